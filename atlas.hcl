@@ -23,3 +23,28 @@ env "app" {
     }
   }
 }
+
+data "external_schema" "postgres" {
+  program = [
+    "go",
+    "run",
+    "-mod=mod",
+    "ariga.io/atlas-provider-gorm",
+    "load",
+    "--path", "./models",
+    "--dialect", "postgres",
+  ]
+}
+
+env "postgres" {
+  src = data.external_schema.postgres.url
+  dev = "docker://postgres/17/dev?search_path=public"
+  migration {
+    dir = "file://migrations/postgres"
+  }
+  format {
+    migrate {
+      diff = "{{ sql . \"  \" }}"
+    }
+  }
+}
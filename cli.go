@@ -0,0 +1,227 @@
+package main
+
+import (
+	"biyobot/configs"
+	"biyobot/logging"
+	"biyobot/migrations"
+	"biyobot/services/database"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const migrateUsage = "usage: biyobot migrate status|up|down [n]"
+
+// subcommands run instead of the bot, each returns the exit code.
+var subcommands = map[string]func(args []string) int{
+	"migrate": runMigrate,
+	"backup":  runBackup,
+	"restore": runRestore,
+	"copydb":  runCopyDatabase,
+}
+
+// openDatabase opens the configured database for a subcommand.
+func openDatabase() (*database.DatabaseManager, configs.DatabaseConfig, error) {
+	conf, err := configs.NewDatabaseConfig()
+	if err != nil {
+		return nil, conf, err
+	}
+	dbm, err := database.NewDatabaseManager(conf)
+	return dbm, conf, err
+}
+
+// newMigrator returns the migrator for the dialect of dbm.
+func newMigrator(dbm *database.DatabaseManager) (*database.Migrator, error) {
+	fsys, err := migrations.For(dbm.Dialect())
+	if err != nil {
+		return nil, err
+	}
+	return database.NewMigrator(dbm, fsys)
+}
+
+// migrateOnBoot brings the schema up to date before anything touches it.
+func migrateOnBoot(dbm *database.DatabaseManager) {
+	migrator, err := newMigrator(dbm)
+	if err != nil {
+		logging.Fatal("failed to load migrations", "err", err)
+	}
+	versions, err := migrator.Up()
+	if err != nil {
+		logging.Fatal("refusing to start, migrations failed", "err", err)
+	}
+	for _, version := range versions {
+		slog.Info("applied migration", "version", version)
+	}
+}
+
+// runMigrate runs the migrate subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	dbm, _, err := openDatabase()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer dbm.Close()
+	migrator, err := newMigrator(dbm)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.Up == "":
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05") + " (unknown to this binary)"
+			case status.AppliedAt != nil:
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%s  %s\n", status.Version, state)
+		}
+	case "up":
+		versions, err := migrator.Up()
+		for _, version := range versions {
+			fmt.Println("applied", version)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if len(versions) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				fmt.Println(migrateUsage)
+				return 2
+			}
+		}
+		versions, err := migrator.Down(n)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, version := range versions {
+			fmt.Println("reverted", version)
+		}
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// runBackup writes a backup into the backup directory, or the directory given.
+func runBackup(args []string) int {
+	dbm, conf, err := openDatabase()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer dbm.Close()
+	dir := conf.BackupDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	path, err := dbm.Backup(dir)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println("backed up to", path)
+	return 0
+}
+
+// runRestore replaces the database with a backup, the bot must be stopped.
+// The backup's schema is migrated on the next start.
+func runRestore(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("usage: biyobot restore <backup file>")
+		return 2
+	}
+	conf, err := configs.NewDatabaseConfig()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if conf.Driver != "sqlite" {
+		fmt.Println("restore only handles SQLite backups, restore PostgreSQL with its own tools, e.g. pg_restore")
+		return 2
+	}
+	previous, err := database.RestoreBackup(conf.Path, args[0])
+	if previous != "" {
+		fmt.Println("previous database kept at", previous)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("restored %s from %s\n", conf.Path, args[0])
+	return 0
+}
+
+// runCopyDatabase copies a SQLite database into the configured one, to move
+// a deployment to PostgreSQL. Both are migrated first, the configured one
+// must be empty.
+func runCopyDatabase(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("usage: biyobot copydb <sqlite file>")
+		return 2
+	}
+	dst, conf, err := openDatabase()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer dst.Close()
+	if conf.Driver == "sqlite" && filepath.Clean(conf.Path) == filepath.Clean(args[0]) {
+		fmt.Println("the SQLite file is the configured database, set DATABASE_DRIVER and DATABASE_URL to the destination")
+		return 2
+	}
+	if _, err := os.Stat(args[0]); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	src, err := database.NewDatabaseManager(configs.DatabaseConfig{Driver: "sqlite", Path: args[0]})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer src.Close()
+
+	for _, dbm := range []*database.DatabaseManager{src, dst} {
+		migrator, err := newMigrator(dbm)
+		if err == nil {
+			_, err = migrator.Up()
+		}
+		if err != nil {
+			fmt.Printf("failed to migrate %s database: %s\n", dbm.Dialect(), err)
+			return 1
+		}
+	}
+
+	results, err := database.CopyDatabase(src, dst)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for _, result := range results {
+		fmt.Printf("%-18s %d rows\n", result.Table, result.Rows)
+	}
+	return 0
+}
@@ -1,49 +1,148 @@
 package configs
 
 import (
+	"biyobot/logging"
 	"fmt"
-	"log"
-	"os"
-	"strings"
+	"log/slog"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 type AppConfig struct {
-	DiscordToken string
+	// File is the config file the settings were looked for in, it need not
+	// exist. See load for the order settings are read in.
+	File                  string
+	DiscordToken          string
 	DiscordMasterServerId string
+	// manages the guild allow-list, defaults to the owner of the bot application
+	DiscordOwnerId string
+	// optional, seed channel bindings on first start. Bindings are managed
+	// with the !bind command afterwards.
 	DiscordSrvSchedulerCid string
+	DiscordSrvReceiptsCid  string
+	LLMModel               string
+	// routing below this confidence is refused, see llm.IntentService
+	IntentConfidenceThreshold float64
+	// how long messages of each kind live before the bot deletes them,
+	// MESSAGE_TTL_<KIND> in seconds overrides the defaults
+	MessageTTLs map[string]time.Duration
+	Tasks       TaskSchedules
+	Services    ServicesConfig
+	Database    DatabaseConfig
+	Log         logging.Options
+	// serves /metrics, /healthz and /readyz when set
+	MetricsAddr string
+}
+
+// TaskSchedules is when the bot's background tasks run.
+type TaskSchedules struct {
+	Cleanup       TaskSchedule `yaml:"cleanup"`
+	Notifications TaskSchedule `yaml:"notifications"`
+	Actions       TaskSchedule `yaml:"actions"`
+	Purge         TaskSchedule `yaml:"purge"`
+}
+
+// TaskSchedule is an interval or a cron expression with runs starting up to
+// Jitter late. Loading checks it with scheduler.Parse, the bot parses it
+// again to get the schedule.
+// In YAML it is either the schedule alone or a mapping with both.
+type TaskSchedule struct {
+	Schedule string        `yaml:"schedule"`
+	Jitter   time.Duration `yaml:"jitter"`
+}
+
+func (t *TaskSchedule) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = TaskSchedule{}
+		return node.Decode(&t.Schedule)
+	}
+	type plain TaskSchedule
+	return node.Decode((*plain)(t))
+}
+
+func (t TaskSchedule) String() string {
+	if t.Jitter > 0 {
+		return fmt.Sprintf("%s (up to +%s)", t.Schedule, t.Jitter)
+	}
+	return t.Schedule
+}
+
+// ServicesConfig holds the settings of the services that have any.
+type ServicesConfig struct {
+	Receipts struct {
+		OCR RunnerConfig `yaml:"ocr"`
+	} `yaml:"receipts"`
+	// the sample python service
+	Python RunnerConfig `yaml:"python"`
+}
+
+// RunnerConfig is an external program a service runs, see
+// services.ExternalRunner.
+type RunnerConfig struct {
+	Executable string        `yaml:"executable"`
+	Args       []string      `yaml:"args"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+var defaultMessageTTLs = map[string]time.Duration{
+	MessageKinds.Reply:    180 * time.Second,
+	MessageKinds.Request:  180 * time.Second,
+	MessageKinds.Error:    180 * time.Second,
+	MessageKinds.Reminder: 24 * time.Hour,
+}
+
+// MessageTTL returns how long a message of kind lives, unknown kinds get the
+// reply TTL.
+func (c *AppConfig) MessageTTL(kind string) time.Duration {
+	if ttl, ok := c.MessageTTLs[kind]; ok {
+		return ttl
+	}
+	return c.MessageTTLs[MessageKinds.Reply]
 }
 
 func NewAppConfig() (*AppConfig, error) {
 	// env
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+		slog.Info("no .env file found")
 	} else {
-		log.Println(".env loaded successfully")
+		slog.Info(".env loaded")
 	}
+	return loadAppConfig()
+}
 
-	var missingVars []string
-	discordToken := os.Getenv("DISCORD_BOT_TOKEN")
-	if discordToken == "" {
-		missingVars = append(missingVars, "DISCORD_BOT_TOKEN")
-	}
-	discordMasterServerId := os.Getenv("DISCORD_MASTER_SERVER_ID")
-	if discordMasterServerId == "" {
-		missingVars = append(missingVars, "DISCORD_MASTER_SERVER_ID")
-	}
-	discordServiceSchedulerCid := os.Getenv("DISCORD_SERVICE_SCHEDULER_CID")
-	if discordServiceSchedulerCid  == "" {
-		missingVars = append(missingVars, "DISCORD_SERVICE_SCHEDULER_CID")
+// ReloadAppConfig reads the config again, with .env values replacing the
+// ones loaded before.
+func ReloadAppConfig() (*AppConfig, error) {
+	if err := godotenv.Overload(); err != nil {
+		slog.Info("no .env file found")
 	}
+	return loadAppConfig()
+}
 
-	if len(missingVars) > 0 {
-		return nil, fmt.Errorf("missing required environment variables: %s",
-			strings.Join(missingVars, ", "))
+func loadAppConfig() (*AppConfig, error) {
+	l := load()
+	l.validateApp()
+	l.validateDatabase()
+	if err := l.err(); err != nil {
+		return nil, err
 	}
+	f := l.file
 	return &AppConfig{
-		DiscordToken: discordToken,
-		DiscordMasterServerId: discordMasterServerId,
-		DiscordSrvSchedulerCid: discordServiceSchedulerCid,
+		File:                      l.path,
+		DiscordToken:              f.Discord.Token,
+		DiscordMasterServerId:     f.Discord.MasterServerId,
+		DiscordOwnerId:            f.Discord.OwnerId,
+		DiscordSrvSchedulerCid:    f.Discord.SchedulerChannelId,
+		DiscordSrvReceiptsCid:     f.Discord.ReceiptsChannelId,
+		LLMModel:                  f.LLM.Model,
+		IntentConfidenceThreshold: f.LLM.ConfidenceThreshold,
+		MessageTTLs:               f.MessageTTLs,
+		Tasks:                     f.Tasks,
+		Services:                  f.Services,
+		Database:                  f.Database,
+		Log:                       f.Log,
+		MetricsAddr:               f.Metrics.Addr,
 	}, nil
 }
@@ -0,0 +1,17 @@
+package configs
+
+import "strings"
+
+// AttachmentRef points at a file kept in the local content-addressed store.
+// Services receive these instead of raw Discord URLs.
+type AttachmentRef struct {
+	Hash        string `json:"hash"` // sha256, hex encoded
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Path        string `json:"path"`
+}
+
+func (a AttachmentRef) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
@@ -2,6 +2,24 @@ package configs
 
 var ServiceNames = struct {
 	Scheduler string
+	Receipts  string
+	Polls     string
 }{
 	Scheduler: "scheduler",
+	Receipts:  "receipts",
+	Polls:     "polls",
+}
+
+// MessageKinds are the kinds of messages the bot expires, each with its own
+// time to live, see AppConfig.MessageTTL.
+var MessageKinds = struct {
+	Reply    string
+	Request  string
+	Error    string
+	Reminder string
+}{
+	Reply:    "reply",
+	Request:  "request",
+	Error:    "error",
+	Reminder: "reminder",
 }
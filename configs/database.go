@@ -0,0 +1,37 @@
+package configs
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DatabaseConfig is which database the bot uses and how it is backed up.
+// The migrate, backup, restore and copydb subcommands read it on its own,
+// they run without the Discord settings.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // sqlite | postgres
+	// Path is the SQLite database, its directory also holds the other local
+	// state like uploaded files. URL is the PostgreSQL connection string.
+	Path string `yaml:"path"`
+	URL  string `yaml:"url"`
+	// scheduled backups, a zero interval disables them. BackupDir defaults
+	// to backups/ next to the database.
+	BackupDir       string        `yaml:"backup_dir"`
+	BackupInterval  time.Duration `yaml:"backup_interval"`
+	BackupRetention int           `yaml:"backup_retention"`
+	// deleted notifications are purged after this many days, 0 keeps them
+	DeletedRetentionDays int `yaml:"deleted_retention_days"`
+}
+
+// NewDatabaseConfig loads .env and reads the database config from the
+// config file and the environment.
+func NewDatabaseConfig() (DatabaseConfig, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Info("no .env file found")
+	}
+	l := load()
+	l.validateDatabase()
+	return l.file.Database, l.err()
+}
@@ -3,6 +3,11 @@ package configs
 type DiscordMetadata struct {
 	MessageId string
 	ChannelId string
+	GuildId   string // empty for DMs
 	UserId    string
 	Username  string
 }
+
+func (m DiscordMetadata) IsDM() bool {
+	return m.GuildId == ""
+}
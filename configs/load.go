@@ -0,0 +1,308 @@
+package configs
+
+import (
+	"biyobot/logging"
+	"biyobot/services/scheduler"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is where the config file is looked for, CONFIG_FILE
+// points elsewhere. See config.example.yaml for every setting.
+const DefaultConfigFile = "config.yaml"
+
+// fileConfig is the config file. Settings are read in order: the defaults,
+// the file when there is one, then the environment variables overriding
+// single settings, so deployments configured through .env keep working.
+type fileConfig struct {
+	Discord struct {
+		Token              string `yaml:"token"`
+		MasterServerId     string `yaml:"master_server_id"`
+		OwnerId            string `yaml:"owner_id"`
+		SchedulerChannelId string `yaml:"scheduler_channel_id"`
+		ReceiptsChannelId  string `yaml:"receipts_channel_id"`
+	} `yaml:"discord"`
+	LLM struct {
+		Model               string  `yaml:"model"`
+		ConfidenceThreshold float64 `yaml:"confidence_threshold"`
+	} `yaml:"llm"`
+	MessageTTLs map[string]time.Duration `yaml:"message_ttls"`
+	Tasks       TaskSchedules            `yaml:"tasks"`
+	Services    ServicesConfig           `yaml:"services"`
+	Database    DatabaseConfig           `yaml:"database"`
+	Log         logging.Options          `yaml:"log"`
+	Metrics     struct {
+		Addr string `yaml:"addr"`
+	} `yaml:"metrics"`
+}
+
+func defaultFileConfig() fileConfig {
+	var f fileConfig
+	f.LLM.Model = "qwen2.5:3b"
+	f.LLM.ConfidenceThreshold = 0.6
+	f.MessageTTLs = make(map[string]time.Duration, len(defaultMessageTTLs))
+	for kind, ttl := range defaultMessageTTLs {
+		f.MessageTTLs[kind] = ttl
+	}
+	f.Tasks = TaskSchedules{
+		Cleanup:       TaskSchedule{Schedule: "3m"},
+		Notifications: TaskSchedule{Schedule: "1m"},
+		Actions:       TaskSchedule{Schedule: "30s"},
+		Purge:         TaskSchedule{Schedule: "0 4 * * *"},
+	}
+	f.Services.Receipts.OCR = RunnerConfig{
+		Executable: "external/ocr/venv/bin/python3",
+		Args:       []string{"external/ocr/ocr.py"},
+		Timeout:    60 * time.Second,
+	}
+	f.Services.Python = RunnerConfig{
+		Executable: "external/test/venv/bin/python3",
+		Args:       []string{"external/test/test.py"},
+		Timeout:    10 * time.Second,
+	}
+	f.Database = DatabaseConfig{
+		Driver:               "sqlite",
+		Path:                 "dbs/app.db",
+		BackupInterval:       24 * time.Hour,
+		BackupRetention:      7,
+		DeletedRetentionDays: 30,
+	}
+	f.Log = logging.Options{Level: "info", Format: "text", Redact: "content"}
+	return f
+}
+
+// ConfigError lists every problem found in the config, so they can all be
+// fixed at once.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// loader reads the config, collecting problems instead of stopping at the
+// first one.
+type loader struct {
+	path     string
+	file     fileConfig
+	problems []string
+}
+
+func load() *loader {
+	l := &loader{path: DefaultConfigFile, file: defaultFileConfig()}
+	if v := os.Getenv("CONFIG_FILE"); v != "" {
+		l.path = v
+	}
+	l.readFile()
+	l.readEnv()
+	return l
+}
+
+func (l *loader) problemf(format string, args ...any) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+func (l *loader) err() error {
+	if len(l.problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: l.problems}
+}
+
+// readFile decodes the config file over the defaults, settings left out of
+// it keep their default. A missing file is fine, only env vars are used then.
+func (l *loader) readFile() {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		l.problemf("failed to read %s: %s", l.path, err)
+		return
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&l.file)
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+	case errors.As(err, &typeErr):
+		for _, problem := range typeErr.Errors {
+			// "field x not found in type struct {...}", the type says nothing
+			if i := strings.Index(problem, " not found in type "); i >= 0 {
+				problem = problem[:i] + " is not a setting"
+			}
+			l.problemf("%s: %s", l.path, problem)
+		}
+	default:
+		l.problemf("%s: %s", l.path, err)
+	}
+}
+
+// readEnv applies the environment variables that override settings.
+func (l *loader) readEnv() {
+	f := &l.file
+	envString(l, "DISCORD_BOT_TOKEN", &f.Discord.Token)
+	envString(l, "DISCORD_MASTER_SERVER_ID", &f.Discord.MasterServerId)
+	envString(l, "DISCORD_OWNER_ID", &f.Discord.OwnerId)
+	envString(l, "DISCORD_SERVICE_SCHEDULER_CID", &f.Discord.SchedulerChannelId)
+	envString(l, "DISCORD_SERVICE_RECEIPTS_CID", &f.Discord.ReceiptsChannelId)
+	envString(l, "LLM_MODEL", &f.LLM.Model)
+	env(l, "INTENT_CONFIDENCE_THRESHOLD", &f.LLM.ConfidenceThreshold, "a number", func(v string) (float64, error) {
+		return strconv.ParseFloat(v, 64)
+	})
+	for kind := range defaultMessageTTLs {
+		ttl := f.MessageTTLs[kind]
+		env(l, "MESSAGE_TTL_"+strings.ToUpper(kind), &ttl, "a number of seconds", seconds)
+		f.MessageTTLs[kind] = ttl
+	}
+
+	envString(l, "DATABASE_DRIVER", &f.Database.Driver)
+	envString(l, "DATABASE_PATH", &f.Database.Path)
+	envString(l, "DATABASE_URL", &f.Database.URL)
+	envString(l, "BACKUP_DIR", &f.Database.BackupDir)
+	env(l, "BACKUP_INTERVAL_HOURS", &f.Database.BackupInterval, "a number of hours, 0 to disable", func(v string) (time.Duration, error) {
+		hours, err := strconv.Atoi(v)
+		return time.Duration(hours) * time.Hour, err
+	})
+	env(l, "BACKUP_RETENTION", &f.Database.BackupRetention, "a number of backups", strconv.Atoi)
+	env(l, "DELETED_RETENTION_DAYS", &f.Database.DeletedRetentionDays, "a number of days, 0 to keep deleted rows", strconv.Atoi)
+
+	envString(l, "LOG_LEVEL", &f.Log.Level)
+	envString(l, "LOG_FORMAT", &f.Log.Format)
+	envString(l, "LOG_REDACT", &f.Log.Redact)
+
+	envString(l, "METRICS_ADDR", &f.Metrics.Addr)
+}
+
+// env overrides dst with the variable key when it is set.
+func env[T any](l *loader, key string, dst *T, want string, parse func(string) (T, error)) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := parse(v)
+	if err != nil {
+		l.problemf("%s must be %s, got %q", key, want, v)
+		return
+	}
+	*dst = parsed
+}
+
+func envString(l *loader, key string, dst *string) {
+	env(l, key, dst, "", func(v string) (string, error) { return v, nil })
+}
+
+func seconds(v string) (time.Duration, error) {
+	n, err := strconv.Atoi(v)
+	return time.Duration(n) * time.Second, err
+}
+
+// validateApp checks the settings the bot needs, problems name the config
+// file key with the env var in parentheses where there is one.
+func (l *loader) validateApp() {
+	f := &l.file
+	if f.Discord.Token == "" {
+		l.problemf("discord.token (DISCORD_BOT_TOKEN) is required")
+	}
+	if f.Discord.MasterServerId == "" {
+		l.problemf("discord.master_server_id (DISCORD_MASTER_SERVER_ID) is required")
+	}
+	if f.LLM.Model == "" {
+		l.problemf("llm.model (LLM_MODEL) is required")
+	}
+	if t := f.LLM.ConfidenceThreshold; t < 0 || t > 1 {
+		l.problemf("llm.confidence_threshold (INTENT_CONFIDENCE_THRESHOLD) must be between 0 and 1, got %v", t)
+	}
+
+	for _, kind := range slices.Sorted(maps.Keys(f.MessageTTLs)) {
+		if _, ok := defaultMessageTTLs[kind]; !ok {
+			l.problemf("message_ttls.%s is not a message kind", kind)
+		} else if ttl := f.MessageTTLs[kind]; ttl <= 0 {
+			l.problemf("message_ttls.%s (MESSAGE_TTL_%s) must be positive, got %s", kind, strings.ToUpper(kind), ttl)
+		}
+	}
+
+	for _, task := range []struct {
+		key      string
+		schedule TaskSchedule
+	}{
+		{"tasks.cleanup", f.Tasks.Cleanup},
+		{"tasks.notifications", f.Tasks.Notifications},
+		{"tasks.actions", f.Tasks.Actions},
+		{"tasks.purge", f.Tasks.Purge},
+	} {
+		if task.schedule.Schedule == "" {
+			l.problemf("%s: a schedule is required", task.key)
+		} else if _, err := scheduler.Parse(task.schedule.Schedule, task.schedule.Jitter); err != nil {
+			l.problemf("%s: %s", task.key, err)
+		}
+	}
+	l.validateRunner("services.receipts.ocr", f.Services.Receipts.OCR)
+	l.validateRunner("services.python", f.Services.Python)
+
+	if _, err := logging.ParseLevel(f.Log.Level); err != nil {
+		l.problemf("log.level (LOG_LEVEL): %s", err)
+	}
+	if f.Log.Format != "text" && f.Log.Format != "json" {
+		l.problemf("log.format (LOG_FORMAT) must be text or json, got %q", f.Log.Format)
+	}
+	if _, err := logging.ParseRedaction(f.Log.Redact); err != nil {
+		l.problemf("log.redact (LOG_REDACT): %s", err)
+	}
+	if addr := f.Metrics.Addr; addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			l.problemf("metrics.addr (METRICS_ADDR) must be host:port or :port, got %q", addr)
+		}
+	}
+}
+
+func (l *loader) validateRunner(key string, runner RunnerConfig) {
+	if runner.Executable == "" {
+		l.problemf("%s.executable is required", key)
+	}
+	if runner.Timeout <= 0 {
+		l.problemf("%s.timeout must be positive, got %s", key, runner.Timeout)
+	}
+}
+
+func (l *loader) validateDatabase() {
+	d := &l.file.Database
+	switch d.Driver {
+	case "sqlite":
+		if d.Path == "" {
+			l.problemf("database.path (DATABASE_PATH) is required with the sqlite driver")
+		}
+	case "postgres":
+		if d.URL == "" {
+			l.problemf("database.url (DATABASE_URL) is required with the postgres driver")
+		}
+	default:
+		l.problemf("database.driver (DATABASE_DRIVER) must be sqlite or postgres, got %q", d.Driver)
+	}
+	if d.BackupDir == "" {
+		d.BackupDir = filepath.Join(filepath.Dir(d.Path), "backups")
+	}
+	if d.BackupInterval < 0 {
+		l.problemf("database.backup_interval (BACKUP_INTERVAL_HOURS) must not be negative, 0 disables backups")
+	}
+	if d.BackupRetention < 1 {
+		l.problemf("database.backup_retention (BACKUP_RETENTION) must be at least 1, got %d", d.BackupRetention)
+	}
+	if d.DeletedRetentionDays < 0 {
+		l.problemf("database.deleted_retention_days (DELETED_RETENTION_DAYS) must not be negative, 0 keeps deleted rows")
+	}
+}
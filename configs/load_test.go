@@ -0,0 +1,213 @@
+package configs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envKeys are the variables that override settings, cleared so the
+// environment the tests run in doesn't leak into them.
+var envKeys = []string{
+	"DISCORD_BOT_TOKEN", "DISCORD_MASTER_SERVER_ID", "DISCORD_OWNER_ID",
+	"DISCORD_SERVICE_SCHEDULER_CID", "DISCORD_SERVICE_RECEIPTS_CID",
+	"LLM_MODEL", "INTENT_CONFIDENCE_THRESHOLD",
+	"MESSAGE_TTL_REPLY", "MESSAGE_TTL_REQUEST", "MESSAGE_TTL_ERROR", "MESSAGE_TTL_REMINDER",
+	"DATABASE_DRIVER", "DATABASE_PATH", "DATABASE_URL", "BACKUP_DIR",
+	"BACKUP_INTERVAL_HOURS", "BACKUP_RETENTION", "DELETED_RETENTION_DAYS",
+	"LOG_LEVEL", "LOG_FORMAT", "LOG_REDACT", "METRICS_ADDR",
+}
+
+// useConfig points CONFIG_FILE at a file holding content, with every
+// override variable unset.
+func useConfig(t *testing.T, content string) {
+	t.Helper()
+	for _, key := range envKeys {
+		t.Setenv(key, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+const requiredSettings = `
+discord:
+  token: file-token
+  master_server_id: "42"
+`
+
+func TestLoadDefaults(t *testing.T) {
+	useConfig(t, requiredSettings)
+	conf, err := loadAppConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.DiscordToken != "file-token" || conf.DiscordMasterServerId != "42" {
+		t.Errorf("discord settings = %q, %q, want the file's", conf.DiscordToken, conf.DiscordMasterServerId)
+	}
+	if conf.LLMModel != "qwen2.5:3b" || conf.IntentConfidenceThreshold != 0.6 {
+		t.Errorf("llm = %q at %v, want the defaults", conf.LLMModel, conf.IntentConfidenceThreshold)
+	}
+	if ttl := conf.MessageTTL(MessageKinds.Reminder); ttl != 24*time.Hour {
+		t.Errorf("reminder TTL = %s, want 24h", ttl)
+	}
+	if ttl := conf.MessageTTL("unknown"); ttl != 180*time.Second {
+		t.Errorf("unknown kind TTL = %s, want the reply TTL", ttl)
+	}
+	if conf.Tasks.Purge.Schedule != "0 4 * * *" || conf.Tasks.Cleanup.Schedule != "3m" {
+		t.Errorf("tasks = %+v, want the defaults", conf.Tasks)
+	}
+	if conf.Database.Driver != "sqlite" || conf.Database.Path != "dbs/app.db" {
+		t.Errorf("database = %s %s, want the default SQLite file", conf.Database.Driver, conf.Database.Path)
+	}
+	if conf.Database.BackupDir != filepath.Join("dbs", "backups") {
+		t.Errorf("backup dir = %q, want backups/ next to the database", conf.Database.BackupDir)
+	}
+	if conf.Log.Level != "info" || conf.Log.Redact != "content" {
+		t.Errorf("log = %+v, want the defaults", conf.Log)
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	useConfig(t, requiredSettings+`
+llm:
+  model: file-model
+message_ttls:
+  reply: 5m
+tasks:
+  cleanup:
+    schedule: 10m
+    jitter: 30s
+`)
+	t.Setenv("DISCORD_BOT_TOKEN", "env-token")
+	t.Setenv("LLM_MODEL", "env-model")
+	t.Setenv("MESSAGE_TTL_REPLY", "60")
+	t.Setenv("BACKUP_INTERVAL_HOURS", "6")
+	conf, err := loadAppConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.DiscordToken != "env-token" || conf.LLMModel != "env-model" {
+		t.Errorf("token %q, model %q, want the env values", conf.DiscordToken, conf.LLMModel)
+	}
+	if ttl := conf.MessageTTL(MessageKinds.Reply); ttl != time.Minute {
+		t.Errorf("reply TTL = %s, want MESSAGE_TTL_REPLY's 1m", ttl)
+	}
+	if ttl := conf.MessageTTL(MessageKinds.Error); ttl != 180*time.Second {
+		t.Errorf("error TTL = %s, want the default", ttl)
+	}
+	if conf.Database.BackupInterval != 6*time.Hour {
+		t.Errorf("backup interval = %s, want 6h", conf.Database.BackupInterval)
+	}
+	if want := (TaskSchedule{Schedule: "10m", Jitter: 30 * time.Second}); conf.Tasks.Cleanup != want {
+		t.Errorf("cleanup = %+v, want %+v", conf.Tasks.Cleanup, want)
+	}
+}
+
+func TestLoadListsEveryProblem(t *testing.T) {
+	useConfig(t, `
+discord:
+  tokne: typo
+llm:
+  confidence_threshold: 2
+tasks:
+  cleanup: every day
+  actions: 100ms
+  purge:
+    schedule: 3m
+    jitter: -1s
+  notifications: "0 0 31 2 *"
+log:
+  format: xml
+`)
+	t.Setenv("BACKUP_RETENTION", "some")
+	_, err := loadAppConfig()
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("err = %v, want a ConfigError", err)
+	}
+
+	for _, want := range []string{
+		"tokne is not a setting",
+		"discord.token (DISCORD_BOT_TOKEN) is required",
+		"discord.master_server_id (DISCORD_MASTER_SERVER_ID) is required",
+		"llm.confidence_threshold (INTENT_CONFIDENCE_THRESHOLD) must be between 0 and 1",
+		"tasks.cleanup: ",
+		"tasks.actions: interval must be at least 1s",
+		"tasks.purge: jitter must not be negative",
+		"tasks.notifications: cron expression \"0 0 31 2 *\" never matches",
+		"log.format (LOG_FORMAT) must be text or json",
+		"BACKUP_RETENTION must be a number of backups",
+	} {
+		if !slices.ContainsFunc(configErr.Problems, func(problem string) bool { return strings.Contains(problem, want) }) {
+			t.Errorf("no problem mentions %q, got:\n%s", want, configErr)
+		}
+	}
+	if len(configErr.Problems) != 10 {
+		t.Errorf("got %d problems, want 10:\n%s", len(configErr.Problems), configErr)
+	}
+}
+
+func TestReload(t *testing.T) {
+	useConfig(t, requiredSettings)
+	current, err := loadAppConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t, `
+discord:
+  token: new-token
+  master_server_id: "42"
+llm:
+  model: new-model
+tasks:
+  purge: "@weekly"
+log:
+  format: json
+`)
+	next, err := loadAppConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, changes := current.Reload(next)
+	got := make(map[string]Change)
+	for _, change := range changes {
+		got[change.Key] = change
+	}
+	if len(changes) != 4 {
+		t.Errorf("changes = %v, want the token, model, purge schedule and log format", changes)
+	}
+	if c := got["llm.model"]; c.From != "qwen2.5:3b" || c.To != "new-model" || c.Restart {
+		t.Errorf("llm.model change = %+v, want applied", c)
+	}
+	if c := got["tasks.purge"]; c.To != "@weekly" || c.Restart {
+		t.Errorf("tasks.purge change = %+v, want applied", c)
+	}
+	for _, key := range []string{"discord.token", "log.format"} {
+		if !got[key].Restart {
+			t.Errorf("%s change = %+v, want it to need a restart", key, got[key])
+		}
+	}
+	if c := got["discord.token"]; strings.Contains(c.String(), "new-token") {
+		t.Errorf("token change %q shows the token", c)
+	}
+
+	if conf.LLMModel != "new-model" || conf.Tasks.Purge.Schedule != "@weekly" {
+		t.Errorf("reloaded config kept model %q, purge %q", conf.LLMModel, conf.Tasks.Purge.Schedule)
+	}
+	if conf.DiscordToken != "file-token" || conf.Log.Format != "text" {
+		t.Errorf("settings needing a restart changed: token %q, log format %q", conf.DiscordToken, conf.Log.Format)
+	}
+	if current.LLMModel != "qwen2.5:3b" {
+		t.Errorf("Reload changed the current config")
+	}
+}
@@ -0,0 +1,13 @@
+package configs
+
+// NotificationTarget is where a notification is delivered. Notifications
+// without targets are DMed to their creator.
+type NotificationTarget struct {
+	Kind string `json:"kind"` // dm | channel | thread
+	// the user to DM, or the channel to post in (threads start from a post
+	// in this channel)
+	ID string `json:"id"`
+	// user and role mentions added to channel and thread posts, as <@id>
+	// and <@&id>
+	Mentions []string `json:"mentions,omitempty"`
+}
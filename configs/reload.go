@@ -0,0 +1,180 @@
+package configs
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Change is a setting that differs after a reload. Restart is set for the
+// settings that are only read on startup, those keep their old value.
+type Change struct {
+	Key      string
+	From, To string
+	Restart  bool
+}
+
+func (c Change) String() string {
+	if c.Restart {
+		return fmt.Sprintf("%s: %s → %s (needs a restart)", c.Key, c.From, c.To)
+	}
+	return fmt.Sprintf("%s: %s → %s", c.Key, c.From, c.To)
+}
+
+// setting is one setting as it is compared on reload. apply copies it from a
+// reloaded config, it is nil for settings that need a restart.
+type setting struct {
+	key   string
+	value func(c *AppConfig) string
+	apply func(dst, src *AppConfig)
+}
+
+var settings = []setting{
+	{key: "discord.token", value: func(c *AppConfig) string { return secret(c.DiscordToken) }},
+	{key: "discord.master_server_id", value: func(c *AppConfig) string { return c.DiscordMasterServerId }},
+	{
+		key:   "discord.owner_id",
+		value: func(c *AppConfig) string { return c.DiscordOwnerId },
+		// without one configured the owner of the bot application is used
+		apply: func(dst, src *AppConfig) {
+			if src.DiscordOwnerId != "" {
+				dst.DiscordOwnerId = src.DiscordOwnerId
+			}
+		},
+	},
+	{key: "discord.scheduler_channel_id", value: func(c *AppConfig) string { return c.DiscordSrvSchedulerCid }},
+	{key: "discord.receipts_channel_id", value: func(c *AppConfig) string { return c.DiscordSrvReceiptsCid }},
+	{
+		key:   "llm.model",
+		value: func(c *AppConfig) string { return c.LLMModel },
+		apply: func(dst, src *AppConfig) { dst.LLMModel = src.LLMModel },
+	},
+	{
+		key:   "llm.confidence_threshold",
+		value: func(c *AppConfig) string { return fmt.Sprint(c.IntentConfidenceThreshold) },
+		apply: func(dst, src *AppConfig) { dst.IntentConfidenceThreshold = src.IntentConfidenceThreshold },
+	},
+	{
+		key: "message_ttls",
+		value: func(c *AppConfig) string {
+			var ttls []string
+			for _, kind := range slices.Sorted(maps.Keys(c.MessageTTLs)) {
+				ttls = append(ttls, fmt.Sprintf("%s=%s", kind, c.MessageTTLs[kind]))
+			}
+			return strings.Join(ttls, " ")
+		},
+		apply: func(dst, src *AppConfig) { dst.MessageTTLs = src.MessageTTLs },
+	},
+	{
+		key:   "tasks.cleanup",
+		value: func(c *AppConfig) string { return c.Tasks.Cleanup.String() },
+		apply: func(dst, src *AppConfig) { dst.Tasks.Cleanup = src.Tasks.Cleanup },
+	},
+	{
+		key:   "tasks.notifications",
+		value: func(c *AppConfig) string { return c.Tasks.Notifications.String() },
+		apply: func(dst, src *AppConfig) { dst.Tasks.Notifications = src.Tasks.Notifications },
+	},
+	{
+		key:   "tasks.actions",
+		value: func(c *AppConfig) string { return c.Tasks.Actions.String() },
+		apply: func(dst, src *AppConfig) { dst.Tasks.Actions = src.Tasks.Actions },
+	},
+	{
+		key:   "tasks.purge",
+		value: func(c *AppConfig) string { return c.Tasks.Purge.String() },
+		apply: func(dst, src *AppConfig) { dst.Tasks.Purge = src.Tasks.Purge },
+	},
+	{key: "services.receipts.ocr", value: func(c *AppConfig) string { return fmt.Sprint(c.Services.Receipts.OCR) }},
+	{key: "services.python", value: func(c *AppConfig) string { return fmt.Sprint(c.Services.Python) }},
+	{key: "database.driver", value: func(c *AppConfig) string { return c.Database.Driver }},
+	{key: "database.path", value: func(c *AppConfig) string { return c.Database.Path }},
+	{key: "database.url", value: func(c *AppConfig) string { return secret(c.Database.URL) }},
+	{key: "database.backup_dir", value: func(c *AppConfig) string { return c.Database.BackupDir }},
+	{key: "database.backup_interval", value: func(c *AppConfig) string { return c.Database.BackupInterval.String() }},
+	{
+		key:   "database.backup_retention",
+		value: func(c *AppConfig) string { return fmt.Sprint(c.Database.BackupRetention) },
+		apply: func(dst, src *AppConfig) { dst.Database.BackupRetention = src.Database.BackupRetention },
+	},
+	{
+		key:   "database.deleted_retention_days",
+		value: func(c *AppConfig) string { return fmt.Sprint(c.Database.DeletedRetentionDays) },
+		apply: func(dst, src *AppConfig) { dst.Database.DeletedRetentionDays = src.Database.DeletedRetentionDays },
+	},
+	{
+		key:   "log.level",
+		value: func(c *AppConfig) string { return c.Log.Level },
+		apply: func(dst, src *AppConfig) { dst.Log.Level = src.Log.Level },
+	},
+	{key: "log.format", value: func(c *AppConfig) string { return c.Log.Format }},
+	{
+		key:   "log.redact",
+		value: func(c *AppConfig) string { return c.Log.Redact },
+		apply: func(dst, src *AppConfig) { dst.Log.Redact = src.Log.Redact },
+	},
+	{key: "metrics.addr", value: func(c *AppConfig) string { return c.MetricsAddr }},
+}
+
+// secret stands in for tokens and connection strings in logs, a short hash
+// tells them apart without revealing them.
+func secret(v string) string {
+	if v == "" {
+		return `""`
+	}
+	h := fnv.New32a()
+	h.Write([]byte(v))
+	return fmt.Sprintf("<secret %08x>", h.Sum32())
+}
+
+// Reload returns c with the settings of next that apply at runtime and
+// every setting that differs, those needing a restart included.
+func (c *AppConfig) Reload(next *AppConfig) (*AppConfig, []Change) {
+	conf := *c
+	var changes []Change
+	for _, s := range settings {
+		from := s.value(c)
+		to := s.value(next)
+		if s.apply != nil {
+			s.apply(&conf, next)
+			to = s.value(&conf)
+		}
+		if from != to {
+			changes = append(changes, Change{Key: s.key, From: from, To: to, Restart: s.apply == nil})
+		}
+	}
+	return &conf, changes
+}
+
+// WatchFile calls onChange whenever the file at path is written, created or
+// removed, checking every interval until ctx is done.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	stamp := func() string {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+	}
+	last := stamp()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if current := stamp(); current != last {
+				last = current
+				slog.Info("config file changed, reloading", "path", path)
+				onChange()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package configs
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -30,6 +31,8 @@ func (r ServiceResult) Decode(target any) error {
 	return json.Unmarshal(r.Data, target)
 }
 
+// Runner is a service. ctx is the request the service runs for, it carries
+// its request ID and ends when the request is abandoned.
 type Runner interface {
-	Run(input json.RawMessage) ServiceResult
+	Run(ctx context.Context, input json.RawMessage) ServiceResult
 }
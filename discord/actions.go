@@ -0,0 +1,132 @@
+package discord
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+// ActionPayload is what a scheduled post or edit writes. Posts can expire
+// like any other message of ExpireKind, an empty kind keeps them.
+type ActionPayload struct {
+	Content    string `json:"content"`
+	ExpireKind string `json:"expire_kind,omitempty"`
+}
+
+type ScheduleActionDto struct {
+	Action    string // post | edit | pin | unpin | delete
+	ChannelId string
+	MessageId string // the message to act on, empty for posts
+	At        time.Time
+	Payload   ActionPayload
+	RefId     string
+}
+
+// scheduleAction queues a Discord action, executed by ExecuteScheduledActions
+// once At has passed.
+func (b *DiscordBot) scheduleAction(ctx context.Context, data ScheduleActionDto) error {
+	switch data.Action {
+	case "post", "edit", "pin", "unpin", "delete":
+	default:
+		return fmt.Errorf("unknown scheduled action %q", data.Action)
+	}
+	payload, err := utils.StructToJson(data.Payload)
+	if err != nil {
+		return err
+	}
+	_, err = b.Repos.DiscordMessages.AddMessage(ctx, database.AddDiscordMessageDto{
+		Action:          data.Action,
+		ChannelId:       data.ChannelId,
+		UserId:          b.State.User.ID,
+		MessageId:       data.MessageId,
+		ExecuteActionOn: utils.InJapanTime(data.At),
+		Payload:         payload,
+		RefId:           data.RefId,
+	})
+	return err
+}
+
+// ExecuteScheduledActions runs the due posts, edits, pins and unpins.
+// Failed actions are retried on later runs like deletions are.
+func (b *DiscordBot) ExecuteScheduledActions(ctx context.Context) error {
+	actions, err := b.Repos.DiscordMessages.GetDueActions(ctx)
+	if err != nil {
+		return fmt.Errorf("getting scheduled actions failed: %w", err)
+	}
+
+	var finished []uuid.UUID
+	retries := make(map[int][]uuid.UUID) // by attempts so far
+	for _, action := range actions {
+		outcome := b.executeAction(ctx, action)
+		switch {
+		case outcome == requestRetry && action.Attempts+1 < maxActionAttempts:
+			retries[action.Attempts] = append(retries[action.Attempts], action.ID)
+		case outcome != requestDone:
+			slog.WarnContext(ctx, "giving up scheduled action", "action", action.Action, "action_id", action.ID, "channel_id", action.ChannelId)
+			fallthrough
+		default:
+			finished = append(finished, action.ID)
+		}
+	}
+
+	if len(finished) != 0 {
+		if err := b.Repos.DiscordMessages.DeleteMessageBatch(ctx, finished); err != nil {
+			slog.ErrorContext(ctx, "removing executed actions failed", "err", err)
+		}
+	}
+	for attempts, ids := range retries {
+		retryAt := utils.JapanTimeNow().Add(time.Minute << attempts)
+		if err := b.Repos.DiscordMessages.RetryMessageBatch(ctx, ids, retryAt); err != nil {
+			slog.ErrorContext(ctx, "rescheduling actions failed", "err", err)
+		}
+	}
+	return nil
+}
+
+func (b *DiscordBot) executeAction(ctx context.Context, action models.DiscordMessage) requestOutcome {
+	var payload ActionPayload
+	if action.Payload != "" {
+		if err := json.Unmarshal([]byte(action.Payload), &payload); err != nil {
+			slog.ErrorContext(ctx, "scheduled action has a broken payload", "action_id", action.ID, "err", err)
+			return requestDrop
+		}
+	}
+
+	switch action.Action {
+	case "post":
+		var msg *discordgo.Message
+		outcome := requestWithRetry(ctx, func() (err error) {
+			msg, err = b.Session.ChannelMessageSend(action.ChannelId, payload.Content)
+			return err
+		})
+		if outcome == requestDone && msg != nil && payload.ExpireKind != "" {
+			if err := b.tagMessageToBeDeleted(msg, payload.ExpireKind); err != nil {
+				slog.ErrorContext(ctx, "failed to tag message for deletion", "err", err)
+			}
+		}
+		return outcome
+	case "edit":
+		return requestWithRetry(ctx, func() error {
+			_, err := b.Session.ChannelMessageEdit(action.ChannelId, action.MessageId, payload.Content)
+			return err
+		})
+	case "pin":
+		return requestWithRetry(ctx, func() error {
+			return b.Session.ChannelMessagePin(action.ChannelId, action.MessageId)
+		})
+	case "unpin":
+		return requestWithRetry(ctx, func() error {
+			return b.Session.ChannelMessageUnpin(action.ChannelId, action.MessageId)
+		})
+	}
+	slog.ErrorContext(ctx, "scheduled action has an unknown action", "action_id", action.ID, "action", action.Action)
+	return requestDrop
+}
@@ -0,0 +1,299 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+// actionRecorder keeps every action scheduled, due or not, until cancelled.
+type actionRecorder struct {
+	database.DiscordMessages
+	added []database.AddDiscordMessageDto
+}
+
+func recordActions(b *DiscordBot) *actionRecorder {
+	r := &actionRecorder{DiscordMessages: b.Repos.DiscordMessages}
+	b.Repos.DiscordMessages = r
+	return r
+}
+
+func (r *actionRecorder) AddMessage(ctx context.Context, data database.AddDiscordMessageDto) (*models.DiscordMessage, error) {
+	if data.Action != "delete" {
+		r.added = append(r.added, data)
+	}
+	return r.DiscordMessages.AddMessage(ctx, data)
+}
+
+func (r *actionRecorder) CancelActions(ctx context.Context, refId string) error {
+	r.added = slices.DeleteFunc(r.added, func(data database.AddDiscordMessageDto) bool { return data.RefId == refId })
+	return r.DiscordMessages.CancelActions(ctx, refId)
+}
+
+func dueActions(t *testing.T, b *DiscordBot) []models.DiscordMessage {
+	t.Helper()
+	actions, err := b.Repos.DiscordMessages.GetDueActions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return actions
+}
+
+func TestScheduleActionPayloadRoundTrip(t *testing.T) {
+	b, _ := newTestBot(t)
+	payload := ActionPayload{Content: "🔔 \"Dinner\" is starting now\n<@alice>", ExpireKind: configs.MessageKinds.Reminder}
+	err := b.scheduleAction(context.Background(), ScheduleActionDto{
+		Action: "post", ChannelId: testChannel, At: time.Now().Add(-time.Minute), Payload: payload, RefId: "ref",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := dueActions(t, b)
+	if len(actions) != 1 {
+		t.Fatalf("got %d due actions, want 1", len(actions))
+	}
+	action := actions[0]
+	if action.Action != "post" || action.ChannelId != testChannel || action.UserId != botUser || action.RefId != "ref" {
+		t.Errorf("stored %+v", action)
+	}
+	var got ActionPayload
+	if err := json.Unmarshal([]byte(action.Payload), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != payload {
+		t.Errorf("payload = %+v, want %+v", got, payload)
+	}
+}
+
+func TestScheduleActionRejectsUnknownActions(t *testing.T) {
+	b, _ := newTestBot(t)
+	err := b.scheduleAction(context.Background(), ScheduleActionDto{Action: "archive", ChannelId: testChannel, At: time.Now()})
+	if err == nil {
+		t.Fatal("scheduled an unknown action")
+	}
+	if actions := dueActions(t, b); len(actions) != 0 {
+		t.Errorf("stored %v", actions)
+	}
+}
+
+func TestExecuteScheduledActions(t *testing.T) {
+	b, session := newTestBot(t)
+	ctx := context.Background()
+	now := time.Now()
+	for i, action := range []ScheduleActionDto{
+		{Action: "post", Payload: ActionPayload{Content: "🔔 Dinner is starting now", ExpireKind: configs.MessageKinds.Reminder}},
+		{Action: "edit", MessageId: "42", Payload: ActionPayload{Content: "⏳ Starts in an hour"}},
+		{Action: "pin", MessageId: "42"},
+		{Action: "unpin", MessageId: "42"},
+		{Action: "delete", MessageId: "43"},
+	} {
+		action.ChannelId = testChannel
+		action.At = now.Add(time.Duration(i-10) * time.Minute)
+		if err := b.scheduleAction(ctx, action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.ExecuteScheduledActions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := session.sentTo(testChannel); !slices.Equal(sent, []string{"🔔 Dinner is starting now"}) {
+		t.Errorf("posted %q", sent)
+	}
+	if len(session.edits) != 1 || session.edits[0].ID != "42" || *session.edits[0].Content != "⏳ Starts in an hour" {
+		t.Errorf("edits = %v", session.edits)
+	}
+	if !slices.Equal(session.pins, []string{"pin 42", "unpin 42"}) {
+		t.Errorf("pins = %q", session.pins)
+	}
+	if actions := dueActions(t, b); len(actions) != 0 {
+		t.Errorf("%d actions left after running", len(actions))
+	}
+	// deletions are the cleanup task's, alongside the post expiring
+	if n := queued(t, b); n != 2 {
+		t.Errorf("%d deletions queued, want the scheduled one and the post's", n)
+	}
+	if len(session.deleted) != 0 {
+		t.Errorf("deleted %q running actions", session.deleted)
+	}
+	if err := b.DeleteExpiredMessages(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(session.deleted, []string{"43"}) {
+		t.Errorf("deleted %q, want the scheduled deletion", session.deleted)
+	}
+}
+
+func TestExecuteScheduledActionsDropsBrokenActions(t *testing.T) {
+	b, session := newTestBot(t)
+	ctx := context.Background()
+	for _, data := range []database.AddDiscordMessageDto{
+		{Action: "archive", MessageId: "42"},
+		{Action: "edit", MessageId: "42", Payload: `{"content":`},
+	} {
+		data.ChannelId = testChannel
+		data.ExecuteActionOn = time.Now().Add(-time.Minute)
+		if _, err := b.Repos.DiscordMessages.AddMessage(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.ExecuteScheduledActions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(session.edits) != 0 {
+		t.Errorf("edited with a broken payload: %v", session.edits)
+	}
+	if actions := dueActions(t, b); len(actions) != 0 {
+		t.Errorf("%d broken actions kept", len(actions))
+	}
+}
+
+func TestExecuteScheduledActionsBacksOff(t *testing.T) {
+	shortBackoff(t)
+	for attempts := range maxActionAttempts {
+		t.Run(strconv.Itoa(attempts)+" attempts before", func(t *testing.T) {
+			b, session := newTestBot(t)
+			requests := 0
+			session.fail = func(request, channelID, messageID string) error {
+				requests++
+				return restError(http.StatusTooManyRequests, 0)
+			}
+			ctx := context.Background()
+			err := b.scheduleAction(ctx, ScheduleActionDto{Action: "pin", ChannelId: testChannel, MessageId: "42", At: time.Now().Add(-time.Minute)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := dueActions(t, b)[0].ID
+			// earlier runs failed as often, leaving the action due again
+			for range attempts {
+				if err := b.Repos.DiscordMessages.RetryMessageBatch(ctx, []uuid.UUID{id}, time.Now().Add(-time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			retries := recordRetries(b)
+
+			if err := b.ExecuteScheduledActions(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if requests != requestRetries {
+				t.Errorf("tried %d times in the run, want %d", requests, requestRetries)
+			}
+
+			retryAt, ok := retries.retryAt[id]
+			if attempts+1 == maxActionAttempts {
+				if ok {
+					t.Errorf("rescheduled after the last attempt")
+				}
+				if actions := dueActions(t, b); len(actions) != 0 {
+					t.Errorf("still due after %d attempts, want it given up", maxActionAttempts)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("not rescheduled")
+			}
+			want := time.Minute << attempts
+			if wait := time.Until(retryAt); wait > want || wait < want-5*time.Second {
+				t.Errorf("retried in %s, want %s", wait, want)
+			}
+		})
+	}
+}
+
+func TestExecuteScheduledActionsOnGoneMessages(t *testing.T) {
+	shortBackoff(t)
+	b, session := newTestBot(t)
+	session.fail = func(request, channelID, messageID string) error {
+		return restError(http.StatusNotFound, discordgo.ErrCodeUnknownMessage)
+	}
+	retries := recordRetries(b)
+	ctx := context.Background()
+	err := b.scheduleAction(ctx, ScheduleActionDto{Action: "unpin", ChannelId: testChannel, MessageId: "42", At: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.ExecuteScheduledActions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if actions := dueActions(t, b); len(actions) != 0 || len(retries.retryAt) != 0 {
+		t.Errorf("kept an action on a deleted message")
+	}
+}
+
+func TestEventCountdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     time.Duration
+		stages []string // action and offset from the start
+	}{
+		{
+			name:   "two days ahead",
+			in:     48 * time.Hour,
+			stages: []string{"pin -24h0m0s", "edit -24h0m0s", "edit -1h0m0s", "edit 0s", "unpin 0s"},
+		},
+		{
+			name:   "half an hour ahead",
+			in:     30 * time.Minute,
+			stages: []string{"pin now", "edit 0s", "unpin 0s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			actions := recordActions(b)
+			ctx := context.Background()
+			meta := guildMeta("alice")
+			at := time.Now().Add(tt.in).Truncate(time.Second)
+			intent := addIntent("Board games", at)
+			intent.Params["is_event"] = true
+
+			if err := b.handleNotifications(ctx, intent, meta, messageOf(meta, "board games, who's in")); err != nil {
+				t.Fatal(err)
+			}
+			notifications, err := b.Repos.Notifications.GetUserNotifications(ctx, testGuild, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			event := notifications[0]
+			if event.RsvpMessageId == "" {
+				t.Fatal("no RSVP post")
+			}
+
+			var stages []string
+			for _, action := range actions.added {
+				if action.ChannelId != event.RsvpChannelId || action.MessageId != event.RsvpMessageId || action.RefId != event.ID.String() {
+					t.Errorf("%s is not on the RSVP post of the event: %+v", action.Action, action)
+				}
+				offset := action.ExecuteActionOn.Sub(at).String()
+				if d := time.Since(action.ExecuteActionOn); d >= 0 && d < time.Minute {
+					offset = "now"
+				}
+				stages = append(stages, action.Action+" "+offset)
+			}
+			if !slices.Equal(stages, tt.stages) {
+				t.Errorf("scheduled %q, want %q", stages, tt.stages)
+			}
+
+			// deleting the event drops its countdown
+			if err := b.handleNotifications(ctx, deleteIntent(event.ID), meta, messageOf(meta, "cancel it")); err != nil {
+				t.Fatal(err)
+			}
+			if len(actions.added) != 0 {
+				t.Errorf("%d actions left after deleting the event", len(actions.added))
+			}
+		})
+	}
+}
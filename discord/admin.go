@@ -0,0 +1,232 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/logging"
+	"biyobot/services/scheduler"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *DiscordBot) cmdStatus(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	pending, err := b.Repos.Notifications.CountNotifications(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to count notifications: %s", err)
+	}
+	queued, err := b.Repos.DiscordMessages.CountQueuedMessages(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to count queued deletions: %s", err)
+	}
+
+	llmStatus := "✅ ok"
+	if err := b.IntentService.Health(ctx); err != nil {
+		llmStatus = "❌ " + err.Error()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 **Status**\n")
+	fmt.Fprintf(&sb, "uptime: %s\n", time.Since(b.startedAt).Round(time.Second))
+	// there is no gateway before Start, nor in tests
+	if b.gateway != nil {
+		fmt.Fprintf(&sb, "gateway latency: %s\n", b.gateway.HeartbeatLatency().Round(time.Millisecond))
+	} else {
+		sb.WriteString("gateway latency: unavailable, not connected\n")
+	}
+	fmt.Fprintf(&sb, "pending notifications: %d\n", pending)
+	fmt.Fprintf(&sb, "queued deletions: %d\n", queued)
+	fmt.Fprintf(&sb, "llm: %s\n", llmStatus)
+	if b.scheduler != nil {
+		sb.WriteString("tasks:\n")
+		for _, name := range b.scheduler.Names() {
+			writeTaskStatus(&sb, b.scheduler.Task(name).Status())
+		}
+	}
+	return sb.String(), nil
+}
+
+func writeTaskStatus(sb *strings.Builder, status scheduler.Status) {
+	state := "idle"
+	switch {
+	case status.Running:
+		state = "running"
+	case status.Paused:
+		state = "paused"
+	}
+	lastRun := "never"
+	if !status.LastRun.IsZero() {
+		lastRun = fmt.Sprintf("%s ago, took %s", time.Since(status.LastRun).Round(time.Second), status.LastDuration.Round(time.Millisecond))
+	}
+	nextRun := "none"
+	if !status.NextRun.IsZero() {
+		nextRun = "in " + max(0, time.Until(status.NextRun)).Round(time.Second).String()
+	}
+	fmt.Fprintf(sb, "• %s (`%s`) — %s, last run %s, next %s\n", status.Name, status.Schedule, state, lastRun, nextRun)
+	if status.LastError != nil {
+		fmt.Fprintf(sb, "  ⚠️ last run failed: %s\n", status.LastError)
+	}
+	if status.Skipped > 0 {
+		fmt.Fprintf(sb, "  ⏭️ %d runs skipped, the previous one was still going\n", status.Skipped)
+	}
+}
+
+func (b *DiscordBot) cmdServices(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	names := b.Services.Names()
+	slices.Sort(names)
+	routable := b.IntentService.ServiceNames()
+
+	var sb strings.Builder
+	sb.WriteString("🧩 **Registered services**\n")
+	for _, name := range names {
+		note := ""
+		if !slices.Contains(routable, name) {
+			note = " (not routed from messages)"
+		}
+		fmt.Fprintf(&sb, "• %s%s\n", name, note)
+	}
+	return sb.String(), nil
+}
+
+// cmdReload re-reads the config. The settings read on startup, like the
+// token or the database, need a restart, everything else applies right away.
+func (b *DiscordBot) cmdReload(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	conf, err := configs.ReloadAppConfig()
+	if err != nil {
+		return "", fmt.Errorf("reload failed, keeping the current config: %s", err)
+	}
+	changes, err := b.applyConfig(conf)
+	if err != nil {
+		return "", fmt.Errorf("reload failed, keeping the current config: %s", err)
+	}
+	slog.InfoContext(ctx, "config reloaded", "user_id", m.Author.ID)
+	if len(changes) == 0 {
+		return "🔄 Config reloaded, nothing changed", nil
+	}
+	var sb strings.Builder
+	sb.WriteString("🔄 Config reloaded\n")
+	for _, change := range changes {
+		fmt.Fprintf(&sb, "• %s\n", change)
+	}
+	return sb.String(), nil
+}
+
+// reloadConfig re-reads the config after its file changed, keeping the
+// current one when the new one is invalid.
+func (b *DiscordBot) reloadConfig() {
+	conf, err := configs.ReloadAppConfig()
+	if err != nil {
+		slog.Error("config reload failed, keeping the current config", "err", err)
+		return
+	}
+	if _, err := b.applyConfig(conf); err != nil {
+		slog.Error("config reload failed, keeping the current config", "err", err)
+	}
+}
+
+// applyConfig switches to the settings of conf that apply at runtime and
+// logs every change, those needing a restart included. A task schedule that
+// doesn't parse rejects the whole reload.
+func (b *DiscordBot) applyConfig(conf *configs.AppConfig) ([]configs.Change, error) {
+	schedules, err := taskSchedules(conf.Tasks)
+	if err != nil {
+		return nil, err
+	}
+	// !reload and the file watcher may both get here
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+	current := b.Config()
+	next, changes := current.Reload(conf)
+	b.config.Store(next)
+	logging.SetLevels(next.Log)
+	b.IntentService.SetModel(next.LLMModel)
+	b.IntentService.SetConfidenceThreshold(next.IntentConfidenceThreshold)
+	for _, task := range []struct {
+		name       string
+		prev, next configs.TaskSchedule
+	}{
+		{"cleanup", current.Tasks.Cleanup, next.Tasks.Cleanup},
+		{"notifications", current.Tasks.Notifications, next.Tasks.Notifications},
+		{"actions", current.Tasks.Actions, next.Tasks.Actions},
+		{"purge", current.Tasks.Purge, next.Tasks.Purge},
+	} {
+		if task.next != task.prev {
+			b.scheduler.Task(task.name).SetSchedule(schedules[task.name])
+		}
+	}
+	for _, change := range changes {
+		slog.Info("config changed", "key", change.Key, "from", change.From, "to", change.To, "restart", change.Restart)
+	}
+	return changes, nil
+}
+
+func (b *DiscordBot) cmdPause(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	names, err := b.selectTasks(args)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		b.scheduler.Task(name).Pause()
+	}
+	slog.InfoContext(ctx, "tasks paused", "tasks", names, "user_id", m.Author.ID)
+	return fmt.Sprintf("⏸️ Paused %s", strings.Join(names, ", ")), nil
+}
+
+func (b *DiscordBot) cmdResume(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	names, err := b.selectTasks(args)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		b.scheduler.Task(name).Resume()
+	}
+	slog.InfoContext(ctx, "tasks resumed", "tasks", names, "user_id", m.Author.ID)
+	return fmt.Sprintf("▶️ Resumed %s", strings.Join(names, ", ")), nil
+}
+
+// cmdSweep runs tasks now, paused ones included. Tasks already running are
+// left to finish.
+func (b *DiscordBot) cmdSweep(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	names, err := b.selectTasks(args)
+	if err != nil {
+		return "", err
+	}
+	var triggered, busy []string
+	for _, name := range names {
+		if b.scheduler.Task(name).Trigger() {
+			triggered = append(triggered, name)
+		} else {
+			busy = append(busy, name)
+		}
+	}
+	slog.InfoContext(ctx, "tasks triggered", "tasks", triggered, "busy", busy, "user_id", m.Author.ID)
+	var sb strings.Builder
+	if len(triggered) > 0 {
+		fmt.Fprintf(&sb, "🧹 Running %s\n", strings.Join(triggered, ", "))
+	}
+	if len(busy) > 0 {
+		fmt.Fprintf(&sb, "⏳ Already running: %s\n", strings.Join(busy, ", "))
+	}
+	return sb.String(), nil
+}
+
+// selectTasks resolves task names from args, where "all" or no args
+// selects every task.
+func (b *DiscordBot) selectTasks(args []string) ([]string, error) {
+	all := b.scheduler.Names()
+	if len(args) == 0 || slices.Contains(args, "all") {
+		return all, nil
+	}
+	for _, name := range args {
+		if !slices.Contains(all, name) {
+			return nil, fmt.Errorf("unknown task `%s`, available: %s", name, strings.Join(all, ", "))
+		}
+	}
+	return args, nil
+}
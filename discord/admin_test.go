@@ -0,0 +1,118 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// TestApplyConfigWhileHandling reloads the config while handlers read it,
+// run with -race to catch unsynchronized access.
+func TestApplyConfigWhileHandling(t *testing.T) {
+	b, _ := newTestBot(t)
+	ctx := context.Background()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_ = b.Config().MessageTTL(configs.MessageKinds.Reply)
+				if err := b.IntentService.Health(ctx); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+
+	var changes []configs.Change
+	for i := range 50 {
+		next := *b.Config()
+		next.LLMModel = fmt.Sprintf("model-%d", i)
+		next.IntentConfidenceThreshold = float64(i) / 100
+		var err error
+		if changes, err = b.applyConfig(&next); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if conf := b.Config(); conf.LLMModel != "model-49" || conf.IntentConfidenceThreshold != 0.49 {
+		t.Errorf("config after reloads: model %s, threshold %v", conf.LLMModel, conf.IntentConfidenceThreshold)
+	}
+	if len(changes) != 2 {
+		t.Errorf("last reload reported %v, want the model and threshold", changes)
+	}
+}
+
+func TestApplyConfigRejectsBadSchedule(t *testing.T) {
+	b, _ := newTestBot(t)
+	next := *b.Config()
+	next.LLMModel = "other"
+	next.Tasks.Purge = configs.TaskSchedule{Schedule: "0 4 30 feb *"}
+	if _, err := b.applyConfig(&next); err == nil {
+		t.Fatal("a schedule that never matches was accepted")
+	}
+	if b.Config().LLMModel == "other" {
+		t.Error("the rejected reload was partly applied")
+	}
+}
+
+func TestBindingsStayInTheGuild(t *testing.T) {
+	b, session := newTestBot(t)
+	ctx := context.Background()
+	session.channels["2002"] = &discordgo.Channel{ID: "2002", GuildID: "guild-2"}
+	if err := b.Repos.ChannelBindings.BindChannel(ctx, database.BindChannelDto{
+		GuildId: "guild-2", ChannelId: "2002", Service: configs.ServiceNames.Scheduler,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m := &discordgo.MessageCreate{Message: messageOf(guildMeta("alice"), "")}
+	scheduler := configs.ServiceNames.Scheduler
+
+	if _, err := b.cmdBind(ctx, m, []string{"<#2002>", scheduler}); err == nil {
+		t.Error("bound a channel of another server")
+	}
+	if _, err := b.cmdUnbind(ctx, m, []string{"<#2002>"}); err == nil {
+		t.Error("unbound a channel of another server")
+	}
+	if _, err := b.cmdBind(ctx, m, []string{"<#9999>", scheduler}); err == nil {
+		t.Error("bound an unknown channel")
+	}
+	bound, err := b.Repos.ChannelBindings.GetGuildBindings(ctx, "guild-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bound) != 1 {
+		t.Errorf("guild-2 bindings = %+v, want the one it made", bound)
+	}
+
+	if _, err := b.cmdUnbind(ctx, m, []string{scheduler}); err != nil {
+		t.Fatalf("unbind in this server: %v", err)
+	}
+	if bound, _ := b.Repos.ChannelBindings.GetGuildBindings(ctx, testGuild); len(bound) != 0 {
+		t.Errorf("%s bindings = %+v, want none", testGuild, bound)
+	}
+}
+
+func TestStatusBeforeStart(t *testing.T) {
+	b, _ := newTestBot(t)
+	reply, err := b.cmdStatus(context.Background(), &discordgo.MessageCreate{Message: messageOf(guildMeta("alice"), "!status")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "gateway latency: unavailable") {
+		t.Errorf("reply = %q", reply)
+	}
+}
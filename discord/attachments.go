@@ -0,0 +1,145 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/filestore"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxAttachmentBytes       = 10 << 20
+	maxAttachmentsPerMessage = 5
+	// services only read attachments while handling the message, nothing
+	// refers to the stored files afterwards
+	attachmentRetention = 24 * time.Hour
+)
+
+// allowedAttachmentTypes lists media types services know how to consume.
+// Any image/* type is accepted on top of these.
+var allowedAttachmentTypes = []string{
+	"application/pdf",
+	"text/calendar",
+	"text/csv",
+}
+
+var attachmentClient = &http.Client{Timeout: 30 * time.Second}
+
+// checkAttachments holds the message attachments against the count, type
+// and size limits Discord reports, without downloading anything. It returns
+// the attachments that pass, the refs routing may see of them and why the
+// others were skipped so the user can be told.
+func checkAttachments(m *discordgo.MessageCreate) ([]*discordgo.MessageAttachment, []configs.AttachmentRef, []string) {
+	var accepted []*discordgo.MessageAttachment
+	var refs []configs.AttachmentRef
+	var rejected []string
+	for i, a := range m.Attachments {
+		if i >= maxAttachmentsPerMessage {
+			rejected = append(rejected, fmt.Sprintf("`%s`: only %d attachments per message are processed", a.Filename, maxAttachmentsPerMessage))
+			continue
+		}
+		ref, err := checkAttachment(a)
+		if err != nil {
+			slog.Warn("skipping attachment", "filename", a.Filename, "err", err)
+			rejected = append(rejected, fmt.Sprintf("`%s`: %s", a.Filename, err))
+			continue
+		}
+		accepted = append(accepted, a)
+		refs = append(refs, ref)
+	}
+	return accepted, refs, rejected
+}
+
+// checkAttachment returns a ref without hash or path for an attachment
+// within the limits.
+func checkAttachment(a *discordgo.MessageAttachment) (configs.AttachmentRef, error) {
+	contentType := attachmentContentType(a)
+	if !strings.HasPrefix(contentType, "image/") && !slices.Contains(allowedAttachmentTypes, contentType) {
+		return configs.AttachmentRef{}, fmt.Errorf("unsupported file type %q", contentType)
+	}
+	if a.Size > maxAttachmentBytes {
+		return configs.AttachmentRef{}, fmt.Errorf("file is larger than %d MB", maxAttachmentBytes>>20)
+	}
+	return configs.AttachmentRef{Filename: a.Filename, ContentType: contentType, Size: int64(a.Size)}, nil
+}
+
+// collectAttachments downloads attachments that passed checkAttachments into
+// the file store. It only runs once the message is routed and the user may
+// use the service, so nobody gets the bot to fetch files it won't use.
+func (b *DiscordBot) collectAttachments(attachments []*discordgo.MessageAttachment) ([]configs.AttachmentRef, []string) {
+	var refs []configs.AttachmentRef
+	var rejected []string
+	for _, a := range attachments {
+		ref, err := b.storeAttachment(a)
+		if err != nil {
+			slog.Warn("skipping attachment", "filename", a.Filename, "err", err)
+			rejected = append(rejected, fmt.Sprintf("`%s`: %s", a.Filename, err))
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rejected
+}
+
+func (b *DiscordBot) storeAttachment(a *discordgo.MessageAttachment) (configs.AttachmentRef, error) {
+	ref, err := checkAttachment(a)
+	if err != nil {
+		return configs.AttachmentRef{}, err
+	}
+
+	resp, err := attachmentClient.Get(a.URL)
+	if err != nil {
+		return configs.AttachmentRef{}, fmt.Errorf("download failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return configs.AttachmentRef{}, fmt.Errorf("download failed with status %s", resp.Status)
+	}
+
+	// the reported size can't be trusted, the download itself is capped
+	hash, size, err := b.Files.Put(resp.Body, maxAttachmentBytes)
+	if errors.Is(err, filestore.ErrTooLarge) {
+		return configs.AttachmentRef{}, fmt.Errorf("file is larger than %d MB", maxAttachmentBytes>>20)
+	}
+	if err != nil {
+		return configs.AttachmentRef{}, fmt.Errorf("could not store file")
+	}
+	ref.Hash, ref.Size, ref.Path = hash, size, b.Files.Path(hash)
+	return ref, nil
+}
+
+// pruneAttachments removes stored attachments older than the retention.
+func (b *DiscordBot) pruneAttachments(ctx context.Context) error {
+	removed, err := b.Files.Prune(time.Now().Add(-attachmentRetention))
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		slog.InfoContext(ctx, "pruned attachments", "count", removed)
+	}
+	return nil
+}
+
+// attachmentContentType normalizes Discord's content type (which may carry
+// parameters or be missing entirely) to a bare media type.
+func attachmentContentType(a *discordgo.MessageAttachment) string {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(filepath.Ext(a.Filename)))
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
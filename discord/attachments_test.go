@@ -0,0 +1,150 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// attachmentServer serves size bytes for every download and counts them.
+func attachmentServer(t *testing.T, size int) (string, *atomic.Int32) {
+	t.Helper()
+	var downloads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write([]byte(strings.Repeat("x", size)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &downloads
+}
+
+func TestCheckAttachments(t *testing.T) {
+	attachment := func(filename, contentType string, size int) *discordgo.MessageAttachment {
+		return &discordgo.MessageAttachment{Filename: filename, ContentType: contentType, Size: size}
+	}
+	tests := []struct {
+		name        string
+		attachment  *discordgo.MessageAttachment
+		contentType string // of the ref, empty when rejected
+		rejected    string
+	}{
+		{"image", attachment("receipt.jpg", "image/jpeg", 1<<20), "image/jpeg", ""},
+		{"parameters dropped", attachment("list.csv", "text/csv; charset=utf-8", 100), "text/csv", ""},
+		{"type from the extension", attachment("menu.PDF", "", 100), "application/pdf", ""},
+		{"unsupported type", attachment("photos.zip", "application/zip", 100), "", "`photos.zip`: unsupported file type \"application/zip\""},
+		{"unknown type", attachment("notes", "", 100), "", "`notes`: unsupported file type \"application/octet-stream\""},
+		{"too large", attachment("scan.png", "image/png", maxAttachmentBytes+1), "", "`scan.png`: file is larger than 10 MB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{tt.attachment}}}
+			accepted, refs, rejected := checkAttachments(m)
+			if tt.rejected != "" {
+				if len(accepted) != 0 || len(refs) != 0 || len(rejected) != 1 || rejected[0] != tt.rejected {
+					t.Errorf("checkAttachments = %v, %v, %q, want %q", accepted, refs, rejected, tt.rejected)
+				}
+				return
+			}
+			if len(accepted) != 1 || len(rejected) != 0 {
+				t.Fatalf("checkAttachments = %v, %q", accepted, rejected)
+			}
+			want := configs.AttachmentRef{Filename: tt.attachment.Filename, ContentType: tt.contentType, Size: int64(tt.attachment.Size)}
+			if refs[0] != want {
+				t.Errorf("ref = %+v, want %+v", refs[0], want)
+			}
+		})
+	}
+}
+
+func TestCheckAttachmentsPerMessage(t *testing.T) {
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{}}
+	for n := range maxAttachmentsPerMessage + 2 {
+		m.Attachments = append(m.Attachments, &discordgo.MessageAttachment{Filename: fmt.Sprintf("%d.png", n), ContentType: "image/png"})
+	}
+	accepted, _, rejected := checkAttachments(m)
+	if len(accepted) != maxAttachmentsPerMessage || len(rejected) != 2 {
+		t.Errorf("accepted %d and rejected %d, want %d and 2", len(accepted), len(rejected), maxAttachmentsPerMessage)
+	}
+}
+
+func TestStoreAttachment(t *testing.T) {
+	b, _ := newTestBot(t)
+	url, _ := attachmentServer(t, 1<<10)
+
+	ref, err := b.storeAttachment(&discordgo.MessageAttachment{Filename: "receipt.png", ContentType: "image/png", Size: 1 << 10, URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash == "" || ref.Size != 1<<10 || ref.Path != b.Files.Path(ref.Hash) {
+		t.Errorf("ref = %+v", ref)
+	}
+	if _, err := os.Stat(ref.Path); err != nil {
+		t.Errorf("not stored: %v", err)
+	}
+}
+
+func TestStoreAttachmentCapsTheDownload(t *testing.T) {
+	b, _ := newTestBot(t)
+	url, _ := attachmentServer(t, maxAttachmentBytes+1)
+
+	// Discord said it was small
+	_, err := b.storeAttachment(&discordgo.MessageAttachment{Filename: "receipt.png", ContentType: "image/png", Size: 1 << 10, URL: url})
+	if err == nil || err.Error() != "file is larger than 10 MB" {
+		t.Errorf("err = %v, want the file rejected as too large", err)
+	}
+}
+
+func TestAttachmentsDownloadedOnceAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		channelId string
+		guildId   string
+		content   string
+		denied    bool
+		downloads int32
+	}{
+		{name: "routed and allowed", channelId: testChannel, guildId: testGuild, downloads: 1},
+		{name: "not allowed", channelId: testChannel, guildId: testGuild, denied: true},
+		{name: "not routed", channelId: "dm-alice", content: "what do you think?"},
+		{name: "not addressed", channelId: "general", guildId: testGuild, content: "look at this"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, session := newTestBot(t)
+			session.perms["alice"] = 0
+			if tt.denied {
+				err := b.Repos.Permissions.SetPermission(context.Background(), database.SetPermissionDto{
+					GuildId: testGuild, SubjectType: "user", SubjectId: "alice",
+					Service: configs.ServiceNames.Scheduler, Action: "*", Effect: "deny",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			url, downloads := attachmentServer(t, 1<<10)
+
+			b.onMessageCreate(nil, &discordgo.MessageCreate{Message: &discordgo.Message{
+				ID:        "upload",
+				ChannelID: tt.channelId,
+				GuildID:   tt.guildId,
+				Content:   tt.content,
+				Author:    &discordgo.User{ID: "alice", Username: "alice"},
+				Attachments: []*discordgo.MessageAttachment{
+					{Filename: "flyer.png", ContentType: "image/png", Size: 1 << 10, URL: url},
+				},
+			}})
+			if n := downloads.Load(); n != tt.downloads {
+				t.Errorf("downloaded %d times, want %d", n, tt.downloads)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// BackupDatabase backs the database up on schedule, keeping the configured
+// number of backups.
+func (b *DiscordBot) BackupDatabase(ctx context.Context) error {
+	conf := b.Config().Database
+	path, err := b.Database.Backup(conf.BackupDir)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	slog.InfoContext(ctx, "database backed up", "path", path)
+	deleted, err := b.Database.PruneBackups(conf.BackupDir, conf.BackupRetention)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to prune backups", "err", err)
+	}
+	for _, old := range deleted {
+		slog.InfoContext(ctx, "deleted old backup", "path", old)
+	}
+	return nil
+}
+
+// cmdBackup sends a fresh backup to the owner. It goes by DM wherever the
+// command was sent, the database holds every server's data.
+func (b *DiscordBot) cmdBackup(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	conf := b.Config().Database
+	path, err := b.Database.Backup(conf.BackupDir)
+	if err != nil {
+		return "", err
+	}
+	if _, err := b.Database.PruneBackups(conf.BackupDir, conf.BackupRetention); err != nil {
+		slog.ErrorContext(ctx, "failed to prune backups", "err", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %s", err)
+	}
+	if info.Size() > maxAttachmentBytes {
+		return fmt.Sprintf("📦 Backed up to `%s`, it is too large to upload (%d MB)", path, info.Size()>>20), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %s", err)
+	}
+	defer file.Close()
+
+	channel, err := b.Session.UserChannelCreate(m.Author.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to open DM: %s", err)
+	}
+	_, err = b.Session.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("📦 Database backup, also kept at `%s`", path),
+		Files: []*discordgo.File{{
+			Name:        filepath.Base(path),
+			ContentType: "application/vnd.sqlite3",
+			Reader:      file,
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload backup: %s", err)
+	}
+	if m.GuildID == "" {
+		return "", nil
+	}
+	return "📦 Backup sent by DM", nil
+}
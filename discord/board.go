@@ -0,0 +1,296 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+const (
+	boardPageSize = 10
+	boardColor    = 0x5865F2
+	// Discord limits embed field values to 1024 characters
+	embedFieldLimit = 1024
+)
+
+// boardViews are the views a pinned board can show, "mine" is only offered
+// in the personal copies users open with the board buttons.
+var boardViews = []string{"all", "week", "today"}
+
+// updateNotifications refreshes the notification board of every channel
+// bound to the scheduler, each showing only its own guild's notifications.
+func (b *DiscordBot) updateNotifications(ctx context.Context) {
+	bindings, err := b.Repos.ChannelBindings.GetServiceBindings(ctx, configs.ServiceNames.Scheduler)
+	if err != nil {
+		slog.ErrorContext(ctx, "getting scheduler channels failed", "err", err)
+		return
+	}
+	byGuild := make(map[string][]models.Notification)
+	for _, binding := range bindings {
+		notifications, ok := byGuild[binding.GuildId]
+		if !ok {
+			notifications, err = b.Repos.Notifications.GetPublicNotifications(ctx, binding.GuildId)
+			if err != nil {
+				slog.ErrorContext(ctx, "getting guild notifications failed", "guild_id", binding.GuildId, "err", err)
+				continue
+			}
+			byGuild[binding.GuildId] = notifications
+		}
+		b.updateNotificationBoard(ctx, binding, notifications, b.headcounts(ctx, notifications))
+	}
+}
+
+// updateNotificationBoard edits the board message of a channel, posting and
+// pinning a new one when there is none yet or it was deleted.
+func (b *DiscordBot) updateNotificationBoard(ctx context.Context, binding models.ChannelBinding, notifications []models.Notification, headcounts map[uuid.UUID]int) {
+	board, err := b.Repos.Boards.GetBoard(ctx, binding.ChannelId, binding.Service)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load notification board", "err", err)
+		return
+	}
+	loc := guildLocation(b.guildSettings(binding.GuildId))
+
+	if board != nil && board.MessageId != "" {
+		embed, components := renderBoard(board, notifications, headcounts, loc, "")
+		_, err := b.Session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         board.MessageId,
+			Channel:    board.ChannelId,
+			Embeds:     &[]*discordgo.MessageEmbed{embed},
+			Components: &components,
+		})
+		if err == nil {
+			return
+		}
+		if !isUnknownMessage(err) {
+			slog.ErrorContext(ctx, "failed to edit notification board", "err", err)
+			return
+		}
+		slog.InfoContext(ctx, "notification board was deleted, posting a new one", "channel_id", binding.ChannelId)
+	}
+
+	// the board is saved first, its buttons need the board id
+	data := database.SaveBoardDto{
+		GuildId:   binding.GuildId,
+		ChannelId: binding.ChannelId,
+		Service:   binding.Service,
+	}
+	if board, err = b.Repos.Boards.SaveBoard(ctx, data); err != nil {
+		slog.ErrorContext(ctx, "failed to save notification board", "err", err)
+		return
+	}
+	embed, components := renderBoard(board, notifications, headcounts, loc, "")
+	msg, err := b.Session.ChannelMessageSendComplex(binding.ChannelId, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send notification board", "err", err)
+		return
+	}
+	data.MessageId = msg.ID
+	if _, err := b.Repos.Boards.SaveBoard(ctx, data); err != nil {
+		slog.ErrorContext(ctx, "failed to save notification board", "err", err)
+	}
+	if err := b.Session.ChannelMessagePin(binding.ChannelId, msg.ID); err != nil {
+		slog.ErrorContext(ctx, "failed to pin notification board", "err", err)
+	}
+}
+
+// onBoardComponent handles the board buttons, args are the board id and
+// either "page" with the view being paged and the page to show, or "view"
+// with the view to switch to. The pinned board is shared, pressing its
+// buttons opens a copy only the user sees, whose buttons then update that
+// copy. The copy's view only lives in its buttons, so paging carries it.
+func (b *DiscordBot) onBoardComponent(ctx context.Context, i *discordgo.InteractionCreate, args []string) (*discordgo.InteractionResponse, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("malformed board button")
+	}
+	boardId, err := uuid.Parse(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed board button")
+	}
+	board, err := b.Repos.Boards.GetBoardById(ctx, boardId)
+	if err != nil {
+		return nil, fmt.Errorf("this board no longer exists")
+	}
+
+	switch {
+	case args[1] == "page" && len(args) == 4:
+		board.View = args[2]
+		board.Page, _ = strconv.Atoi(args[3])
+	case args[1] == "view" && len(args) == 3:
+		board.View, board.Page = args[2], 0
+	default:
+		return nil, fmt.Errorf("malformed board button")
+	}
+	if board.View != "mine" && !slices.Contains(boardViews, board.View) {
+		return nil, fmt.Errorf("malformed board button")
+	}
+
+	notifications, err := b.Repos.Notifications.GetPublicNotifications(ctx, board.GuildId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notifications: %s", err)
+	}
+	embed, components := renderBoard(board, notifications, b.headcounts(ctx, notifications), guildLocation(b.guildSettings(board.GuildId)), interactionUserId(i))
+
+	respType := discordgo.InteractionResponseUpdateMessage
+	if i.Message == nil || i.Message.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		respType = discordgo.InteractionResponseChannelMessageWithSource
+	}
+	return &discordgo.InteractionResponse{
+		Type: respType,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+			Flags:      discordgo.MessageFlagsEphemeral,
+		},
+	}, nil
+}
+
+func (b *DiscordBot) cmdBoard(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	if len(args) != 1 || !slices.Contains(boardViews, args[0]) {
+		return "", fmt.Errorf("usage: `!board %s`", strings.Join(boardViews, "|"))
+	}
+	board, err := b.Repos.Boards.GetBoard(ctx, m.ChannelID, configs.ServiceNames.Scheduler)
+	if err != nil || board == nil {
+		return "", fmt.Errorf("this channel has no notification board, bind it with `!bind scheduler`")
+	}
+	if err := b.Repos.Boards.SetBoardView(ctx, board.ID, args[0], 0); err != nil {
+		return "", fmt.Errorf("failed to update board: %s", err)
+	}
+	b.updateNotifications(ctx)
+	return fmt.Sprintf("📌 Board now shows %s", strings.ToLower(viewLabel(args[0]))), nil
+}
+
+// renderBoard renders one page of a board's view, notifications grouped
+// into a field per day, followed by paging and view buttons. userId is set
+// when rendering a user's personal copy. Events show their headcount.
+func renderBoard(board *models.Board, notifications []models.Notification, headcounts map[uuid.UUID]int, loc *time.Location, userId string) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	visible := filterView(notifications, board.View, time.Now().In(loc), userId)
+	pages := max(1, (len(visible)+boardPageSize-1)/boardPageSize)
+	page := min(max(board.Page, 0), pages-1)
+	visible = visible[page*boardPageSize : min(len(visible), (page+1)*boardPageSize)]
+
+	embed := &discordgo.MessageEmbed{
+		Title:     "📅 Upcoming Notifications — " + viewLabel(board.View),
+		Color:     boardColor,
+		Footer:    &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d/%d · %s", page+1, pages, loc)},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if len(visible) == 0 {
+		embed.Description = "📭 No upcoming notifications."
+	}
+	for _, n := range visible {
+		at := n.NotifyAt.In(loc)
+		day := at.Format("Mon, Jan 02")
+		title := n.Title
+		if n.IsEvent {
+			title += fmt.Sprintf(" 👥 %d", headcounts[n.ID])
+			if n.Capacity > 0 {
+				title += fmt.Sprintf("/%d", n.Capacity)
+			}
+		}
+		line := fmt.Sprintf("`%s` **%s** `[id:%s]`\n%s\n", at.Format("15:04"), title, n.ID, n.Message)
+		if last := len(embed.Fields) - 1; last >= 0 && embed.Fields[last].Name == day &&
+			len(embed.Fields[last].Value)+len(line) <= embedFieldLimit {
+			embed.Fields[last].Value += line
+			continue
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: day, Value: truncate(line, embedFieldLimit)})
+	}
+
+	// a row holds at most 5 buttons, the views get their own
+	pageId := func(page int) string {
+		return fmt.Sprintf("board:%s:page:%s:%d", board.ID, board.View, page)
+	}
+	paging := discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: "◀", Style: discordgo.SecondaryButton, CustomID: pageId(page - 1), Disabled: page == 0},
+		discordgo.Button{Label: "▶", Style: discordgo.SecondaryButton, CustomID: pageId(page + 1), Disabled: page >= pages-1},
+	}}
+	views := boardViews
+	if userId != "" {
+		views = append(slices.Clone(views), "mine")
+	}
+	var viewRow discordgo.ActionsRow
+	for _, view := range views {
+		style := discordgo.SecondaryButton
+		if view == board.View {
+			style = discordgo.PrimaryButton
+		}
+		viewRow.Components = append(viewRow.Components, discordgo.Button{
+			Label:    viewLabel(view),
+			Style:    style,
+			CustomID: fmt.Sprintf("board:%s:view:%s", board.ID, view),
+		})
+	}
+	return embed, []discordgo.MessageComponent{paging, viewRow}
+}
+
+func filterView(notifications []models.Notification, view string, now time.Time, userId string) []models.Notification {
+	var until time.Time
+	switch view {
+	case "mine":
+		var mine []models.Notification
+		for _, n := range notifications {
+			if n.UserId == userId {
+				mine = append(mine, n)
+			}
+		}
+		return mine
+	case "today":
+		until = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	case "week":
+		until = now.AddDate(0, 0, 7)
+	default:
+		return notifications
+	}
+	var filtered []models.Notification
+	for _, n := range notifications {
+		if n.NotifyAt.Before(until) {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
+
+func viewLabel(view string) string {
+	switch view {
+	case "today":
+		return "Today"
+	case "week":
+		return "Next 7 days"
+	case "mine":
+		return "Mine"
+	}
+	return "All"
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit-len("…")], "") + "…"
+}
+
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	if restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage {
+		return true
+	}
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,98 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// buttons returns the custom ids of the buttons in each row.
+func buttons(t *testing.T, components []discordgo.MessageComponent) [][]string {
+	t.Helper()
+	var rows [][]string
+	for _, component := range components {
+		row, ok := component.(discordgo.ActionsRow)
+		if !ok {
+			t.Fatalf("component %T is not an actions row", component)
+		}
+		var ids []string
+		for _, c := range row.Components {
+			ids = append(ids, c.(discordgo.Button).CustomID)
+		}
+		rows = append(rows, ids)
+	}
+	return rows
+}
+
+func pressBoard(t *testing.T, b *DiscordBot, customId, userId string, ephemeral bool) *discordgo.InteractionResponse {
+	t.Helper()
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:    discordgo.InteractionMessageComponent,
+		GuildID: testGuild,
+		Member:  &discordgo.Member{User: &discordgo.User{ID: userId}},
+		Message: &discordgo.Message{},
+	}}
+	if ephemeral {
+		i.Message.Flags = discordgo.MessageFlagsEphemeral
+	}
+	parts := strings.Split(customId, ":")
+	resp, err := b.onBoardComponent(context.Background(), i, parts[1:])
+	if err != nil {
+		t.Fatalf("pressing %s: %v", customId, err)
+	}
+	return resp
+}
+
+func TestBoardPagingKeepsView(t *testing.T) {
+	b, _ := newTestBot(t)
+	ctx := context.Background()
+	for i := range 40 {
+		userId := "alice"
+		if i%2 == 0 {
+			userId = "bob"
+		}
+		_, err := b.Repos.Notifications.AddNotification(ctx, database.AddNotificationDto{
+			Service: configs.ServiceNames.Scheduler, NotifyAt: time.Now().Add(time.Duration(i+1) * time.Hour),
+			Title: "n", GuildId: testGuild, UserId: userId,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	board, err := b.Repos.Boards.SaveBoard(ctx, database.SaveBoardDto{GuildId: testGuild, ChannelId: testChannel, Service: configs.ServiceNames.Scheduler, MessageId: "pinned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pressing "mine" on the pinned board opens bob's copy
+	resp := pressBoard(t, b, "board:"+board.ID.String()+":view:mine", "bob", false)
+	rows := buttons(t, resp.Data.Components)
+	for _, row := range rows {
+		if len(row) > 5 {
+			t.Errorf("row with %d buttons: %v", len(row), row)
+		}
+	}
+	if len(rows) != 2 || len(rows[1]) != 4 {
+		t.Fatalf("rows = %v, want paging and 4 views", rows)
+	}
+	if footer := resp.Data.Embeds[0].Footer.Text; !strings.HasPrefix(footer, "Page 1/2") {
+		t.Errorf("footer = %q, want page 1 of bob's 2", footer)
+	}
+
+	next := rows[0][1]
+	resp = pressBoard(t, b, next, "bob", true)
+	if resp.Type != discordgo.InteractionResponseUpdateMessage {
+		t.Errorf("paging the copy sent a new message")
+	}
+	if title := resp.Data.Embeds[0].Title; !strings.HasSuffix(title, viewLabel("mine")) {
+		t.Errorf("title after paging = %q, want the mine view", title)
+	}
+	if footer := resp.Data.Embeds[0].Footer.Text; !strings.HasPrefix(footer, "Page 2/2") {
+		t.Errorf("footer after paging = %q", footer)
+	}
+}
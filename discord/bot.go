@@ -3,17 +3,20 @@ package discord
 import (
 	"biyobot/configs"
 	"biyobot/llm"
+	"biyobot/logging"
+	"biyobot/metrics"
 	"biyobot/models"
 	"biyobot/services"
 	"biyobot/services/database"
+	"biyobot/services/filestore"
+	"biyobot/services/scheduler"
 	"biyobot/utils"
 	"context"
 	"fmt"
-	"log"
-	"os"
-	"os/signal"
+	"log/slog"
 	"strings"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -21,143 +24,168 @@ import (
 )
 
 type DiscordBot struct {
-	Session            *discordgo.Session
-	AppConfig          *configs.AppConfig
-	Services           *services.Registry
-	IntentService      *llm.IntentService
-	DiscordMessageRepo *database.DiscordMessageRepo
-	NotificationsRepo  *database.NotificationsRepo
+	Session Session
+	// the gateway's cache of guilds, channels, roles and the bot's own user
+	State         *discordgo.State
+	Services      *services.Registry
+	IntentService *llm.IntentService
+	Repos         *database.Repos
+	Database      Database
+	Files         *filestore.FileStore
+
+	// the gateway connection, nil when the bot isn't connected like in tests
+	gateway Gateway
+
+	// swapped as a whole on reload, see Config and applyConfig
+	config   atomic.Pointer[configs.AppConfig]
+	reloadMu sync.Mutex
+
+	startedAt time.Time
+	// runs the background tasks, managed with !pause, !resume and !sweep
+	scheduler *scheduler.Scheduler
+
+	// in-flight message and interaction handlers, see startHandling
+	inflight  sync.WaitGroup
+	closingMu sync.Mutex
+	closing   bool
+	// the context handlers run in, cancelled when they take too long to
+	// finish on shutdown
+	handlerCtx     context.Context
+	cancelHandlers context.CancelFunc
 }
 
-func NewDiscordBot(conf *configs.AppConfig, services *services.Registry, intentService *llm.IntentService, messageRepo *database.DiscordMessageRepo, notifyRepo *database.NotificationsRepo) *DiscordBot {
+func NewDiscordBot(conf *configs.AppConfig, services *services.Registry, intentService *llm.IntentService, repos *database.Repos, db Database, files *filestore.FileStore) *DiscordBot {
 	session, err := discordgo.New("Bot " + conf.DiscordToken)
 	if err != nil {
-		log.Fatal("Error creating Discord session:", err)
+		logging.Fatal("error creating Discord session", "err", err)
 	}
-	return &DiscordBot{
-		Session:            session,
-		AppConfig:          conf,
-		Services:           services,
-		IntentService:      intentService,
-		DiscordMessageRepo: messageRepo,
-		NotificationsRepo:  notifyRepo,
+	session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent | discordgo.IntentsDirectMessages
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	b := &DiscordBot{
+		Session:        session,
+		State:          session.State,
+		Services:       services,
+		IntentService:  intentService,
+		Repos:          repos,
+		Database:       db,
+		Files:          files,
+		gateway:        discordGateway{session},
+		handlerCtx:     handlerCtx,
+		cancelHandlers: cancelHandlers,
 	}
+	b.config.Store(conf)
+	return b
 }
-func (b *DiscordBot) Start(ctx context.Context) {
-	// discord bot client
-	b.Session.AddHandler(b.onReady)
-	b.Session.AddHandler(b.onMessageCreate)
-	b.Session.AddHandler(b.onGuildCreate)
-
-	b.Session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent | discordgo.IntentsDirectMessages
-
-	err := b.Session.Open()
-	if err != nil {
-		log.Println("Error opening connection:", err)
-		os.Exit(1)
-	}
-	defer b.Session.Close()
 
-	// start background tasks
-	fmt.Println("Starting bot background tasks")
-	services.StartBackgroundTask(ctx, 180, b.DeleteExpiredMessages)
-	services.StartBackgroundTask(ctx, 60, b.HandleNotificationDm)
-
-	fmt.Println("Bot is running. Press Ctrl+C to exit.")
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+// Config returns the current settings. A reload swaps in a new config
+// rather than changing this one, so callers can keep what they got.
+func (b *DiscordBot) Config() *configs.AppConfig {
+	return b.config.Load()
 }
 
-func (b *DiscordBot) DeleteExpiredMessages(ctx context.Context) {
-	log.Println("Running background task: deleting expired messages")
-	expiredMessages, err := b.DiscordMessageRepo.GetAllExpiredMessages()
+// Start runs the bot until ctx is done, then shuts it down, see shutdown.
+func (b *DiscordBot) Start(ctx context.Context) {
+	// discord bot client
+	b.gateway.AddHandler(b.onReady)
+	b.gateway.AddHandler(b.onMessageCreate)
+	b.gateway.AddHandler(b.onGuildCreate)
+	b.gateway.AddHandler(b.onInteractionCreate)
+
+	// the tasks and the metrics server outlive ctx, shutdown stops them
+	runCtx, stopRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopRun()
+	// before the gateway opens, so task commands find it
+	b.scheduler = scheduler.New(runCtx, b.Repos.TaskRuns)
+
+	// loading the config checked these, a bad one never gets this far
+	schedules, err := taskSchedules(b.Config().Tasks)
 	if err != nil {
-		log.Println("Getting expired messages failed")
-		return
+		logging.Fatal("invalid task schedule", "err", err)
 	}
-	var ids []uuid.UUID
-	for _, e := range expiredMessages {
-		b.Session.ChannelMessageDelete(e.ChannelId, e.MessageId)
-		ids = append(ids, e.ID)
+
+	if err := b.gateway.Open(); err != nil {
+		logging.Fatal("error opening connection", "err", err)
 	}
-	if len(ids) != 0 {
-		err := b.DiscordMessageRepo.DeleteMessageBatch(ids)
-		if err != nil {
-			log.Println("Getting expired messages failed")
-			return
-		}
+
+	if addr := b.Config().MetricsAddr; addr != "" {
+		go b.serveMetrics(runCtx, addr)
 	}
-}
 
-func (b *DiscordBot) HandleNotificationDm(ctx context.Context) {
-	log.Println("Running background task: dming notifications")
-	expiredNotifications, err := b.NotificationsRepo.GetAllExpiredNotifications()
-	if err != nil {
-		log.Println("Discord bot failed to get expired notifications")
-		return
+	// start background tasks
+	slog.Info("starting bot background tasks")
+	b.startedAt = time.Now()
+	for _, task := range []struct {
+		name string
+		fn   scheduler.Func
+	}{
+		{"cleanup", b.DeleteExpiredMessages},
+		{"notifications", b.DeliverNotifications},
+		{"actions", b.ExecuteScheduledActions},
+		// skips runs while the retention is 0, it can be set on reload
+		{"purge", b.PurgeDeleted},
+	} {
+		b.scheduler.Add(task.name, schedules[task.name], task.fn)
 	}
-	if len(expiredNotifications) == 0 {
-		return
+	if interval := b.Config().Database.BackupInterval; interval > 0 && b.Database.Dialect() == "sqlite" {
+		b.scheduler.Add("backup", scheduler.Every(interval, 0), b.BackupDatabase)
 	}
-	var ids []uuid.UUID
-	for _, n := range expiredNotifications {
-		metadata, err := utils.JsonToStruct[configs.DiscordMetadata](n.Metadata)
-		if err != nil {
-			log.Printf("failed to parse metadata for notification %s: %v", n.ID, err)
-			continue
-		}
+	go configs.WatchFile(ctx, b.Config().File, 5*time.Second, b.reloadConfig)
 
-		channel, err := b.Session.UserChannelCreate(metadata.UserId)
-		if err != nil {
-			log.Printf("failed to create DM channel for user %s: %v", metadata.UserId, err)
-			continue
-		}
-
-		content := fmt.Sprintf(
-			"🔔 **%s**\n\n%s\n\n⏰ Scheduled for: %s",
-			n.Title,
-			n.Message,
-			n.NotifyAt.Format("Jan 02, 2006 15:04 MST"),
-		)
+	slog.Info("bot is running, press Ctrl+C to exit")
+	<-ctx.Done()
+	b.shutdown()
+}
 
-		sentMsg, err := b.Session.ChannelMessageSend(channel.ID, content)
+// taskSchedules parses the configured schedule of each task, by task name.
+func taskSchedules(tasks configs.TaskSchedules) (map[string]scheduler.Schedule, error) {
+	schedules := make(map[string]scheduler.Schedule)
+	for _, task := range []struct {
+		name     string
+		schedule configs.TaskSchedule
+	}{
+		{"cleanup", tasks.Cleanup},
+		{"notifications", tasks.Notifications},
+		{"actions", tasks.Actions},
+		{"purge", tasks.Purge},
+	} {
+		schedule, err := scheduler.Parse(task.schedule.Schedule, task.schedule.Jitter)
 		if err != nil {
-			log.Printf("failed to send DM to user %s: %v", metadata.UserId, err)
-			continue
-		}
-		b.tagMessageToBeDeleted(sentMsg, 86400)
-
-		ids = append(ids, n.ID)
-	}
-
-	if len(ids) > 0 {
-		if err := b.NotificationsRepo.DeleteNotificationBatch(ids); err != nil {
-			log.Printf("failed to delete processed notifications: %v", err)
+			return nil, fmt.Errorf("tasks.%s: %w", task.name, err)
 		}
+		schedules[task.name] = schedule
 	}
+	return schedules, nil
 }
 
-func (b *DiscordBot) tagMessageToBeDeleted(msg *discordgo.Message, secondsTillDelete int) error {
-	log.Println("Tagging a message to be deleted")
-	_, err := b.DiscordMessageRepo.AddMessage(database.AddDiscordMessageDto{
-		Action:          "delete",
-		ChannelId:       msg.ChannelID,
-		UserId:          msg.Author.ID,
-		MessageId:       msg.ID,
-		Content:         msg.Content,
-		ExecuteActionOn: utils.JapanTimeNow().Add(time.Duration(secondsTillDelete) * time.Second),
-	})
-	return err
+// tagRequestToBeDeleted expires a user's message once it has been handled.
+// Bots can't delete other users' messages in DMs, so those are kept.
+func (b *DiscordBot) tagRequestToBeDeleted(msg *discordgo.Message) {
+	if msg.GuildID == "" {
+		return
+	}
+	if err := b.tagMessageToBeDeleted(msg, configs.MessageKinds.Request); err != nil {
+		slog.Error("failed to tag message for deletion", "err", err)
+	}
 }
 
-// handles notifications service
-func (b *DiscordBot) handleNotifications(intent *llm.IntentResult, discordMeta *configs.DiscordMetadata) error {
+// handles notifications service, m is the message the intent came from
+func (b *DiscordBot) handleNotifications(ctx context.Context, intent *llm.IntentResult, discordMeta *configs.DiscordMetadata, m *discordgo.Message) error {
 	metadata, err := utils.StructToJson(discordMeta)
 	if err != nil {
 		return fmt.Errorf("failed to serialize discord metadata: %s", err)
 	}
+	targets, err := b.parseTargets(m)
+	if err != nil {
+		return err
+	}
+	var targetsJson string
+	if len(targets) > 0 {
+		if targetsJson, err = utils.StructToJson(targets); err != nil {
+			return fmt.Errorf("failed to serialize targets: %s", err)
+		}
+	}
 
+	loc := guildLocation(b.guildSettings(discordMeta.GuildId))
 	var replyContent string
 	switch intent.Action {
 	case "add":
@@ -166,116 +194,167 @@ func (b *DiscordBot) handleNotifications(intent *llm.IntentResult, discordMeta *
 			return fmt.Errorf("failed to parse notify_at: %s", err)
 		}
 		title := utils.ParamString(intent.Params, "title")
-		_, err = b.NotificationsRepo.AddNotification(database.AddNotificationDto{
+		notification, err := b.Repos.Notifications.AddNotification(ctx, database.AddNotificationDto{
 			Service:  "scheduler",
 			Metadata: metadata,
 			NotifyAt: notifyAt,
 			Title:    title,
 			Message:  utils.ParamString(intent.Params, "description"),
+			GuildId:  discordMeta.GuildId,
+			UserId:   discordMeta.UserId,
+			Private:  discordMeta.IsDM(),
+			Targets:  targetsJson,
+			IsEvent:  utils.ParamBool(intent.Params, "is_event") && !discordMeta.IsDM(),
+			Capacity: max(0, utils.ParamInt(intent.Params, "capacity")),
+			Actor:    actorOf(discordMeta),
 		})
 		if err != nil {
 			return fmt.Errorf("failed to add notification: %s", err)
 		}
-		replyContent = fmt.Sprintf("✅ Scheduled **%s** for %s", title, notifyAt.Format("Jan 02, 2006 15:04 MST"))
+		replyContent = fmt.Sprintf("✅ Scheduled **%s** for %s", title, notifyAt.In(loc).Format("Jan 02, 2006 15:04 MST"))
+		if notification.IsEvent {
+			if err := b.postRsvp(ctx, notification, rsvpChannel(targets, discordMeta)); err != nil {
+				slog.ErrorContext(ctx, "failed to post RSVP", "notification_id", notification.ID, "err", err)
+			}
+			replyContent = fmt.Sprintf("🎟️ Event **%s** on %s, RSVP with the buttons", title, notifyAt.In(loc).Format("Jan 02, 2006 15:04 MST"))
+		}
+		b.scheduleAnnouncements(ctx, notification, discordMeta)
+		if len(targets) > 0 {
+			replyContent += " → " + formatTargets(targets)
+		}
 	case "edit":
+		if err := b.checkNotificationAccess(ctx, utils.ParamString(intent.Params, "notification_id"), discordMeta); err != nil {
+			return err
+		}
 		notifyAt, err := time.Parse(time.RFC3339, utils.ParamString(intent.Params, "notify_at"))
 		if err != nil {
 			return fmt.Errorf("failed to parse notify_at: %s", err)
 		}
 		title := utils.ParamString(intent.Params, "title")
-		_, err = b.NotificationsRepo.EditNotification(database.EditNotificationDto{
+		notification, err := b.Repos.Notifications.EditNotification(ctx, database.EditNotificationDto{
 			ID:       utils.ParamString(intent.Params, "notification_id"),
 			Service:  "scheduler",
 			Metadata: metadata,
 			NotifyAt: notifyAt,
 			Title:    title,
 			Message:  utils.ParamString(intent.Params, "description"),
+			Targets:  targetsJson,
+			Actor:    actorOf(discordMeta),
 		})
 		if err != nil {
 			return fmt.Errorf("failed to edit notification: %s", err)
 		}
-		replyContent = fmt.Sprintf("✏️ Updated **%s** to %s", title, notifyAt.Format("Jan 02, 2006 15:04 MST"))
+		b.scheduleAnnouncements(ctx, notification, discordMeta)
+		replyContent = fmt.Sprintf("✏️ Updated **%s** to %s", title, notifyAt.In(loc).Format("Jan 02, 2006 15:04 MST"))
 	case "delete":
 		notificationId, err := uuid.Parse(utils.ParamString(intent.Params, "notification_id"))
 		if err != nil {
 			return fmt.Errorf("failed to parse notification_id: %s", err)
 		}
-		err = b.NotificationsRepo.DeleteNotification(notificationId)
+		if err := b.checkNotificationAccess(ctx, notificationId.String(), discordMeta); err != nil {
+			return err
+		}
+		err = b.Repos.Notifications.DeleteNotification(ctx, notificationId, actorOf(discordMeta))
 		if err != nil {
 			return fmt.Errorf("failed to delete notification: %s", err)
 		}
-		replyContent = fmt.Sprintf("🗑️ Deleted notification `%s`", notificationId)
+		if err := b.Repos.DiscordMessages.CancelActions(ctx, notificationId.String()); err != nil {
+			slog.ErrorContext(ctx, "failed to cancel scheduled actions", "notification_id", notificationId, "err", err)
+		}
+		replyContent = fmt.Sprintf("🗑️ Deleted notification `%s`, `!undo` brings it back", notificationId)
+	case "list":
+		notifications, err := b.Repos.Notifications.GetUserNotifications(ctx, discordMeta.GuildId, discordMeta.UserId)
+		if err != nil {
+			return fmt.Errorf("failed to list notifications: %s", err)
+		}
+		replyContent = formatNotifications(notifications, loc)
 	}
 
 	if replyContent != "" {
-		msg, err := b.Session.ChannelMessageSend(b.AppConfig.DiscordSrvSchedulerCid, replyContent)
+		msg, err := b.Session.ChannelMessageSend(discordMeta.ChannelId, replyContent)
 		if err != nil {
-			log.Println("failed to send reply:", err)
+			slog.ErrorContext(ctx, "failed to send reply", "err", err)
 		} else {
-			if err := b.tagMessageToBeDeleted(msg, 180); err != nil {
-				log.Println("failed to tag message for deletion:", err)
+			if err := b.tagMessageToBeDeleted(msg, configs.MessageKinds.Reply); err != nil {
+				slog.ErrorContext(ctx, "failed to tag message for deletion", "err", err)
 			}
 		}
 	}
 
-	b.updateNotifications()
+	b.updateNotifications(ctx)
 	return nil
 }
 
-func formatNotifications(notifications []models.Notification) string {
-	if len(notifications) == 0 {
-		return "📭 No upcoming notifications."
+// scheduleAnnouncements announces a shared notification in the channel it
+// was made in once it starts, replacing earlier announcements for it. Events
+// count down on their RSVP post instead, see scheduleCountdown. DMs, and
+// notifications already posted to channels, get the reminder only.
+func (b *DiscordBot) scheduleAnnouncements(ctx context.Context, notification *models.Notification, discordMeta *configs.DiscordMetadata) {
+	if err := b.Repos.DiscordMessages.CancelActions(ctx, notification.ID.String()); err != nil {
+		slog.ErrorContext(ctx, "failed to cancel scheduled actions", "notification_id", notification.ID, "err", err)
+		return
 	}
-
-	var b strings.Builder
-	b.WriteString("📅 **Upcoming Notifications**\n\n")
-
-	for _, n := range notifications {
-		fmt.Fprintf(&b, "⏰ %s — **%s** `[id:%s]`\n", n.NotifyAt.Format("Jan 02 15:04 MST"), n.Title, n.ID)
-		fmt.Fprintf(&b, "📝 %s\n\n", n.Message)
+	if notification.NotifyAt.Before(time.Now()) {
+		return
 	}
-
-	return b.String()
-}
-
-func (b *DiscordBot) getFirstMessageInChannel(channelID string) (*discordgo.Message, error) {
-	messages, err := b.Session.ChannelMessages(channelID, 1, "", "0", "")
-	if err != nil {
-		return nil, err
+	if notification.RsvpMessageId != "" {
+		if err := b.scheduleCountdown(ctx, notification); err != nil {
+			slog.ErrorContext(ctx, "failed to schedule countdown", "notification_id", notification.ID, "err", err)
+		}
+		return
+	}
+	if discordMeta.IsDM() || postsToChannel(notification) {
+		return
 	}
-	if len(messages) == 0 {
-		return nil, nil
+	err := b.scheduleAction(ctx, ScheduleActionDto{
+		Action:    "post",
+		ChannelId: discordMeta.ChannelId,
+		At:        notification.NotifyAt,
+		Payload: ActionPayload{
+			Content:    fmt.Sprintf("🔔 **%s** is starting now\n%s", notification.Title, notification.Message),
+			ExpireKind: configs.MessageKinds.Reminder,
+		},
+		RefId: notification.ID.String(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to schedule announcement", "notification_id", notification.ID, "err", err)
 	}
-	return messages[0], nil
 }
 
-func (b *DiscordBot) updateNotifications() {
-	allNotifications, err := b.NotificationsRepo.GetAllNotifications()
+// checkNotificationAccess keeps data apart: from a DM only notifications
+// owned by the sender can be changed, in a server only that server's.
+func (b *DiscordBot) checkNotificationAccess(ctx context.Context, id string, discordMeta *configs.DiscordMetadata) error {
+	notificationId, err := uuid.Parse(id)
 	if err != nil {
-		log.Println("Discord bot getting notifications failed.")
-		return
+		return fmt.Errorf("failed to parse notification_id: %s", err)
 	}
-	content := formatNotifications(allNotifications)
-	firstMsg, err := b.getFirstMessageInChannel(b.AppConfig.DiscordSrvSchedulerCid)
+	notification, err := b.Repos.Notifications.GetNotification(ctx, notificationId)
 	if err != nil {
-		log.Println("Discord bot failed to fetch notifications channel messages:", err)
-		return
+		return fmt.Errorf("notification `%s` not found", id)
+	}
+	if discordMeta.IsDM() && notification.UserId != discordMeta.UserId {
+		return fmt.Errorf("notification `%s` not found", id)
 	}
+	if !discordMeta.IsDM() && notification.GuildId != discordMeta.GuildId {
+		return fmt.Errorf("notification `%s` not found", id)
+	}
+	return nil
+}
 
-	// found 1st channel message, editing it
-	if firstMsg != nil {
-		_, err = b.Session.ChannelMessageEdit(b.AppConfig.DiscordSrvSchedulerCid, firstMsg.ID, content)
-		if err != nil {
-			log.Println("Discord bot failed to edit notifications message:", err)
-		}
-		return
+func formatNotifications(notifications []models.Notification, loc *time.Location) string {
+	if len(notifications) == 0 {
+		return "📭 No upcoming notifications."
 	}
 
-	// initializing 1st channel message
-	_, err = b.Session.ChannelMessageSend(b.AppConfig.DiscordSrvSchedulerCid, content)
-	if err != nil {
-		log.Println("Discord bot failed to send notifications message:", err)
+	var b strings.Builder
+	b.WriteString("📅 **Upcoming Notifications**\n\n")
+
+	for _, n := range notifications {
+		fmt.Fprintf(&b, "⏰ %s — **%s** `[id:%s]`\n", n.NotifyAt.In(loc).Format("Jan 02 15:04 MST"), n.Title, n.ID)
+		fmt.Fprintf(&b, "📝 %s\n\n", n.Message)
 	}
+
+	return b.String()
 }
 
 func (b *DiscordBot) dmUser(userID string, message string) error {
@@ -294,43 +373,140 @@ func (b *DiscordBot) dmUser(userID string, message string) error {
 }
 
 func (b *DiscordBot) onReady(s *discordgo.Session, event *discordgo.Ready) {
-	log.Printf("Logged in as: %s#%s\n", event.User.Username, event.User.Discriminator)
-	b.updateNotifications()
+	// a reconnect during shutdown must not touch the closing database
+	ctx, ok := b.startHandling()
+	if !ok {
+		return
+	}
+	defer b.inflight.Done()
+
+	slog.InfoContext(ctx, "logged in", "user", event.User.Username, "user_id", event.User.ID)
+	b.resolveOwner()
+	b.registerSlashCommands()
+	b.updateNotifications(ctx)
 }
 
 func (b *DiscordBot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore messages from the bot itself
-	if m.Author.ID == s.State.User.ID {
+	if m.Author.ID == b.State.User.ID {
 		return
 	}
+	ctx, ok := b.startHandling()
+	if !ok {
+		return
+	}
+	defer b.inflight.Done()
+
+	discordMetadata := &configs.DiscordMetadata{
+		ChannelId: m.ChannelID,
+		MessageId: m.ID,
+		GuildId:   m.GuildID,
+		UserId:    m.Author.ID,
+		Username:  m.Author.Username,
+	}
 
-	intent, err := b.IntentService.DetectIntent(m.ChannelID, m.Content)
-	log.Printf("Intent: %v", intent)
-	if err != nil {
-		sentErrMsg, secondErr := s.ChannelMessageSend(m.ChannelID, err.Error())
-		if secondErr != nil {
-			log.Printf("Failed to send discord message: %s", err.Error())
+	// the bot leaves guilds that aren't allowed, this covers the time until
+	// it does and guilds denied while it is running
+	if !discordMetadata.IsDM() && !b.guildAllowed(m.GuildID) {
+		return
+	}
+
+	slog.DebugContext(ctx, "message received", "guild_id", m.GuildID, "channel_id", m.ChannelID,
+		"user_id", m.Author.ID, "content", logging.Content(m.Content))
+
+	if b.handleCommand(ctx, m) {
+		metrics.Messages.WithLabelValues("command").Inc()
+		return
+	}
+
+	// unbound channels are general chat, only messages addressed to the bot
+	// get routed there. DMs are always addressed to the bot.
+	content := m.Content
+	bound := !discordMetadata.IsDM() && len(b.IntentService.ChannelServices(ctx, m.ChannelID)) > 0
+	if discordMetadata.IsDM() {
+		content, _ = stripBotMention(m.Message, b.State.User.ID)
+	} else if !bound {
+		var addressed bool
+		if content, addressed = stripBotMention(m.Message, b.State.User.ID); !addressed {
 			return
 		}
-		b.tagMessageToBeDeleted(sentErrMsg, 180)
+	}
+	metrics.Messages.WithLabelValues("intent").Inc()
+
+	// attachments are routed on what Discord says about them and only
+	// downloaded once the user may use the service they go to
+	var pending []*discordgo.MessageAttachment
+	var attachments []configs.AttachmentRef
+	if len(m.Attachments) > 0 {
+		var rejected []string
+		pending, attachments, rejected = checkAttachments(m)
+		if len(rejected) > 0 {
+			b.replyError(m.ChannelID, fmt.Errorf("⚠️ Skipped attachments:\n%s", strings.Join(rejected, "\n")))
+		}
+	}
+
+	guild := b.guildSettings(m.GuildID)
+	intent, err := b.IntentService.DetectIntent(ctx, llm.IntentRequest{
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		UserID:      m.Author.ID,
+		DM:          discordMetadata.IsDM(),
+		Message:     content,
+		Attachments: attachments,
+		Location:    guildLocation(guild),
+		Language:    guildLanguage(guild),
+		Services:    guildServices(guild),
+	})
+	if err != nil {
+		slog.InfoContext(ctx, "no intent", "err", err)
+		metrics.Intents.WithLabelValues("", "", "error").Inc()
+		b.replyError(m.ChannelID, err)
 		return
 	}
-	if intent.Service == configs.ServiceNames.Scheduler {
-		discordMetadata := &configs.DiscordMetadata{
-			ChannelId: m.ChannelID,
-			MessageId: m.ID,
-			UserId:    m.Author.ID,
-			Username:  m.Author.Username,
+	slog.InfoContext(ctx, "intent detected", "service", intent.Service, "action", intent.Action,
+		"confidence", intent.Confidence, "attachments", len(intent.Attachments))
+	if intent.Service != "unknown" {
+		if err := b.authorize(ctx, m, intent.Service, intent.Action); err != nil {
+			metrics.Intents.WithLabelValues(intent.Service, intent.Action, "denied").Inc()
+			b.replyError(m.ChannelID, err)
+			b.tagRequestToBeDeleted(m.Message)
+			return
 		}
-		err := b.handleNotifications(intent, discordMetadata)
-		if err != nil {
-			sentErrMsg, secondErr := s.ChannelMessageSend(m.ChannelID, err.Error())
-			if secondErr != nil {
-				log.Printf("Failed to send discord message: %s", err.Error())
+		if len(pending) > 0 {
+			var rejected []string
+			intent.Attachments, rejected = b.collectAttachments(pending)
+			if len(rejected) > 0 {
+				b.replyError(m.ChannelID, fmt.Errorf("⚠️ Skipped attachments:\n%s", strings.Join(rejected, "\n")))
 			}
-			b.tagMessageToBeDeleted(sentErrMsg, 180)
 		}
-		b.tagMessageToBeDeleted(m.Message, 180)
+	}
+	switch intent.Service {
+	case configs.ServiceNames.Scheduler:
+		err = b.handleNotifications(ctx, intent, discordMetadata, m.Message)
+		b.tagRequestToBeDeleted(m.Message)
+	case configs.ServiceNames.Polls:
+		err = b.handlePolls(ctx, intent, discordMetadata)
+		b.tagRequestToBeDeleted(m.Message)
+	case configs.ServiceNames.Receipts:
+		err = b.handleReceipts(ctx, intent, discordMetadata)
+		if intent.Action != "add" {
+			b.tagRequestToBeDeleted(m.Message)
+		}
+	case "unknown":
+		if !bound || discordMetadata.IsDM() {
+			err = fmt.Errorf("🤔 Not sure what you'd like me to do. Try mentioning a reminder, receipt, poll or conversion, or `!help`.")
+		}
+	default:
+		err = b.handleService(ctx, intent, discordMetadata)
+		b.tagRequestToBeDeleted(m.Message)
+	}
+	outcome := metrics.Outcome(err)
+	if intent.Service == "unknown" {
+		outcome = "unknown"
+	}
+	metrics.Intents.WithLabelValues(intent.Service, intent.Action, outcome).Inc()
+	if err != nil {
+		b.replyError(m.ChannelID, err)
 	}
 
 	// switch m.Content {
@@ -343,12 +519,23 @@ func (b *DiscordBot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageC
 	// }
 }
 
-func (b *DiscordBot) onGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
-	if event.Guild.ID != b.AppConfig.DiscordMasterServerId {
-		log.Printf("✗ Bot was added to unauthorized server: %s (ID: %s) - leaving immediately\n",
-			event.Guild.Name, event.Guild.ID)
-		s.GuildLeave(event.Guild.ID)
-	} else {
-		log.Printf("✓ Bot added to allowed server: %s\n", event.Guild.Name)
+// stripBotMention reports whether the message mentions the bot and returns
+// its content without the mention.
+func stripBotMention(m *discordgo.Message, botID string) (string, bool) {
+	for _, user := range m.Mentions {
+		if user.ID == botID {
+			content := strings.NewReplacer("<@"+botID+">", "", "<@!"+botID+">", "").Replace(m.Content)
+			return strings.TrimSpace(content), true
+		}
+	}
+	return m.Content, false
+}
+
+func (b *DiscordBot) replyError(channelID string, err error) {
+	sentErrMsg, sendErr := b.Session.ChannelMessageSend(channelID, err.Error())
+	if sendErr != nil {
+		slog.Error("failed to send discord message", "channel_id", channelID, "err", sendErr)
+		return
 	}
+	b.tagMessageToBeDeleted(sentErrMsg, configs.MessageKinds.Error)
 }
@@ -0,0 +1,244 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/llm"
+	"biyobot/services/database"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+func guildMeta(userId string) *configs.DiscordMetadata {
+	return &configs.DiscordMetadata{ChannelId: testChannel, MessageId: "msg-" + userId, GuildId: testGuild, UserId: userId, Username: userId}
+}
+
+func dmMeta(userId string) *configs.DiscordMetadata {
+	return &configs.DiscordMetadata{ChannelId: "dm-" + userId, MessageId: "msg-" + userId, UserId: userId, Username: userId}
+}
+
+func messageOf(meta *configs.DiscordMetadata, content string) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        meta.MessageId,
+		ChannelID: meta.ChannelId,
+		GuildID:   meta.GuildId,
+		Author:    &discordgo.User{ID: meta.UserId, Username: meta.Username},
+		Content:   content,
+	}
+}
+
+func addIntent(title string, at time.Time) *llm.IntentResult {
+	return &llm.IntentResult{
+		Service: configs.ServiceNames.Scheduler,
+		Action:  "add",
+		Params: map[string]any{
+			"notify_at":   at.Format(time.RFC3339),
+			"title":       title,
+			"description": "at the usual place",
+		},
+	}
+}
+
+func deleteIntent(id uuid.UUID) *llm.IntentResult {
+	return &llm.IntentResult{
+		Service: configs.ServiceNames.Scheduler,
+		Action:  "delete",
+		Params:  map[string]any{"notification_id": id.String()},
+	}
+}
+
+func TestHandleNotificationsAdd(t *testing.T) {
+	b, session := newTestBot(t)
+	ctx := context.Background()
+	meta := guildMeta("alice")
+	at := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	if err := b.handleNotifications(ctx, addIntent("Dinner", at), meta, messageOf(meta, "dinner tomorrow")); err != nil {
+		t.Fatalf("handleNotifications: %v", err)
+	}
+
+	notifications, err := b.Repos.Notifications.GetUserNotifications(ctx, testGuild, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notifications))
+	}
+	n := notifications[0]
+	if n.Title != "Dinner" || n.GuildId != testGuild || n.UserId != "alice" || n.Private {
+		t.Errorf("stored %+v", n)
+	}
+	if !n.NotifyAt.Equal(at) {
+		t.Errorf("notify_at = %s, want %s", n.NotifyAt, at)
+	}
+	replies := session.sentTo(testChannel)
+	if len(replies) == 0 || !strings.Contains(replies[0], "Scheduled **Dinner**") {
+		t.Errorf("replies = %q", replies)
+	}
+}
+
+func TestHandleNotificationsRejectsBadTime(t *testing.T) {
+	b, _ := newTestBot(t)
+	meta := guildMeta("alice")
+	intent := addIntent("Dinner", time.Now())
+	intent.Params["notify_at"] = "tomorrow-ish"
+	if err := b.handleNotifications(context.Background(), intent, meta, messageOf(meta, "dinner")); err == nil {
+		t.Fatal("expected an error for an unparsable notify_at")
+	}
+}
+
+func TestNotificationAccess(t *testing.T) {
+	b, _ := newTestBot(t)
+	ctx := context.Background()
+	at := time.Now().Add(time.Hour)
+	shared, err := b.Repos.Notifications.AddNotification(ctx, database.AddNotificationDto{
+		Service: configs.ServiceNames.Scheduler, NotifyAt: at, Title: "shared", GuildId: testGuild, UserId: "alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := b.Repos.Notifications.AddNotification(ctx, database.AddNotificationDto{
+		Service: configs.ServiceNames.Scheduler, NotifyAt: at, Title: "private", UserId: "alice", Private: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherGuild := guildMeta("bob")
+	otherGuild.GuildId = "guild-2"
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		meta    *configs.DiscordMetadata
+		allowed bool
+	}{
+		{"same guild, other member", shared.ID, guildMeta("bob"), true},
+		{"other guild", shared.ID, otherGuild, false},
+		{"owner in DMs", private.ID, dmMeta("alice"), true},
+		{"someone else in DMs", private.ID, dmMeta("bob"), false},
+		{"private from a guild", private.ID, guildMeta("alice"), false},
+		{"unknown id", uuid.New(), guildMeta("alice"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := b.checkNotificationAccess(ctx, tt.id.String(), tt.meta)
+			if allowed := err == nil; allowed != tt.allowed {
+				t.Errorf("allowed = %v (%v), want %v", allowed, err, tt.allowed)
+			}
+		})
+	}
+
+	// a denied delete leaves the notification alone
+	if err := b.handleNotifications(ctx, deleteIntent(shared.ID), otherGuild, messageOf(otherGuild, "delete")); err == nil {
+		t.Fatal("delete from another guild succeeded")
+	}
+	if _, err := b.Repos.Notifications.GetNotification(ctx, shared.ID); err != nil {
+		t.Fatalf("notification gone after a denied delete: %v", err)
+	}
+}
+
+func TestUndoRestoresDeletedNotification(t *testing.T) {
+	b, _ := newTestBot(t)
+	ctx := context.Background()
+	meta := guildMeta("alice")
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := b.handleNotifications(ctx, addIntent("Standup", at), meta, messageOf(meta, "standup")); err != nil {
+		t.Fatal(err)
+	}
+	notifications, _ := b.Repos.Notifications.GetUserNotifications(ctx, testGuild, "alice")
+	id := notifications[0].ID
+	if err := b.handleNotifications(ctx, deleteIntent(id), meta, messageOf(meta, "delete it")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Repos.Notifications.GetNotification(ctx, id); err == nil {
+		t.Fatal("notification still there after delete")
+	}
+
+	// only the author's changes are undone
+	if _, err := b.cmdUndo(ctx, &discordgo.MessageCreate{Message: messageOf(guildMeta("bob"), "!undo")}, nil); err == nil {
+		t.Fatal("bob undid alice's delete")
+	}
+	reply, err := b.cmdUndo(ctx, &discordgo.MessageCreate{Message: messageOf(meta, "!undo")}, nil)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if !strings.Contains(reply, "Restored **Standup**") {
+		t.Errorf("reply = %q", reply)
+	}
+	restored, err := b.Repos.Notifications.GetNotification(ctx, id)
+	if err != nil {
+		t.Fatalf("notification not restored: %v", err)
+	}
+	if !restored.NotifyAt.Equal(at) {
+		t.Errorf("restored notify_at = %s, want %s", restored.NotifyAt, at)
+	}
+}
+
+func rsvpPress(notificationId uuid.UUID, userId, status string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		GuildID:   testGuild,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: userId}},
+		Data:      discordgo.MessageComponentInteractionData{CustomID: "rsvp:" + notificationId.String() + ":" + status},
+	}}
+}
+
+func TestRsvpWaitlistPromotion(t *testing.T) {
+	b, session := newTestBot(t)
+	ctx := context.Background()
+	event, err := b.Repos.Notifications.AddNotification(ctx, database.AddNotificationDto{
+		Service: configs.ServiceNames.Scheduler, NotifyAt: time.Now().Add(time.Hour), Title: "Board games",
+		GuildId: testGuild, UserId: "alice", IsEvent: true, Capacity: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.postRsvp(ctx, event, testChannel); err != nil {
+		t.Fatal(err)
+	}
+
+	press := func(userId, status string) {
+		t.Helper()
+		i := rsvpPress(event.ID, userId, status)
+		if _, err := b.onRsvpComponent(ctx, i, []string{event.ID.String(), status}); err != nil {
+			t.Fatalf("%s %s: %v", userId, status, err)
+		}
+	}
+	statuses := func() map[string]string {
+		t.Helper()
+		attendees, err := b.Repos.Attendees.GetAttendees(ctx, event.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byUser := map[string]string{}
+		for _, a := range attendees {
+			byUser[a.UserId] = a.Status
+		}
+		return byUser
+	}
+
+	press("bob", "going")
+	press("carol", "going")
+	if got := statuses(); got["alice"] != "going" || got["bob"] != "waitlist" || got["carol"] != "waitlist" {
+		t.Fatalf("before: %v", got)
+	}
+
+	press("alice", "declined")
+	// the DM goes out after the reply
+	b.inflight.Wait()
+	got := statuses()
+	if got["bob"] != "going" || got["carol"] != "waitlist" {
+		t.Fatalf("after alice declined: %v, want bob going and carol still waiting", got)
+	}
+	if dms := session.dmsTo("bob"); len(dms) != 1 || !strings.Contains(dms[0], "Board games") {
+		t.Errorf("bob's DMs = %q", dms)
+	}
+	if dms := session.dmsTo("carol"); len(dms) != 0 {
+		t.Errorf("carol was notified: %q", dms)
+	}
+}
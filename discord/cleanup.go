@@ -0,0 +1,190 @@
+package discord
+
+import (
+	"biyobot/metrics"
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+const (
+	// Discord refuses to bulk delete messages older than 14 days, an hour
+	// of margin keeps requests from failing on the boundary
+	bulkDeleteMaxAge = 14*24*time.Hour - time.Hour
+	bulkDeleteLimit  = 100
+	// requests failing with 429/5xx are retried right away this many times,
+	// after that the message is rescheduled for a later run
+	requestRetries    = 3
+	maxActionAttempts = 5
+)
+
+// the first wait between retries of a request, doubling after each, a
+// variable so tests can shorten it
+var requestBackoff = 500 * time.Millisecond
+
+// requestOutcome is how a request on a message went, see classifyRequestError.
+type requestOutcome int
+
+const (
+	requestDone  requestOutcome = iota // succeeded, or the message is gone
+	requestRetry                       // failed for now, try again later
+	requestDrop                        // can never succeed, e.g. missing permissions
+)
+
+func (b *DiscordBot) tagMessageToBeDeleted(msg *discordgo.Message, kind string) error {
+	slog.Debug("tagging a message to be deleted", "message_id", msg.ID, "channel_id", msg.ChannelID, "kind", kind)
+	_, err := b.Repos.DiscordMessages.AddMessage(context.Background(), database.AddDiscordMessageDto{
+		Action:          "delete",
+		ChannelId:       msg.ChannelID,
+		UserId:          msg.Author.ID,
+		MessageId:       msg.ID,
+		Content:         msg.Content,
+		ExecuteActionOn: utils.JapanTimeNow().Add(b.Config().MessageTTL(kind)),
+		Kind:            kind,
+	})
+	return err
+}
+
+// DeleteExpiredMessages deletes expired messages per channel, in bulk where
+// Discord allows it. Rows are only removed once their message is gone,
+// failures are retried on later runs with a growing delay.
+func (b *DiscordBot) DeleteExpiredMessages(ctx context.Context) error {
+	expiredMessages, err := b.Repos.DiscordMessages.GetAllExpiredMessages(ctx)
+	if err != nil {
+		return fmt.Errorf("getting expired messages failed: %w", err)
+	}
+	metrics.DeletionBacklog.Set(float64(len(expiredMessages)))
+
+	byChannel := make(map[string][]models.DiscordMessage)
+	for _, e := range expiredMessages {
+		byChannel[e.ChannelId] = append(byChannel[e.ChannelId], e)
+	}
+
+	var finished []uuid.UUID
+	retries := make(map[int][]uuid.UUID) // by attempts so far
+	for channelId, messages := range byChannel {
+		for _, result := range b.deleteChannelMessages(ctx, channelId, messages) {
+			switch {
+			case result.outcome == requestRetry && result.message.Attempts+1 < maxActionAttempts:
+				retries[result.message.Attempts] = append(retries[result.message.Attempts], result.message.ID)
+			case result.outcome != requestDone:
+				slog.WarnContext(ctx, "giving up deleting message", "message_id", result.message.MessageId, "channel_id", channelId)
+				fallthrough
+			default:
+				finished = append(finished, result.message.ID)
+			}
+		}
+	}
+
+	if len(finished) != 0 {
+		if err := b.Repos.DiscordMessages.DeleteMessageBatch(ctx, finished); err != nil {
+			slog.ErrorContext(ctx, "removing deleted messages failed", "err", err)
+		}
+	}
+	for attempts, ids := range retries {
+		retryAt := utils.JapanTimeNow().Add(time.Minute << attempts)
+		if err := b.Repos.DiscordMessages.RetryMessageBatch(ctx, ids, retryAt); err != nil {
+			slog.ErrorContext(ctx, "rescheduling message deletions failed", "err", err)
+		}
+	}
+	return nil
+}
+
+type deleteResult struct {
+	message models.DiscordMessage
+	outcome requestOutcome
+}
+
+func (b *DiscordBot) deleteChannelMessages(ctx context.Context, channelId string, messages []models.DiscordMessage) []deleteResult {
+	results := make([]deleteResult, 0, len(messages))
+	var bulk, single []models.DiscordMessage
+	for _, m := range messages {
+		sentAt, err := discordgo.SnowflakeTimestamp(m.MessageId)
+		if err == nil && time.Since(sentAt) < bulkDeleteMaxAge {
+			bulk = append(bulk, m)
+		} else {
+			single = append(single, m)
+		}
+	}
+
+	for start := 0; start < len(bulk); start += bulkDeleteLimit {
+		chunk := bulk[start:min(start+bulkDeleteLimit, len(bulk))]
+		if len(chunk) == 1 {
+			single = append(single, chunk...)
+			continue
+		}
+		ids := make([]string, len(chunk))
+		for i, m := range chunk {
+			ids[i] = m.MessageId
+		}
+		outcome := requestWithRetry(ctx, func() error {
+			return b.Session.ChannelMessagesBulkDelete(channelId, ids)
+		})
+		if outcome != requestDone {
+			// e.g. DM channels, where bulk deletes aren't allowed, or one
+			// message of the chunk is gone. One by one sorts it out.
+			single = append(single, chunk...)
+			continue
+		}
+		for _, m := range chunk {
+			results = append(results, deleteResult{message: m, outcome: requestDone})
+		}
+	}
+
+	for _, m := range single {
+		outcome := requestWithRetry(ctx, func() error {
+			return b.Session.ChannelMessageDelete(channelId, m.MessageId)
+		})
+		if outcome != requestDone {
+			slog.WarnContext(ctx, "failed to delete message", "message_id", m.MessageId, "channel_id", channelId, "attempt", m.Attempts+1)
+		}
+		results = append(results, deleteResult{message: m, outcome: outcome})
+	}
+	return results
+}
+
+// requestWithRetry runs a request on a message, retrying with backoff while Discord
+// answers 429 or 5xx.
+func requestWithRetry(ctx context.Context, request func() error) requestOutcome {
+	backoff := requestBackoff
+	for attempt := 0; ; attempt++ {
+		outcome := classifyRequestError(request())
+		if outcome != requestRetry || attempt+1 >= requestRetries {
+			return outcome
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return requestRetry
+		}
+	}
+}
+
+func classifyRequestError(err error) requestOutcome {
+	if err == nil || isUnknownMessage(err) {
+		return requestDone
+	}
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		// network errors and the like
+		return requestRetry
+	}
+	if restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownChannel {
+		return requestDone
+	}
+	status := restErr.Response.StatusCode
+	if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+		return requestRetry
+	}
+	return requestDrop
+}
@@ -0,0 +1,227 @@
+package discord
+
+import (
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+// shortBackoff makes requests retry without waiting.
+func shortBackoff(t *testing.T) {
+	backoff := requestBackoff
+	requestBackoff = time.Millisecond
+	t.Cleanup(func() { requestBackoff = backoff })
+}
+
+// snowflakeAt returns the id Discord gives the nth message sent at at.
+func snowflakeAt(at time.Time, n int) string {
+	return strconv.FormatInt((at.UnixMilli()-1420070400000)<<22|int64(n), 10)
+}
+
+func restError(status, code int) error {
+	return &discordgo.RESTError{
+		Response: &http.Response{StatusCode: status, Status: http.StatusText(status)},
+		Message:  &discordgo.APIErrorMessage{Code: code},
+	}
+}
+
+// queueDeletion queues a message for deletion that is already due.
+func queueDeletion(t *testing.T, b *DiscordBot, channelId, messageId string) uuid.UUID {
+	t.Helper()
+	m, err := b.Repos.DiscordMessages.AddMessage(context.Background(), database.AddDiscordMessageDto{
+		Action:          "delete",
+		ChannelId:       channelId,
+		UserId:          botUser,
+		MessageId:       messageId,
+		ExecuteActionOn: utils.JapanTimeNow().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.ID
+}
+
+func queued(t *testing.T, b *DiscordBot) int64 {
+	t.Helper()
+	count, err := b.Repos.DiscordMessages.CountQueuedMessages(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// retryRecorder notes when each rescheduled message or action is retried.
+type retryRecorder struct {
+	database.DiscordMessages
+	retryAt map[uuid.UUID]time.Time
+}
+
+func recordRetries(b *DiscordBot) *retryRecorder {
+	r := &retryRecorder{DiscordMessages: b.Repos.DiscordMessages, retryAt: map[uuid.UUID]time.Time{}}
+	b.Repos.DiscordMessages = r
+	return r
+}
+
+func (r *retryRecorder) RetryMessageBatch(ctx context.Context, ids []uuid.UUID, retryAt time.Time) error {
+	for _, id := range ids {
+		r.retryAt[id] = retryAt
+	}
+	return r.DiscordMessages.RetryMessageBatch(ctx, ids, retryAt)
+}
+
+func TestDeleteExpiredMessagesInBulk(t *testing.T) {
+	b, session := newTestBot(t)
+	now := time.Now()
+
+	// 201 recent messages make two full chunks, the one left over is
+	// deleted on its own
+	var recent []string
+	for n := range 201 {
+		recent = append(recent, snowflakeAt(now.Add(-time.Hour), n))
+	}
+	// too old to bulk delete, the last within the hour of margin
+	old := []string{
+		snowflakeAt(now.Add(-15*24*time.Hour), 0),
+		snowflakeAt(now.Add(-20*24*time.Hour), 0),
+		snowflakeAt(now.Add(-14*24*time.Hour+30*time.Minute), 0),
+	}
+	for _, id := range append(slices.Clone(recent), old...) {
+		queueDeletion(t, b, testChannel, id)
+	}
+
+	if err := b.DeleteExpiredMessages(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(session.bulk) != 2 || len(session.bulk[0]) != 100 || len(session.bulk[1]) != 100 {
+		t.Fatalf("bulk deleted %d chunks, want two of 100", len(session.bulk))
+	}
+	if !slices.Equal(slices.Concat(session.bulk...), recent[:200]) {
+		t.Errorf("bulk deleted other messages than the first 200 recent ones")
+	}
+	slices.Sort(session.deleted)
+	want := append([]string{recent[200]}, old...)
+	slices.Sort(want)
+	if !slices.Equal(session.deleted, want) {
+		t.Errorf("deleted one by one %v, want %v", session.deleted, want)
+	}
+	if n := queued(t, b); n != 0 {
+		t.Errorf("%d messages still queued", n)
+	}
+}
+
+func TestDeleteExpiredMessagesFallsBackToSingleDeletes(t *testing.T) {
+	shortBackoff(t)
+	b, session := newTestBot(t)
+	// DM channels don't take bulk deletes
+	session.fail = func(request, channelID, messageID string) error {
+		if request == "bulk delete" {
+			return restError(http.StatusBadRequest, 50003)
+		}
+		return nil
+	}
+	now := time.Now()
+	for n := range 3 {
+		queueDeletion(t, b, "dm-1", snowflakeAt(now, n))
+	}
+
+	if err := b.DeleteExpiredMessages(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(session.deleted) != 3 {
+		t.Errorf("deleted %d messages one by one, want 3", len(session.deleted))
+	}
+	if n := queued(t, b); n != 0 {
+		t.Errorf("%d messages still queued", n)
+	}
+}
+
+func TestDeleteExpiredMessagesGoneIsDone(t *testing.T) {
+	shortBackoff(t)
+	b, session := newTestBot(t)
+	session.fail = func(request, channelID, messageID string) error {
+		switch channelID {
+		case "unknown-message":
+			return restError(http.StatusNotFound, discordgo.ErrCodeUnknownMessage)
+		case "unknown-channel":
+			return restError(http.StatusNotFound, discordgo.ErrCodeUnknownChannel)
+		case "forbidden":
+			return restError(http.StatusForbidden, discordgo.ErrCodeMissingPermissions)
+		}
+		return nil
+	}
+	retries := recordRetries(b)
+	for _, channelId := range []string{"unknown-message", "unknown-channel", "forbidden"} {
+		queueDeletion(t, b, channelId, snowflakeAt(time.Now(), 0))
+	}
+
+	if err := b.DeleteExpiredMessages(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// gone is as good as deleted, missing permissions won't come back
+	if n := queued(t, b); n != 0 {
+		t.Errorf("%d messages still queued", n)
+	}
+	if len(retries.retryAt) != 0 {
+		t.Errorf("rescheduled %d messages, want none", len(retries.retryAt))
+	}
+}
+
+func TestDeleteExpiredMessagesBacksOff(t *testing.T) {
+	shortBackoff(t)
+	for attempts := range maxActionAttempts {
+		t.Run(strconv.Itoa(attempts)+" attempts before", func(t *testing.T) {
+			b, session := newTestBot(t)
+			requests := 0
+			session.fail = func(request, channelID, messageID string) error {
+				requests++
+				return restError(http.StatusServiceUnavailable, 0)
+			}
+			ctx := context.Background()
+			id := queueDeletion(t, b, testChannel, snowflakeAt(time.Now(), 0))
+			// earlier runs failed as often, leaving the message due again
+			for range attempts {
+				if err := b.Repos.DiscordMessages.RetryMessageBatch(ctx, []uuid.UUID{id}, utils.JapanTimeNow().Add(-time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			retries := recordRetries(b)
+
+			if err := b.DeleteExpiredMessages(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if requests != requestRetries {
+				t.Errorf("tried %d times in the run, want %d", requests, requestRetries)
+			}
+
+			if attempts+1 == maxActionAttempts {
+				if n := queued(t, b); n != 0 {
+					t.Errorf("still queued after %d attempts, want it given up", maxActionAttempts)
+				}
+				if _, ok := retries.retryAt[id]; ok {
+					t.Errorf("rescheduled after the last attempt")
+				}
+				return
+			}
+			retryAt, ok := retries.retryAt[id]
+			if !ok {
+				t.Fatal("not rescheduled")
+			}
+			want := time.Minute << attempts
+			if wait := time.Until(retryAt); wait > want || wait < want-5*time.Second {
+				t.Errorf("retried in %s, want %s", wait, want)
+			}
+			if n := queued(t, b); n != 1 {
+				t.Errorf("%d messages queued, want the one rescheduled", n)
+			}
+		})
+	}
+}
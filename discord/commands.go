@@ -0,0 +1,343 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const commandPrefix = "!"
+
+type command struct {
+	Usage       string
+	Description string
+	AdminOnly   bool
+	OwnerOnly   bool
+	Run         func(b *DiscordBot, ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error)
+}
+
+var commands = map[string]command{
+	"bind": {
+		Usage:       "!bind [#channel] <service> [service...]",
+		Description: "route a channel to one or more services",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdBind,
+	},
+	"unbind": {
+		Usage:       "!unbind [#channel] [service...]",
+		Description: "remove services from a channel, or all of them",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdUnbind,
+	},
+	"bindings": {
+		Usage:       "!bindings",
+		Description: "list channel bindings of this server",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdBindings,
+	},
+	"board": {
+		Usage:       "!board all|week|today",
+		Description: "choose what the pinned notification board shows",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdBoard,
+	},
+	"settings": {
+		Usage:       "!settings",
+		Description: "show this server's settings",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdSettings,
+	},
+	"set": {
+		Usage:       "!set timezone|language|services|adminrole <value>",
+		Description: "change a server setting",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdSet,
+	},
+	"allow": {
+		Usage:       "!allow <@role|@user|everyone> <service|*> [action|*]",
+		Description: "allow a role or user a service or action",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdAllow,
+	},
+	"deny": {
+		Usage:       "!deny <@role|@user|everyone> <service|*> [action|*]",
+		Description: "deny a role or user a service or action",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdDeny,
+	},
+	"revoke": {
+		Usage:       "!revoke <@role|@user|everyone> <service|*> [action|*]",
+		Description: "remove an allow or deny rule",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdRevoke,
+	},
+	"permissions": {
+		Usage:       "!permissions",
+		Description: "list permission rules of this server",
+		AdminOnly:   true,
+		Run:         (*DiscordBot).cmdPermissions,
+	},
+	"status": {
+		Usage:       "!status",
+		Description: "show uptime, latency, queues and LLM health",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdStatus,
+	},
+	"services": {
+		Usage:       "!services",
+		Description: "list registered services",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdServices,
+	},
+	"reload": {
+		Usage:       "!reload",
+		Description: "reload the config",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdReload,
+	},
+	"pause": {
+		Usage:       "!pause [cleanup|notifications|actions|purge|backup|all]",
+		Description: "pause background tasks",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdPause,
+	},
+	"resume": {
+		Usage:       "!resume [cleanup|notifications|actions|purge|backup|all]",
+		Description: "resume background tasks",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdResume,
+	},
+	"sweep": {
+		Usage:       "!sweep [cleanup|notifications|actions|purge|backup|all]",
+		Description: "run background tasks now",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdSweep,
+	},
+	"backup": {
+		Usage:       "!backup",
+		Description: "back up the database and send it by DM",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdBackup,
+	},
+	"undo": {
+		Usage:       "!undo",
+		Description: "undo your last notification change",
+		Run:         (*DiscordBot).cmdUndo,
+	},
+	"guilds": {
+		Usage:       "!guilds",
+		Description: "list known servers and whether they are allowed",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdGuilds,
+	},
+	"guild": {
+		Usage:       "!guild allow|deny <server id>",
+		Description: "add a server to the allow-list or remove it",
+		OwnerOnly:   true,
+		Run:         (*DiscordBot).cmdGuild,
+	},
+}
+
+func init() {
+	// registered here since it reads the commands map itself
+	commands["help"] = command{
+		Usage:       "!help",
+		Description: "list commands",
+		Run:         (*DiscordBot).cmdHelp,
+	}
+}
+
+var channelMentionRe = regexp.MustCompile(`^<#(\d+)>$`)
+
+// handleCommand runs a "!" command and reports whether the message was one.
+func (b *DiscordBot) handleCommand(ctx context.Context, m *discordgo.MessageCreate) bool {
+	if !strings.HasPrefix(m.Content, commandPrefix) {
+		return false
+	}
+	fields := strings.Fields(strings.TrimPrefix(m.Content, commandPrefix))
+	if len(fields) == 0 {
+		return false
+	}
+	name := strings.ToLower(fields[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return false
+	}
+
+	var reply string
+	var err error
+	if cmd.OwnerOnly && !b.isOwner(m.Author.ID) {
+		slog.InfoContext(ctx, "command denied", "command", name, "user_id", m.Author.ID, "channel_id", m.ChannelID)
+		err = fmt.Errorf("⛔ `%s%s` is for the bot owner only", commandPrefix, name)
+	} else if cmd.AdminOnly && !b.isGuildAdmin(m) {
+		slog.InfoContext(ctx, "command denied", "command", name, "user_id", m.Author.ID, "channel_id", m.ChannelID)
+		err = fmt.Errorf("⛔ `%s%s` is for server admins only", commandPrefix, name)
+	} else if err = b.authorize(ctx, m, commandsService, name); err == nil {
+		reply, err = cmd.Run(b, ctx, m, fields[1:])
+	}
+	if err != nil {
+		b.replyError(m.ChannelID, err)
+	} else if reply != "" {
+		// replies list roles and users, mentioning them must not ping
+		msg, sendErr := b.Session.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+			Content:         reply,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		if sendErr != nil {
+			slog.ErrorContext(ctx, "failed to send command reply", "err", sendErr)
+		} else {
+			b.tagMessageToBeDeleted(msg, configs.MessageKinds.Reply)
+		}
+	}
+	b.tagRequestToBeDeleted(m.Message)
+	return true
+}
+
+// isGuildAdmin reports whether the author may manage channels where the
+// message was sent, or holds the server's admin role. DMs never carry admin
+// rights.
+func (b *DiscordBot) isGuildAdmin(m *discordgo.MessageCreate) bool {
+	if m.GuildID == "" {
+		return false
+	}
+	if guild := b.guildSettings(m.GuildID); guild != nil && guild.AdminRoleId != "" &&
+		m.Member != nil && slices.Contains(m.Member.Roles, guild.AdminRoleId) {
+		return true
+	}
+	perms, err := b.Session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		slog.Error("failed to resolve permissions", "user_id", m.Author.ID, "err", err)
+		return false
+	}
+	return perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageChannels) != 0
+}
+
+// targetChannel splits an optional leading #channel mention off args,
+// defaulting to the channel the command was sent in.
+func targetChannel(m *discordgo.MessageCreate, args []string) (string, []string) {
+	if len(args) > 0 {
+		if match := channelMentionRe.FindStringSubmatch(args[0]); match != nil {
+			return match[1], args[1:]
+		}
+	}
+	return m.ChannelID, args
+}
+
+// guildChannel looks a channel up, from the state cache first, and checks
+// that it belongs to guildId.
+func (b *DiscordBot) guildChannel(guildId, channelId string) (*discordgo.Channel, error) {
+	channel, err := b.State.Channel(channelId)
+	if err != nil {
+		if channel, err = b.Session.Channel(channelId); err != nil {
+			return nil, fmt.Errorf("can't find channel <#%s>", channelId)
+		}
+	}
+	if channel.GuildID != guildId {
+		return nil, fmt.Errorf("<#%s> is not in this server", channelId)
+	}
+	return channel, nil
+}
+
+func (b *DiscordBot) cmdHelp(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	names := make([]string, 0, len(commands))
+	for name, cmd := range commands {
+		if cmd.OwnerOnly && !b.isOwner(m.Author.ID) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("🤖 **Commands**\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "`%s` — %s\n", commands[name].Usage, commands[name].Description)
+	}
+	return sb.String(), nil
+}
+
+func (b *DiscordBot) cmdBind(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	channelId, services := targetChannel(m, args)
+	if len(services) == 0 {
+		return "", fmt.Errorf("usage: `!bind [#channel] <service> [service...]`")
+	}
+	if _, err := b.guildChannel(m.GuildID, channelId); err != nil {
+		return "", err
+	}
+	known := b.IntentService.ServiceNames()
+	enabled := guildServices(b.guildSettings(m.GuildID))
+	for _, service := range services {
+		if !slices.Contains(known, service) {
+			return "", fmt.Errorf("unknown service `%s`, available: %s", service, strings.Join(known, ", "))
+		}
+		if enabled != nil && !slices.Contains(enabled, service) {
+			return "", fmt.Errorf("service `%s` is disabled on this server, see `!settings`", service)
+		}
+	}
+	for _, service := range services {
+		err := b.Repos.ChannelBindings.BindChannel(ctx, database.BindChannelDto{
+			GuildId:   m.GuildID,
+			ChannelId: channelId,
+			Service:   service,
+			CreatedBy: m.Author.ID,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to bind %s: %s", service, err)
+		}
+	}
+	b.updateNotifications(ctx)
+	return fmt.Sprintf("🔗 <#%s> → %s", channelId, strings.Join(b.IntentService.ChannelServices(ctx, channelId), ", ")), nil
+}
+
+func (b *DiscordBot) cmdUnbind(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	channelId, services := targetChannel(m, args)
+	if _, err := b.guildChannel(m.GuildID, channelId); err != nil {
+		return "", err
+	}
+	removed, err := b.Repos.ChannelBindings.UnbindChannel(ctx, m.GuildID, channelId, services)
+	if err != nil {
+		return "", fmt.Errorf("failed to unbind: %s", err)
+	}
+	if removed == 0 {
+		return fmt.Sprintf("Nothing to unbind in <#%s>", channelId), nil
+	}
+	remaining := b.IntentService.ChannelServices(ctx, channelId)
+	if len(remaining) == 0 {
+		return fmt.Sprintf("✂️ <#%s> is no longer bound to any service", channelId), nil
+	}
+	return fmt.Sprintf("✂️ <#%s> → %s", channelId, strings.Join(remaining, ", ")), nil
+}
+
+func (b *DiscordBot) cmdBindings(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	bindings, err := b.Repos.ChannelBindings.GetGuildBindings(ctx, m.GuildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load bindings: %s", err)
+	}
+	if len(bindings) == 0 {
+		return "📭 No channels are bound. Use `!bind <service>` in a channel.", nil
+	}
+
+	byChannel := make(map[string][]string)
+	var channels []string
+	for _, binding := range bindings {
+		if _, ok := byChannel[binding.ChannelId]; !ok {
+			channels = append(channels, binding.ChannelId)
+		}
+		byChannel[binding.ChannelId] = append(byChannel[binding.ChannelId], binding.Service)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔗 **Channel bindings**\n")
+	for _, channelId := range channels {
+		fmt.Fprintf(&sb, "<#%s> → %s\n", channelId, strings.Join(byChannel[channelId], ", "))
+	}
+	return sb.String(), nil
+}
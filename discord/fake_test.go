@@ -0,0 +1,375 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/llm"
+	"biyobot/services"
+	"biyobot/services/database"
+	"biyobot/services/database/memory"
+	"biyobot/services/filestore"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ollama/ollama/api"
+)
+
+const (
+	testGuild   = "guild-1"
+	testChannel = "1001"
+	botUser     = "bot"
+)
+
+// fakeSession records what the bot sends instead of calling Discord.
+type fakeSession struct {
+	mu       sync.Mutex
+	nextId   int
+	sent     []*discordgo.Message
+	edits    []*discordgo.MessageEdit
+	dms      map[string]string // DM channel id by user id
+	channels map[string]*discordgo.Channel
+	perms    map[string]int64    // by user id, every permission when missing
+	members  map[string][]string // role ids by user id
+	left     []string
+	answers  []*discordgo.InteractionResponse
+	deleted  []string   // ids of the messages deleted one by one
+	bulk     [][]string // ids of each bulk delete
+	pins     []string   // "pin <message id>" or "unpin <message id>"
+	// fail returns the error a delete, edit or pin answers with, nil
+	// succeeds. Bulk deletes ask with no message.
+	fail func(request, channelID, messageID string) error
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{
+		dms:      map[string]string{},
+		channels: map[string]*discordgo.Channel{testChannel: {ID: testChannel, GuildID: testGuild}},
+		perms:    map[string]int64{},
+		members:  map[string][]string{},
+	}
+}
+
+func (f *fakeSession) message(channelID, content string) *discordgo.Message {
+	f.nextId++
+	msg := &discordgo.Message{ID: fmt.Sprint(f.nextId), ChannelID: channelID, Content: content, Author: &discordgo.User{ID: botUser}}
+	f.sent = append(f.sent, msg)
+	return msg
+}
+
+// sentTo returns the contents of the messages sent to a channel.
+func (f *fakeSession) sentTo(channelID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var contents []string
+	for _, msg := range f.sent {
+		if msg.ChannelID == channelID {
+			contents = append(contents, msg.Content)
+		}
+	}
+	return contents
+}
+
+// dmsTo returns the contents of the DMs sent to a user.
+func (f *fakeSession) dmsTo(userID string) []string {
+	f.mu.Lock()
+	channelID, ok := f.dms[userID]
+	f.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return f.sentTo(channelID)
+}
+
+func (f *fakeSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.message(channelID, content), nil
+}
+
+func (f *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg := f.message(channelID, data.Content)
+	msg.Embeds, msg.Components = data.Embeds, data.Components
+	return msg, nil
+}
+
+func (f *fakeSession) ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.ChannelMessageSend(channelID, content)
+}
+
+func (f *fakeSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.ChannelMessageEditComplex(discordgo.NewMessageEdit(channelID, messageID).SetContent(content))
+}
+
+func (f *fakeSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failed("edit", m.Channel, m.ID); err != nil {
+		return nil, err
+	}
+	f.edits = append(f.edits, m)
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel, Author: &discordgo.User{ID: botUser}}, nil
+}
+
+func (f *fakeSession) failed(request, channelID, messageID string) error {
+	if f.fail == nil {
+		return nil
+	}
+	return f.fail(request, channelID, messageID)
+}
+
+func (f *fakeSession) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failed("delete", channelID, messageID); err != nil {
+		return err
+	}
+	f.deleted = append(f.deleted, messageID)
+	return nil
+}
+
+func (f *fakeSession) ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failed("bulk delete", channelID, ""); err != nil {
+		return err
+	}
+	f.bulk = append(f.bulk, slices.Clone(messages))
+	return nil
+}
+
+func (f *fakeSession) ChannelMessagePin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failed("pin", channelID, messageID); err != nil {
+		return err
+	}
+	f.pins = append(f.pins, "pin "+messageID)
+	return nil
+}
+
+func (f *fakeSession) ChannelMessageUnpin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failed("unpin", channelID, messageID); err != nil {
+		return err
+	}
+	f.pins = append(f.pins, "unpin "+messageID)
+	return nil
+}
+
+func (f *fakeSession) MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextId++
+	return &discordgo.Channel{ID: fmt.Sprintf("thread-%d", f.nextId), ParentID: channelID}, nil
+}
+
+func (f *fakeSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.dms[recipientID]; !ok {
+		f.dms[recipientID] = "dm-" + recipientID
+	}
+	return &discordgo.Channel{ID: f.dms[recipientID], Type: discordgo.ChannelTypeDM}, nil
+}
+
+func (f *fakeSession) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if perms, ok := f.perms[userID]; ok {
+		return perms, nil
+	}
+	return ^int64(0), nil
+}
+
+func (f *fakeSession) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if channel, ok := f.channels[channelID]; ok {
+		return channel, nil
+	}
+	return nil, fmt.Errorf("unknown channel %s", channelID)
+}
+
+func (f *fakeSession) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &discordgo.Member{GuildID: guildID, User: &discordgo.User{ID: userID}, Roles: f.members[userID]}, nil
+}
+
+func (f *fakeSession) GuildLeave(guildID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.left = append(f.left, guildID)
+	return nil
+}
+
+func (f *fakeSession) Application(appID string) (*discordgo.Application, error) {
+	return &discordgo.Application{ID: botUser, Owner: &discordgo.User{ID: "owner"}}, nil
+}
+
+func (f *fakeSession) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	return commands, nil
+}
+
+func (f *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers = append(f.answers, resp)
+	return nil
+}
+
+// fakeLLM answers every prompt with the same response.
+type fakeLLM struct {
+	response string
+	down     error // returned by Heartbeat
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, req *api.ChatRequest, fn api.ChatResponseFunc) error {
+	return fn(api.ChatResponse{Message: api.Message{Role: "assistant", Content: f.response}})
+}
+
+func (f *fakeLLM) Heartbeat(ctx context.Context) error {
+	return f.down
+}
+
+func (f *fakeLLM) Show(ctx context.Context, req *api.ShowRequest) (*api.ShowResponse, error) {
+	return &api.ShowResponse{}, nil
+}
+
+// newTestBot returns a bot on in-memory repos and a fake session, allowed in
+// testGuild with testChannel bound to the scheduler.
+func newTestBot(t *testing.T) (*DiscordBot, *fakeSession) {
+	t.Helper()
+	conf := &configs.AppConfig{
+		DiscordMasterServerId: testGuild,
+		DiscordOwnerId:        "owner",
+		MessageTTLs: map[string]time.Duration{
+			configs.MessageKinds.Reply:    time.Minute,
+			configs.MessageKinds.Request:  time.Minute,
+			configs.MessageKinds.Error:    time.Minute,
+			configs.MessageKinds.Reminder: time.Hour,
+		},
+		IntentConfidenceThreshold: 0.6,
+		Tasks: configs.TaskSchedules{
+			Cleanup:       configs.TaskSchedule{Schedule: "3m"},
+			Notifications: configs.TaskSchedule{Schedule: "1m"},
+			Actions:       configs.TaskSchedule{Schedule: "30s"},
+			Purge:         configs.TaskSchedule{Schedule: "0 4 * * *"},
+		},
+	}
+	repos := memory.NewRepos()
+	files, err := filestore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newFakeSession()
+	state := discordgo.NewState()
+	state.User = &discordgo.User{ID: botUser}
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	t.Cleanup(cancelHandlers)
+	b := &DiscordBot{
+		Session:        session,
+		State:          state,
+		Services:       services.NewRegistry(),
+		IntentService:  llm.NewIntentService(&fakeLLM{}, repos.Notifications, repos.Expenses, repos.ChannelBindings, conf),
+		Repos:          repos,
+		Files:          files,
+		handlerCtx:     handlerCtx,
+		cancelHandlers: cancelHandlers,
+	}
+	b.config.Store(conf)
+
+	ctx := context.Background()
+	if _, err := repos.Guilds.EnsureGuild(ctx, database.UpsertGuildDto{GuildId: testGuild, Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Guilds.SetAllowed(ctx, testGuild, true); err != nil {
+		t.Fatal(err)
+	}
+	err = repos.ChannelBindings.BindChannel(ctx, database.BindChannelDto{
+		GuildId:   testGuild,
+		ChannelId: testChannel,
+		Service:   configs.ServiceNames.Scheduler,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, session
+}
+
+// steps records the order things happen in across fakes.
+type steps struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (s *steps) add(step string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, step)
+}
+
+func (s *steps) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.list)
+}
+
+// fakeGateway is a connection that is always up.
+type fakeGateway struct {
+	steps *steps
+}
+
+func (f *fakeGateway) AddHandler(handler interface{}) func() {
+	return func() {}
+}
+
+func (f *fakeGateway) Open() error {
+	return nil
+}
+
+func (f *fakeGateway) HeartbeatLatency() time.Duration {
+	return 40 * time.Millisecond
+}
+
+func (f *fakeGateway) Ready() bool {
+	return true
+}
+
+func (f *fakeGateway) Close() error {
+	f.steps.add("gateway closed")
+	return nil
+}
+
+// fakeDatabase stands in for the database manager, there are no backups.
+type fakeDatabase struct {
+	steps *steps
+}
+
+func (f *fakeDatabase) Dialect() string {
+	return "sqlite"
+}
+
+func (f *fakeDatabase) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeDatabase) Backup(dir string) (string, error) {
+	return "", fmt.Errorf("no backups in tests")
+}
+
+func (f *fakeDatabase) PruneBackups(dir string, keep int) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeDatabase) Close() error {
+	f.steps.add("database closed")
+	return nil
+}
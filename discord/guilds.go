@@ -0,0 +1,260 @@
+package discord
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var roleMentionRe = regexp.MustCompile(`^<@&(\d+)>$`)
+
+// guildSettings returns the stored settings of a guild, nil for DMs and for
+// guilds that could not be loaded.
+func (b *DiscordBot) guildSettings(guildId string) *models.Guild {
+	if guildId == "" {
+		return nil
+	}
+	guild, err := b.Repos.Guilds.GetGuild(context.Background(), guildId)
+	if err != nil {
+		slog.Error("failed to load guild", "guild_id", guildId, "err", err)
+		return nil
+	}
+	return guild
+}
+
+// guildAllowed reports whether the bot may serve a guild. The master server
+// is always allowed.
+func (b *DiscordBot) guildAllowed(guildId string) bool {
+	if guildId == b.Config().DiscordMasterServerId {
+		return true
+	}
+	guild := b.guildSettings(guildId)
+	return guild != nil && guild.Allowed
+}
+
+// guildLocation is the time zone of a guild, JST when unset or unknown.
+func guildLocation(guild *models.Guild) *time.Location {
+	if guild != nil {
+		if loc, err := time.LoadLocation(guild.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return utils.JapanTimeNow().Location()
+}
+
+func guildLanguage(guild *models.Guild) string {
+	if guild == nil {
+		return ""
+	}
+	return guild.Language
+}
+
+// guildServices lists the services a guild has enabled, nil meaning all.
+func guildServices(guild *models.Guild) []string {
+	if guild == nil || guild.EnabledServices == "" {
+		return nil
+	}
+	return strings.Split(guild.EnabledServices, ",")
+}
+
+func (b *DiscordBot) isOwner(userId string) bool {
+	return b.Config().DiscordOwnerId != "" && userId == b.Config().DiscordOwnerId
+}
+
+// resolveOwner falls back to the owner of the bot application when no owner
+// is configured.
+func (b *DiscordBot) resolveOwner() {
+	if b.Config().DiscordOwnerId != "" {
+		return
+	}
+	app, err := b.Session.Application("@me")
+	if err != nil || app.Owner == nil {
+		slog.Warn("failed to resolve application owner, guild commands are disabled", "err", err)
+		return
+	}
+	b.reloadMu.Lock()
+	conf := *b.Config()
+	conf.DiscordOwnerId = app.Owner.ID
+	b.config.Store(&conf)
+	b.reloadMu.Unlock()
+	slog.Info("using application owner as bot owner", "user_id", app.Owner.ID)
+}
+
+func (b *DiscordBot) onGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
+	ctx, ok := b.startHandling()
+	if !ok {
+		return
+	}
+	defer b.inflight.Done()
+
+	_, err := b.Repos.Guilds.EnsureGuild(ctx, database.UpsertGuildDto{
+		GuildId: event.Guild.ID,
+		Name:    event.Guild.Name,
+		AddedBy: event.Guild.OwnerID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record guild", "guild_id", event.Guild.ID, "err", err)
+	}
+
+	if b.guildAllowed(event.Guild.ID) {
+		slog.InfoContext(ctx, "bot added to allowed server", "guild_id", event.Guild.ID, "guild", event.Guild.Name)
+		return
+	}
+	slog.WarnContext(ctx, "bot was added to unauthorized server, leaving", "guild_id", event.Guild.ID, "guild", event.Guild.Name)
+	b.Session.GuildLeave(event.Guild.ID)
+	if b.Config().DiscordOwnerId != "" {
+		notice := fmt.Sprintf("🚪 Left **%s** (`%s`), it is not on the allow-list. Use `!guild allow %s` and invite the bot again to allow it.",
+			event.Guild.Name, event.Guild.ID, event.Guild.ID)
+		if err := b.dmUser(b.Config().DiscordOwnerId, notice); err != nil {
+			slog.ErrorContext(ctx, "failed to notify the owner", "err", err)
+		}
+	}
+}
+
+func (b *DiscordBot) cmdGuilds(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	guilds, err := b.Repos.Guilds.GetAllGuilds(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load guilds: %s", err)
+	}
+	if len(guilds) == 0 {
+		return "📭 No servers recorded yet.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🏰 **Servers**\n")
+	for _, guild := range guilds {
+		status := "⛔"
+		if guild.Allowed || guild.GuildId == b.Config().DiscordMasterServerId {
+			status = "✅"
+		}
+		fmt.Fprintf(&sb, "%s **%s** `%s`\n", status, guild.Name, guild.GuildId)
+	}
+	return sb.String(), nil
+}
+
+func (b *DiscordBot) cmdGuild(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	if len(args) != 2 || (args[0] != "allow" && args[0] != "deny") {
+		return "", fmt.Errorf("usage: `!guild allow|deny <server id>`")
+	}
+	guildId := args[1]
+	if guildId == b.Config().DiscordMasterServerId {
+		return "", fmt.Errorf("the master server is always allowed")
+	}
+	if _, err := b.Repos.Guilds.EnsureGuild(ctx, database.UpsertGuildDto{GuildId: guildId, AddedBy: m.Author.ID}); err != nil {
+		return "", fmt.Errorf("failed to record server: %s", err)
+	}
+
+	allowed := args[0] == "allow"
+	if err := b.Repos.Guilds.SetAllowed(ctx, guildId, allowed); err != nil {
+		return "", fmt.Errorf("failed to update server: %s", err)
+	}
+	if allowed {
+		return fmt.Sprintf("✅ Server `%s` is allowed", guildId), nil
+	}
+	if err := b.Session.GuildLeave(guildId); err != nil {
+		slog.ErrorContext(ctx, "failed to leave guild", "guild_id", guildId, "err", err)
+	}
+	return fmt.Sprintf("⛔ Server `%s` is denied", guildId), nil
+}
+
+func (b *DiscordBot) cmdSettings(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	guild := b.guildSettings(m.GuildID)
+	if guild == nil {
+		return "", fmt.Errorf("no settings found for this server")
+	}
+	services := guild.EnabledServices
+	if services == "" {
+		services = "all"
+	}
+	adminRole := "none"
+	if guild.AdminRoleId != "" {
+		adminRole = fmt.Sprintf("<@&%s>", guild.AdminRoleId)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⚙️ **Server settings**\n")
+	fmt.Fprintf(&sb, "timezone: `%s`\n", guild.TimeZone)
+	fmt.Fprintf(&sb, "language: `%s`\n", guild.Language)
+	fmt.Fprintf(&sb, "services: %s\n", strings.ReplaceAll(services, ",", ", "))
+	fmt.Fprintf(&sb, "adminrole: %s\n", adminRole)
+	return sb.String(), nil
+}
+
+func (b *DiscordBot) cmdSet(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: `!set timezone|language|services|adminrole <value>`")
+	}
+	key, values := strings.ToLower(args[0]), args[1:]
+
+	var data database.GuildSettingsDto
+	switch key {
+	case "timezone":
+		if _, err := time.LoadLocation(values[0]); err != nil {
+			return "", fmt.Errorf("unknown time zone `%s`, use a name like `Asia/Tokyo`", values[0])
+		}
+		data.TimeZone = &values[0]
+	case "language":
+		language := strings.ToLower(values[0])
+		if language != "en" && language != "ja" {
+			return "", fmt.Errorf("language must be `en` or `ja`")
+		}
+		data.Language = &language
+	case "services":
+		enabled, err := b.parseServices(values)
+		if err != nil {
+			return "", err
+		}
+		data.EnabledServices = &enabled
+	case "adminrole":
+		roleId := values[0]
+		if match := roleMentionRe.FindStringSubmatch(roleId); match != nil {
+			roleId = match[1]
+		} else if roleId == "none" {
+			roleId = ""
+		}
+		data.AdminRoleId = &roleId
+	default:
+		return "", fmt.Errorf("unknown setting `%s`, available: timezone, language, services, adminrole", key)
+	}
+
+	if err := b.Repos.Guilds.UpdateSettings(ctx, m.GuildID, data); err != nil {
+		return "", fmt.Errorf("failed to update settings: %s", err)
+	}
+	if key == "timezone" || key == "language" {
+		b.updateNotifications(ctx)
+	}
+	return b.cmdSettings(ctx, m, nil)
+}
+
+// parseServices validates a list of service names into the stored comma
+// separated form, "all" enables every service.
+func (b *DiscordBot) parseServices(values []string) (string, error) {
+	known := b.IntentService.ServiceNames()
+	var enabled []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			switch {
+			case name == "":
+				continue
+			case name == "all":
+				return "", nil
+			case !slices.Contains(known, name):
+				return "", fmt.Errorf("unknown service `%s`, available: %s", name, strings.Join(known, ", "))
+			case !slices.Contains(enabled, name):
+				enabled = append(enabled, name)
+			}
+		}
+	}
+	slices.Sort(enabled)
+	return strings.Join(enabled, ","), nil
+}
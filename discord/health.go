@@ -0,0 +1,49 @@
+package discord
+
+import (
+	"biyobot/metrics"
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// serveMetrics serves the metrics and the health of what the bot depends
+// on, see metrics.Serve.
+func (b *DiscordBot) serveMetrics(ctx context.Context, addr string) {
+	if err := metrics.Serve(ctx, addr, b.healthChecks()); err != nil {
+		slog.Error("metrics server failed", "addr", addr, "err", err)
+	}
+}
+
+// healthChecks are what the bot depends on. The gateway and the database
+// are live checks, without the LLM the bot still runs commands so it is only
+// not ready. So is a bot draining its handlers on shutdown.
+func (b *DiscordBot) healthChecks() []metrics.Check {
+	return []metrics.Check{
+		{Name: "discord", Run: b.gatewayHealth, Live: true},
+		{Name: "database", Run: b.Database.Ping, Live: true},
+		{Name: "llm", Run: b.IntentService.Health},
+		{Name: "messages", Run: b.acceptingHealth},
+	}
+}
+
+// gatewayHealth reports whether the gateway connection is up, it is down
+// while discordgo reconnects.
+func (b *DiscordBot) gatewayHealth(ctx context.Context) error {
+	if b.gateway == nil {
+		return errors.New("gateway not connected")
+	}
+	if !b.gateway.Ready() {
+		return errors.New("gateway disconnected")
+	}
+	return nil
+}
+
+// acceptingHealth fails once shutdown has begun and new messages are
+// dropped.
+func (b *DiscordBot) acceptingHealth(ctx context.Context) error {
+	if b.shuttingDown() {
+		return errors.New("shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package discord
+
+import (
+	"biyobot/llm"
+	"biyobot/metrics"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// health asks the bot's health endpoint at path, returning the status code
+// and the result of each check.
+func health(t *testing.T, b *DiscordBot, path string) (int, map[string]string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler(b.healthChecks()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s answered %q", path, rec.Body.String())
+	}
+	return rec.Code, body.Checks
+}
+
+func TestReadyWithEverythingUp(t *testing.T) {
+	b, _ := newTestBot(t)
+	order := &steps{}
+	b.gateway, b.Database = &fakeGateway{steps: order}, &fakeDatabase{steps: order}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code, checks := health(t, b, path); code != http.StatusOK {
+			t.Errorf("%s = %d %v, want 200", path, code, checks)
+		}
+	}
+}
+
+func TestNotReadyWithoutTheLLM(t *testing.T) {
+	b, _ := newTestBot(t)
+	order := &steps{}
+	b.gateway, b.Database = &fakeGateway{steps: order}, &fakeDatabase{steps: order}
+	b.IntentService = llm.NewIntentService(&fakeLLM{down: errors.New("connection refused")},
+		b.Repos.Notifications, b.Repos.Expenses, b.Repos.ChannelBindings, b.Config())
+
+	code, checks := health(t, b, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d, want 503", code)
+	}
+	if checks["llm"] != "ollama unreachable: connection refused" {
+		t.Errorf("llm check = %q", checks["llm"])
+	}
+	if checks["discord"] != "ok" || checks["database"] != "ok" {
+		t.Errorf("checks = %v, want the others ok", checks)
+	}
+
+	// the bot still runs commands, a restart wouldn't help
+	if code, checks := health(t, b, "/healthz"); code != http.StatusOK || len(checks) != 2 {
+		t.Errorf("/healthz = %d %v, want 200 from the live checks only", code, checks)
+	}
+}
+
+func TestNotReadyWhileShuttingDown(t *testing.T) {
+	b, _ := newTestBot(t)
+	order := &steps{}
+	b.gateway, b.Database = &fakeGateway{steps: order}, &fakeDatabase{steps: order}
+	b.closing = true
+
+	if code, checks := health(t, b, "/readyz"); code != http.StatusServiceUnavailable || checks["messages"] != "shutting down" {
+		t.Errorf("/readyz = %d %v, want 503 while shutting down", code, checks)
+	}
+}
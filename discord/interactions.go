@@ -0,0 +1,120 @@
+package discord
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// componentHandler handles a button press. Custom ids are
+// "<prefix>:<args...>", args is what follows the prefix split on ":".
+type componentHandler func(b *DiscordBot, ctx context.Context, i *discordgo.InteractionCreate, args []string) (*discordgo.InteractionResponse, error)
+
+var componentHandlers = map[string]componentHandler{
+	"board": (*DiscordBot).onBoardComponent,
+	"rsvp":  (*DiscordBot).onRsvpComponent,
+	"poll":  (*DiscordBot).onPollComponent,
+}
+
+// slashCommand is a slash command along with its handler.
+type slashCommand struct {
+	Command *discordgo.ApplicationCommand
+	Run     func(b *DiscordBot, ctx context.Context, i *discordgo.InteractionCreate) (*discordgo.InteractionResponse, error)
+}
+
+var slashCommands = map[string]slashCommand{
+	pollCommand.Name: {Command: pollCommand, Run: (*DiscordBot).onPollCommand},
+}
+
+// registerSlashCommands replaces the bot's global slash commands with
+// slashCommands.
+func (b *DiscordBot) registerSlashCommands() {
+	commands := make([]*discordgo.ApplicationCommand, 0, len(slashCommands))
+	for _, cmd := range slashCommands {
+		commands = append(commands, cmd.Command)
+	}
+	if _, err := b.Session.ApplicationCommandBulkOverwrite(b.State.User.ID, "", commands); err != nil {
+		slog.Error("failed to register slash commands", "err", err)
+	}
+}
+
+func (b *DiscordBot) onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID != "" && !b.guildAllowed(i.GuildID) {
+		return
+	}
+	ctx, ok := b.startHandling()
+	if !ok {
+		return
+	}
+	defer b.inflight.Done()
+
+	var resp *discordgo.InteractionResponse
+	var err error
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		cmd, ok := slashCommands[i.ApplicationCommandData().Name]
+		if !ok {
+			slog.WarnContext(ctx, "unknown slash command", "command", i.ApplicationCommandData().Name)
+			return
+		}
+		slog.InfoContext(ctx, "slash command", "command", i.ApplicationCommandData().Name,
+			"guild_id", i.GuildID, "channel_id", i.ChannelID, "user_id", interactionUserId(i))
+		resp, err = cmd.Run(b, ctx, i)
+	case discordgo.InteractionMessageComponent:
+		parts := strings.Split(i.MessageComponentData().CustomID, ":")
+		handler, ok := componentHandlers[parts[0]]
+		if !ok {
+			slog.WarnContext(ctx, "unknown component", "custom_id", i.MessageComponentData().CustomID)
+			return
+		}
+		slog.InfoContext(ctx, "component pressed", "custom_id", i.MessageComponentData().CustomID,
+			"guild_id", i.GuildID, "channel_id", i.ChannelID, "user_id", interactionUserId(i))
+		resp, err = handler(b, ctx, i, parts[1:])
+	default:
+		return
+	}
+	if err != nil {
+		resp = ephemeralResponse("⚠️ " + err.Error())
+	}
+	if err := b.Session.InteractionRespond(i.Interaction, resp); err != nil {
+		slog.ErrorContext(ctx, "failed to respond to interaction", "err", err)
+	}
+}
+
+// ephemeralResponse replies to an interaction with a message only the user
+// who pressed the button sees.
+func ephemeralResponse(content string) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}
+}
+
+// interactionMessage adapts an interaction to the message based permission
+// checks.
+func interactionMessage(i *discordgo.InteractionCreate) *discordgo.MessageCreate {
+	author := i.User
+	if i.Member != nil {
+		author = i.Member.User
+	}
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        i.ID,
+		ChannelID: i.ChannelID,
+		GuildID:   i.GuildID,
+		Author:    author,
+		Member:    i.Member,
+	}}
+}
+
+// interactionUserId is the id of who pressed a button, in guilds and DMs.
+func interactionUserId(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
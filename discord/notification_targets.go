@@ -0,0 +1,284 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/metrics"
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+var (
+	channelMentionAnyRe = regexp.MustCompile(`<#(\d+)>`)
+	// asking for a thread takes a phrase, a title that merely contains the
+	// word doesn't
+	threadPhraseRe = regexp.MustCompile(`(?i)\b(in|as|start|open) (a )?(new )?thread\b|スレッド(で|に|を)`)
+)
+
+// parseTargets reads where a reminder should be delivered from the mentions
+// in the message that created it:
+//   - #channel or @role mentions post in that channel, or the current one,
+//     mentioning the mentioned users and roles
+//   - "in a thread" does the same, starting a thread from the post
+//   - only @user mentions DM each mentioned user
+//   - nothing mentioned DMs the creator, as do reminders made in DMs
+//
+// Reminders for users other than the author take the permission to mention
+// everyone, the bot would otherwise ping or DM anyone on anyone's behalf.
+func (b *DiscordBot) parseTargets(m *discordgo.Message) ([]configs.NotificationTarget, error) {
+	if m.GuildID == "" {
+		return nil, nil
+	}
+
+	var users, mentions []string
+	for _, user := range m.Mentions {
+		if user.ID != b.State.User.ID && !slices.Contains(users, user.ID) {
+			users = append(users, user.ID)
+			mentions = append(mentions, "<@"+user.ID+">")
+		}
+	}
+	if err := b.checkUserTargets(m, users); err != nil {
+		return nil, err
+	}
+	for _, roleId := range m.MentionRoles {
+		mentions = append(mentions, "<@&"+roleId+">")
+	}
+	var channels []string
+	for _, match := range channelMentionAnyRe.FindAllStringSubmatch(m.Content, -1) {
+		if !slices.Contains(channels, match[1]) {
+			channels = append(channels, match[1])
+		}
+	}
+	thread := threadPhraseRe.MatchString(m.Content)
+
+	if len(channels) == 0 && len(m.MentionRoles) == 0 && !thread {
+		var targets []configs.NotificationTarget
+		for _, userId := range users {
+			targets = append(targets, configs.NotificationTarget{Kind: "dm", ID: userId})
+		}
+		return targets, nil
+	}
+
+	if len(channels) == 0 {
+		channels = []string{m.ChannelID}
+	}
+	kind := "channel"
+	if thread {
+		kind = "thread"
+	}
+	var targets []configs.NotificationTarget
+	for _, channelId := range channels {
+		if err := b.checkPostAccess(m, channelId, kind, m.MentionRoles); err != nil {
+			return nil, err
+		}
+		targets = append(targets, configs.NotificationTarget{Kind: kind, ID: channelId, Mentions: mentions})
+	}
+	return targets, nil
+}
+
+// checkUserTargets makes sure the author may remind the other users, which
+// takes the permission to mention everyone where the reminder was made.
+func (b *DiscordBot) checkUserTargets(m *discordgo.Message, users []string) error {
+	if !slices.ContainsFunc(users, func(userId string) bool { return userId != m.Author.ID }) {
+		return nil
+	}
+	perms, err := b.Session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions in <#%s>: %s", m.ChannelID, err)
+	}
+	if perms&discordgo.PermissionMentionEveryone == 0 {
+		return fmt.Errorf("⛔ You can only set reminders for yourself here")
+	}
+	return nil
+}
+
+// checkPostAccess makes sure the author could post the reminder themselves:
+// the channel is in the same server, they may send (and start threads) in
+// it and may mention the roles.
+func (b *DiscordBot) checkPostAccess(m *discordgo.Message, channelId, kind string, roles []string) error {
+	if _, err := b.guildChannel(m.GuildID, channelId); err != nil {
+		return err
+	}
+
+	perms, err := b.Session.UserChannelPermissions(m.Author.ID, channelId)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions in <#%s>: %s", channelId, err)
+	}
+	needed := int64(discordgo.PermissionSendMessages)
+	if kind == "thread" {
+		needed |= discordgo.PermissionCreatePublicThreads
+	}
+	if perms&needed != needed {
+		return fmt.Errorf("⛔ You can't post in <#%s>", channelId)
+	}
+	if perms&discordgo.PermissionMentionEveryone != 0 {
+		return nil
+	}
+	for _, roleId := range roles {
+		role, err := b.State.Role(m.GuildID, roleId)
+		if err != nil || !role.Mentionable {
+			return fmt.Errorf("⛔ You can't mention <@&%s>", roleId)
+		}
+	}
+	return nil
+}
+
+func notificationTargets(n *models.Notification) []configs.NotificationTarget {
+	if n.Targets == "" {
+		return nil
+	}
+	var targets []configs.NotificationTarget
+	if err := json.Unmarshal([]byte(n.Targets), &targets); err != nil {
+		slog.Error("failed to parse notification targets", "notification_id", n.ID, "err", err)
+		return nil
+	}
+	return targets
+}
+
+// postsToChannel reports whether a notification is announced in a channel
+// rather than only DMed.
+func postsToChannel(n *models.Notification) bool {
+	return slices.ContainsFunc(notificationTargets(n), func(t configs.NotificationTarget) bool {
+		return t.Kind != "dm"
+	})
+}
+
+func formatTargets(targets []configs.NotificationTarget) string {
+	var parts []string
+	for _, t := range targets {
+		switch t.Kind {
+		case "dm":
+			parts = append(parts, "DM <@"+t.ID+">")
+		case "thread":
+			parts = append(parts, "thread in <#"+t.ID+">")
+		default:
+			parts = append(parts, "<#"+t.ID+">")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DeliverNotifications sends due notifications to their targets. A
+// notification stays queued for the next run only when every target failed,
+// so a flaky target doesn't repeat it everywhere else.
+func (b *DiscordBot) DeliverNotifications(ctx context.Context) error {
+	expiredNotifications, err := b.Repos.Notifications.GetAllExpiredNotifications(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get expired notifications: %w", err)
+	}
+	if len(expiredNotifications) == 0 {
+		return nil
+	}
+	var ids []uuid.UUID
+	for _, n := range expiredNotifications {
+		if n.Service == configs.ServiceNames.Polls {
+			if b.closeDuePoll(ctx, &n) {
+				ids = append(ids, n.ID)
+			}
+			continue
+		}
+		targets := notificationTargets(&n)
+		if n.IsEvent {
+			targets, err = b.eventTargets(ctx, &n)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to load attendees", "notification_id", n.ID, "err", err)
+				continue
+			}
+			if len(targets) == 0 {
+				// nobody is going, the event is over all the same
+				ids = append(ids, n.ID)
+				continue
+			}
+		} else if len(targets) == 0 {
+			metadata, err := utils.JsonToStruct[configs.DiscordMetadata](n.Metadata)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to parse notification metadata", "notification_id", n.ID, "err", err)
+				continue
+			}
+			targets = []configs.NotificationTarget{{Kind: "dm", ID: metadata.UserId}}
+		}
+
+		content := fmt.Sprintf(
+			"🔔 **%s**\n\n%s\n\n⏰ Scheduled for: %s",
+			n.Title,
+			n.Message,
+			n.NotifyAt.In(guildLocation(b.guildSettings(n.GuildId))).Format("Jan 02, 2006 15:04 MST"),
+		)
+		if n.Service != configs.ServiceNames.Scheduler {
+			content = fmt.Sprintf("🔔 **%s**\n\n%s", n.Title, n.Message)
+		}
+
+		delivered := 0
+		for _, target := range targets {
+			if err := b.deliver(target, n.Title, content); err != nil {
+				slog.ErrorContext(ctx, "failed to deliver notification", "notification_id", n.ID, "target", target.Kind, "target_id", target.ID, "err", err)
+				continue
+			}
+			delivered++
+		}
+		if delivered > 0 {
+			metrics.DispatchLag.Observe(max(0, time.Since(n.NotifyAt).Seconds()))
+			ids = append(ids, n.ID)
+		}
+	}
+
+	if len(ids) > 0 {
+		if err := b.Repos.Notifications.DeleteNotificationBatch(ctx, ids, database.SystemActor); err != nil {
+			slog.ErrorContext(ctx, "failed to delete processed notifications", "err", err)
+		}
+	}
+	return nil
+}
+
+func (b *DiscordBot) deliver(target configs.NotificationTarget, title, content string) error {
+	if target.Kind == "dm" {
+		channel, err := b.Session.UserChannelCreate(target.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create DM channel: %w", err)
+		}
+		sentMsg, err := b.Session.ChannelMessageSend(channel.ID, content)
+		if err != nil {
+			return err
+		}
+		b.tagMessageToBeDeleted(sentMsg, configs.MessageKinds.Reminder)
+		return nil
+	}
+
+	// only the mentions the reminder was made with may ping
+	allowed := &discordgo.MessageAllowedMentions{}
+	for _, mention := range target.Mentions {
+		if id, ok := strings.CutPrefix(mention, "<@&"); ok {
+			allowed.Roles = append(allowed.Roles, strings.TrimSuffix(id, ">"))
+		} else if id, ok := strings.CutPrefix(mention, "<@"); ok {
+			allowed.Users = append(allowed.Users, strings.TrimSuffix(id, ">"))
+		}
+	}
+	if len(target.Mentions) > 0 {
+		content = strings.Join(target.Mentions, " ") + "\n" + content
+	}
+	sentMsg, err := b.Session.ChannelMessageSendComplex(target.ID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: allowed,
+	})
+	if err != nil {
+		return err
+	}
+	if target.Kind == "thread" {
+		// the post starts the thread, it is kept along with it
+		_, err := b.Session.MessageThreadStart(target.ID, sentMsg.ID, truncate(title, 100), 24*60)
+		return err
+	}
+	b.tagMessageToBeDeleted(sentMsg, configs.MessageKinds.Reminder)
+	return nil
+}
@@ -0,0 +1,78 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseTargets(t *testing.T) {
+	alice := &discordgo.User{ID: "alice"}
+	bob := &discordgo.User{ID: "bob"}
+	tests := []struct {
+		name     string
+		content  string
+		mentions []*discordgo.User
+		perms    int64 // alice's, every permission when 0
+		want     []configs.NotificationTarget
+		err      bool
+	}{
+		{name: "nothing mentioned", content: "remind me at 5"},
+		{
+			name:     "self mention",
+			content:  "remind <@alice> at 5",
+			mentions: []*discordgo.User{alice},
+			perms:    discordgo.PermissionSendMessages,
+			want:     []configs.NotificationTarget{{Kind: "dm", ID: "alice"}},
+		},
+		{
+			name:     "others without permission",
+			content:  "remind <@bob> at 5",
+			mentions: []*discordgo.User{bob},
+			perms:    discordgo.PermissionSendMessages,
+			err:      true,
+		},
+		{
+			name:     "others with permission",
+			content:  "remind <@bob> at 5",
+			mentions: []*discordgo.User{bob},
+			want:     []configs.NotificationTarget{{Kind: "dm", ID: "bob"}},
+		},
+		{
+			name:    "thread phrase",
+			content: "post the standup reminder in a thread at 9",
+			want:    []configs.NotificationTarget{{Kind: "thread", ID: testChannel}},
+		},
+		{
+			name:    "thread phrase in Japanese",
+			content: "9時にスレッドでリマインド",
+			want:    []configs.NotificationTarget{{Kind: "thread", ID: testChannel}},
+		},
+		{name: "thread in a title", content: "remind me to reply to the thread about taxes"},
+		{name: "threads in a word", content: "buy threaded screws at 5"},
+		{
+			name:    "channel mention",
+			content: "announce the party in <#" + testChannel + "> at 8",
+			want:    []configs.NotificationTarget{{Kind: "channel", ID: testChannel}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, session := newTestBot(t)
+			if tt.perms != 0 {
+				session.perms["alice"] = tt.perms
+			}
+			m := messageOf(guildMeta("alice"), tt.content)
+			m.Mentions = tt.mentions
+			got, err := b.parseTargets(m)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package discord
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// commandsService is the pseudo service permissions for "!" commands are
+// granted on, with the command name as action.
+const commandsService = "commands"
+
+var userMentionRe = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// authorize checks a user may run action of service where the message was
+// sent. The owner and guild admins may always, everyone else is checked
+// against the guild's permission rules. DMs are checked against the rules
+// of the master server.
+func (b *DiscordBot) authorize(ctx context.Context, m *discordgo.MessageCreate, service, action string) error {
+	if b.isOwner(m.Author.ID) || b.isGuildAdmin(m) {
+		return nil
+	}
+
+	guildId, roles := m.GuildID, []string(nil)
+	if m.Member != nil {
+		roles = m.Member.Roles
+	}
+	if guildId == "" {
+		guildId = b.Config().DiscordMasterServerId
+		if member, err := b.Session.GuildMember(guildId, m.Author.ID); err == nil {
+			roles = member.Roles
+		}
+	}
+
+	rules, err := b.Repos.Permissions.GetGuildPermissions(ctx, guildId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load permissions", "guild_id", guildId, "err", err)
+		return fmt.Errorf("⛔ Permissions could not be checked, try again later")
+	}
+	if permitted(rules, guildId, m.Author.ID, roles, service, action) {
+		return nil
+	}
+	slog.InfoContext(ctx, "permission denied", "service", service, "action", action, "user_id", m.Author.ID,
+		"guild_id", m.GuildID, "channel_id", m.ChannelID)
+	return fmt.Errorf("⛔ You don't have permission to use `%s %s`", service, action)
+}
+
+// permitted evaluates rules for a user. Rules naming the user win over
+// rules of their roles, which win over @everyone rules, whose role id is the
+// guild's. Within the same tier a deny wins over an allow. Without a
+// matching rule, services and actions are open unless some allow rule names
+// them explicitly, which restricts them to the allowed subjects.
+func permitted(rules []models.Permission, guildId, userId string, roles []string, service, action string) bool {
+	var userRules, roleRules, everyoneRules []models.Permission
+	restricted := false
+	for _, rule := range rules {
+		if !ruleMatches(rule, service, action) {
+			continue
+		}
+		if rule.Effect == "allow" && rule.Service == service {
+			restricted = true
+		}
+		switch {
+		case rule.SubjectType == "user" && rule.SubjectId == userId:
+			userRules = append(userRules, rule)
+		case rule.SubjectType == "role" && rule.SubjectId == guildId:
+			everyoneRules = append(everyoneRules, rule)
+		case rule.SubjectType == "role" && slices.Contains(roles, rule.SubjectId):
+			roleRules = append(roleRules, rule)
+		}
+	}
+	for _, matched := range [][]models.Permission{userRules, roleRules, everyoneRules} {
+		if len(matched) == 0 {
+			continue
+		}
+		return !slices.ContainsFunc(matched, func(rule models.Permission) bool { return rule.Effect == "deny" })
+	}
+	return !restricted
+}
+
+func ruleMatches(rule models.Permission, service, action string) bool {
+	return (rule.Service == "*" || rule.Service == service) && (rule.Action == "*" || rule.Action == action)
+}
+
+// parseRule reads `<@role|@user|everyone> <service|*> [action|*]` from args.
+func (b *DiscordBot) parseRule(m *discordgo.MessageCreate, args []string) (database.RemovePermissionDto, error) {
+	rule := database.RemovePermissionDto{GuildId: m.GuildID, Action: "*"}
+	if len(args) < 2 || len(args) > 3 {
+		return rule, fmt.Errorf("usage: `<@role|@user|everyone> <service|*> [action|*]`")
+	}
+
+	switch subject := args[0]; {
+	case subject == "everyone" || subject == "@everyone":
+		rule.SubjectType, rule.SubjectId = "role", m.GuildID
+	case roleMentionRe.MatchString(subject):
+		rule.SubjectType, rule.SubjectId = "role", roleMentionRe.FindStringSubmatch(subject)[1]
+	case userMentionRe.MatchString(subject):
+		rule.SubjectType, rule.SubjectId = "user", userMentionRe.FindStringSubmatch(subject)[1]
+	default:
+		return rule, fmt.Errorf("`%s` is not a role or user mention", subject)
+	}
+
+	known := append(b.Services.Names(), commandsService)
+	slices.Sort(known)
+	rule.Service = args[1]
+	if rule.Service != "*" && !slices.Contains(known, rule.Service) {
+		return rule, fmt.Errorf("unknown service `%s`, available: %s", rule.Service, strings.Join(known, ", "))
+	}
+	if len(args) == 3 {
+		rule.Action = strings.ToLower(args[2])
+	}
+	return rule, nil
+}
+
+func (b *DiscordBot) cmdAllow(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	return b.setPermission(ctx, m, args, "allow")
+}
+
+func (b *DiscordBot) cmdDeny(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	return b.setPermission(ctx, m, args, "deny")
+}
+
+func (b *DiscordBot) setPermission(ctx context.Context, m *discordgo.MessageCreate, args []string, effect string) (string, error) {
+	rule, err := b.parseRule(m, args)
+	if err != nil {
+		return "", err
+	}
+	err = b.Repos.Permissions.SetPermission(ctx, database.SetPermissionDto{
+		GuildId:     rule.GuildId,
+		SubjectType: rule.SubjectType,
+		SubjectId:   rule.SubjectId,
+		Service:     rule.Service,
+		Action:      rule.Action,
+		Effect:      effect,
+		CreatedBy:   m.Author.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save permission: %s", err)
+	}
+	return "🔐 " + formatPermission(models.Permission{
+		GuildId:     rule.GuildId,
+		SubjectType: rule.SubjectType,
+		SubjectId:   rule.SubjectId,
+		Service:     rule.Service,
+		Action:      rule.Action,
+		Effect:      effect,
+	}), nil
+}
+
+func (b *DiscordBot) cmdRevoke(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	rule, err := b.parseRule(m, args)
+	if err != nil {
+		return "", err
+	}
+	removed, err := b.Repos.Permissions.RemovePermission(ctx, rule)
+	if err != nil {
+		return "", fmt.Errorf("failed to remove permission: %s", err)
+	}
+	if removed == 0 {
+		return "Nothing to revoke", nil
+	}
+	return "🗑️ Permission removed", nil
+}
+
+func (b *DiscordBot) cmdPermissions(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	rules, err := b.Repos.Permissions.GetGuildPermissions(ctx, m.GuildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load permissions: %s", err)
+	}
+	if len(rules) == 0 {
+		return "📭 No permission rules, every member may use every service.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔐 **Permissions**\n")
+	for _, rule := range rules {
+		sb.WriteString(formatPermission(rule) + "\n")
+	}
+	return sb.String(), nil
+}
+
+func formatPermission(rule models.Permission) string {
+	subject := fmt.Sprintf("<@%s>", rule.SubjectId)
+	switch {
+	case rule.SubjectType == "role" && rule.SubjectId == rule.GuildId:
+		subject = "everyone"
+	case rule.SubjectType == "role":
+		subject = fmt.Sprintf("<@&%s>", rule.SubjectId)
+	}
+	icon := "✅"
+	if rule.Effect == "deny" {
+		icon = "⛔"
+	}
+	return fmt.Sprintf("%s %s %s `%s %s`", icon, subject, rule.Effect, rule.Service, rule.Action)
+}
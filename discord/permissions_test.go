@@ -0,0 +1,40 @@
+package discord
+
+import (
+	"biyobot/models"
+	"testing"
+)
+
+func TestPermitted(t *testing.T) {
+	rule := func(subjectType, subjectId, service, effect string) models.Permission {
+		return models.Permission{SubjectType: subjectType, SubjectId: subjectId, Service: service, Action: "*", Effect: effect}
+	}
+	everyone := func(effect string) models.Permission { return rule("role", testGuild, "receipts", effect) }
+	role := func(effect string) models.Permission { return rule("role", "staff", "receipts", effect) }
+	user := func(effect string) models.Permission { return rule("user", "alice", "receipts", effect) }
+
+	tests := []struct {
+		name  string
+		rules []models.Permission
+		roles []string
+		want  bool
+	}{
+		{"no rules", nil, nil, true},
+		{"everyone denied", []models.Permission{everyone("deny")}, []string{"staff"}, false},
+		{"role allow over everyone deny", []models.Permission{everyone("deny"), role("allow")}, []string{"staff"}, true},
+		{"everyone deny without the role", []models.Permission{everyone("deny"), role("allow")}, nil, false},
+		{"role deny over everyone allow", []models.Permission{everyone("allow"), role("deny")}, []string{"staff"}, false},
+		{"deny wins among roles", []models.Permission{role("allow"), rule("role", "muted", "receipts", "deny")}, []string{"staff", "muted"}, false},
+		{"user allow over role deny", []models.Permission{role("deny"), user("allow")}, []string{"staff"}, true},
+		{"user deny over everyone allow", []models.Permission{everyone("allow"), user("deny")}, nil, false},
+		{"allow to others restricts", []models.Permission{rule("role", "other", "receipts", "allow")}, []string{"staff"}, false},
+		{"wildcard allow does not restrict", []models.Permission{rule("role", "other", "*", "allow")}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := permitted(tt.rules, testGuild, "alice", tt.roles, "receipts", "add"); got != tt.want {
+				t.Errorf("permitted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
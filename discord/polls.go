@@ -0,0 +1,320 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/llm"
+	"biyobot/models"
+	"biyobot/services/polls"
+	"biyobot/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+const pollBarWidth = 10
+
+// pollCommand is the /poll slash command, the same as asking in a message
+// but without the LLM guessing.
+var pollCommand = &discordgo.ApplicationCommand{
+	Name:        "poll",
+	Description: "Start a poll members vote on with buttons",
+	Options: []*discordgo.ApplicationCommandOption{
+		{Type: discordgo.ApplicationCommandOptionString, Name: "question", Description: "What is being decided", Required: true},
+		{Type: discordgo.ApplicationCommandOptionString, Name: "options", Description: "Choices separated by commas", Required: true},
+		{Type: discordgo.ApplicationCommandOptionString, Name: "closes", Description: "When voting ends, e.g. 2h, 18:00 or 6pm"},
+		{Type: discordgo.ApplicationCommandOptionBoolean, Name: "multi", Description: "Allow picking several options"},
+		{Type: discordgo.ApplicationCommandOptionBoolean, Name: "anonymous", Description: "Show tallies only, not who voted"},
+	},
+}
+
+// handlePolls creates a poll asked for in a message.
+func (b *DiscordBot) handlePolls(ctx context.Context, intent *llm.IntentResult, discordMeta *configs.DiscordMetadata) error {
+	question := utils.ParamString(intent.Params, "question")
+	if question == "" {
+		question = "Which one?"
+	}
+	_, err := b.createPoll(ctx, polls.Input{
+		Question:    question,
+		Options:     utils.ParamList(intent.Params, "options"),
+		MultiChoice: utils.ParamBool(intent.Params, "multi_choice"),
+		Anonymous:   utils.ParamBool(intent.Params, "anonymous"),
+		Closes:      utils.ParamString(intent.Params, "closes_at"),
+	}, discordMeta)
+	return err
+}
+
+// onPollCommand handles /poll.
+func (b *DiscordBot) onPollCommand(ctx context.Context, i *discordgo.InteractionCreate) (*discordgo.InteractionResponse, error) {
+	if err := b.authorize(ctx, interactionMessage(i), configs.ServiceNames.Polls, "create"); err != nil {
+		return nil, err
+	}
+	if guild := b.guildSettings(i.GuildID); guild != nil {
+		if enabled := guildServices(guild); enabled != nil && !slices.Contains(enabled, configs.ServiceNames.Polls) {
+			return nil, fmt.Errorf("polls are disabled on this server")
+		}
+	}
+
+	input := polls.Input{}
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "question":
+			input.Question = opt.StringValue()
+		case "options":
+			input.Options = strings.Split(opt.StringValue(), ",")
+		case "closes":
+			input.Closes = opt.StringValue()
+		case "multi":
+			input.MultiChoice = opt.BoolValue()
+		case "anonymous":
+			input.Anonymous = opt.BoolValue()
+		}
+	}
+	user := interactionMessage(i).Author
+	output, err := b.createPoll(ctx, input, &configs.DiscordMetadata{
+		ChannelId: i.ChannelID,
+		GuildId:   i.GuildID,
+		UserId:    user.ID,
+		Username:  user.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ephemeralResponse(fmt.Sprintf("📊 Poll **%s** posted", output.Poll.Question)), nil
+}
+
+// createPoll saves a poll and posts it where it was asked for.
+func (b *DiscordBot) createPoll(ctx context.Context, input polls.Input, discordMeta *configs.DiscordMetadata) (*polls.Output, error) {
+	metadata, err := utils.StructToJson(discordMeta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize discord metadata: %s", err)
+	}
+	input.Action = "create"
+	input.GuildId = discordMeta.GuildId
+	input.ChannelId = discordMeta.ChannelId
+	input.UserId = discordMeta.UserId
+	input.Metadata = metadata
+	if guild := b.guildSettings(discordMeta.GuildId); guild != nil {
+		input.TimeZone = guild.TimeZone
+	}
+
+	output, err := b.runPolls(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	embed, components := b.renderPoll(output)
+	msg, err := b.Session.ChannelMessageSendComplex(discordMeta.ChannelId, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to post poll: %s", err)
+	}
+	if err := b.Repos.Polls.SetPollMessage(ctx, output.Poll.ID, msg.ChannelID, msg.ID); err != nil {
+		slog.ErrorContext(ctx, "failed to save poll message", "poll_id", output.Poll.ID, "err", err)
+	}
+	return output, nil
+}
+
+// onPollComponent handles the poll buttons, args are the poll id and either
+// "vote" with the option index or "close".
+func (b *DiscordBot) onPollComponent(ctx context.Context, i *discordgo.InteractionCreate, args []string) (*discordgo.InteractionResponse, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("malformed poll button")
+	}
+	pollId, err := uuid.Parse(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed poll button")
+	}
+
+	var output *polls.Output
+	switch args[1] {
+	case "vote":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("malformed poll button")
+		}
+		option, _ := strconv.Atoi(args[2])
+		output, err = b.runPolls(ctx, polls.Input{Action: "vote", ID: pollId.String(), UserId: interactionUserId(i), Option: option})
+	case "close":
+		poll, err := b.Repos.Polls.GetPoll(ctx, pollId)
+		if err != nil {
+			return nil, fmt.Errorf("this poll no longer exists")
+		}
+		m := interactionMessage(i)
+		if poll.UserId != m.Author.ID && !b.isOwner(m.Author.ID) && !b.isGuildAdmin(m) {
+			return nil, fmt.Errorf("only whoever started the poll can close it")
+		}
+		output, err = b.closePoll(ctx, pollId, false)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("malformed poll button")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	embed, components := b.renderPoll(output)
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	}, nil
+}
+
+// closePoll closes a poll and announces its results in the poll's channel.
+// The poll message itself is edited when edit is set, a button press
+// updates it through its response instead.
+func (b *DiscordBot) closePoll(ctx context.Context, pollId uuid.UUID, edit bool) (*polls.Output, error) {
+	output, err := b.runPolls(ctx, polls.Input{Action: "close", ID: pollId.String()})
+	if err != nil {
+		return nil, err
+	}
+	poll := output.Poll
+	if edit && poll.MessageId != "" {
+		embed, components := b.renderPoll(output)
+		_, err := b.Session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         poll.MessageId,
+			Channel:    poll.ChannelId,
+			Embeds:     &[]*discordgo.MessageEmbed{embed},
+			Components: &components,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to edit closed poll", "poll_id", poll.ID, "err", err)
+		}
+	}
+
+	send := &discordgo.MessageSend{Content: output.ResultMessage}
+	if poll.MessageId != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: poll.MessageId, ChannelID: poll.ChannelId}
+	}
+	if _, err := b.Session.ChannelMessageSendComplex(poll.ChannelId, send); err != nil {
+		slog.ErrorContext(ctx, "failed to announce poll results", "poll_id", poll.ID, "err", err)
+	}
+	return output, nil
+}
+
+func (b *DiscordBot) runPolls(ctx context.Context, input polls.Input) (*polls.Output, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build polls input: %s", err)
+	}
+	var output polls.Output
+	if err := b.Services.Run(ctx, configs.ServiceNames.Polls, data).Decode(&output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// renderPoll renders the live tally of a poll with a button per option and
+// one to close it, closed polls have no buttons.
+func (b *DiscordBot) renderPoll(output *polls.Output) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	poll := output.Poll
+	total := 0
+	for _, t := range output.Tally {
+		total += t.Votes
+	}
+
+	var sb strings.Builder
+	for n, t := range output.Tally {
+		filled := 0
+		if total > 0 {
+			filled = t.Votes * pollBarWidth / total
+		}
+		fmt.Fprintf(&sb, "**%d. %s**\n`%s%s` %d (%d%%)\n", n+1, t.Option,
+			strings.Repeat("▓", filled), strings.Repeat("░", pollBarWidth-filled), t.Votes, percent(t.Votes, total))
+		if len(t.Voters) > 0 {
+			voters := make([]string, len(t.Voters))
+			for v, userId := range t.Voters {
+				voters[v] = "<@" + userId + ">"
+			}
+			sb.WriteString(strings.Join(voters, " ") + "\n")
+		}
+	}
+
+	mode := []string{"Single choice"}
+	if poll.MultiChoice {
+		mode[0] = "Multiple choice"
+	}
+	if poll.Anonymous {
+		mode = append(mode, "anonymous")
+	}
+	switch {
+	case poll.Closed:
+		mode = append(mode, "closed")
+	case poll.ClosesAt != nil:
+		loc := guildLocation(b.guildSettings(poll.GuildId))
+		mode = append(mode, "closes "+poll.ClosesAt.In(loc).Format("Jan 02 15:04 MST"))
+	}
+
+	title := "📊 " + poll.Question
+	if poll.Closed {
+		title = "🔒 " + poll.Question
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: truncate(sb.String(), 4096),
+		Color:       boardColor,
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%s · %d votes", strings.Join(mode, " · "), total)},
+	}
+	if poll.Closed {
+		return embed, []discordgo.MessageComponent{}
+	}
+
+	// Discord allows 5 buttons per row
+	var components []discordgo.MessageComponent
+	var row discordgo.ActionsRow
+	for n, option := range output.Options {
+		if len(row.Components) == 5 {
+			components = append(components, row)
+			row = discordgo.ActionsRow{}
+		}
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    option,
+			Style:    discordgo.PrimaryButton,
+			CustomID: fmt.Sprintf("poll:%s:vote:%d", poll.ID, n),
+		})
+	}
+	components = append(components, row, discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: "Close poll", Style: discordgo.DangerButton, CustomID: fmt.Sprintf("poll:%s:close", poll.ID)},
+	}})
+	return embed, components
+}
+
+func percent(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return n * 100 / total
+}
+
+// closeDuePoll closes the poll of a due closing notification and reports
+// whether the notification is done with. It fires up to ten minutes early
+// with the other notifications, so it waits until the poll is due.
+func (b *DiscordBot) closeDuePoll(ctx context.Context, n *models.Notification) bool {
+	if n.NotifyAt.After(utils.JapanTimeNow()) {
+		return false
+	}
+	poll, err := b.Repos.Polls.GetPollByNotification(ctx, n.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load poll of notification", "notification_id", n.ID, "err", err)
+		return false
+	}
+	if poll == nil || poll.Closed {
+		return true
+	}
+	if _, err := b.closePoll(ctx, poll.ID, true); err != nil {
+		slog.ErrorContext(ctx, "failed to close poll", "poll_id", poll.ID, "err", err)
+		return false
+	}
+	return true
+}
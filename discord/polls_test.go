@@ -0,0 +1,312 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"biyobot/services/polls"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+// newPollBot returns a test bot running the polls service.
+func newPollBot(t *testing.T) (*DiscordBot, *fakeSession) {
+	t.Helper()
+	b, session := newTestBot(t)
+	b.Services.Register(configs.ServiceNames.Polls, polls.NewService(b.Repos.Polls, b.Repos.Notifications))
+	return b, session
+}
+
+func startPoll(t *testing.T, b *DiscordBot, input polls.Input) uuid.UUID {
+	t.Helper()
+	output, err := b.createPoll(context.Background(), input, guildMeta("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output.Poll.ID
+}
+
+// pressPoll presses a poll button as userId, args follow the poll id in the
+// button's custom id.
+func pressPoll(t *testing.T, b *DiscordBot, pollId uuid.UUID, userId string, args ...string) (*discordgo.MessageEmbed, error) {
+	t.Helper()
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		GuildID:   testGuild,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: userId}},
+		Data:      discordgo.MessageComponentInteractionData{CustomID: "poll:" + pollId.String() + ":" + strings.Join(args, ":")},
+	}}
+	resp, err := b.onPollComponent(context.Background(), i, append([]string{pollId.String()}, args...))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data.Embeds[0], nil
+}
+
+func vote(t *testing.T, b *DiscordBot, pollId uuid.UUID, userId string, option int) *discordgo.MessageEmbed {
+	t.Helper()
+	embed, err := pressPoll(t, b, pollId, userId, "vote", strconv.Itoa(option))
+	if err != nil {
+		t.Fatalf("%s voting %d: %v", userId, option, err)
+	}
+	return embed
+}
+
+// votesFor returns the voters of each option, sorted.
+func votesFor(t *testing.T, b *DiscordBot, pollId uuid.UUID, options int) [][]string {
+	t.Helper()
+	votes, err := b.Repos.Polls.GetVotes(context.Background(), pollId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	voters := make([][]string, options)
+	for _, v := range votes {
+		voters[v.Option] = append(voters[v.Option], v.UserId)
+	}
+	for _, list := range voters {
+		slices.Sort(list)
+	}
+	return voters
+}
+
+func TestPollCommand(t *testing.T) {
+	b, session := newPollBot(t)
+	ctx := context.Background()
+	option := func(name string, value any) *discordgo.ApplicationCommandInteractionDataOption {
+		kind := discordgo.ApplicationCommandOptionString
+		if _, ok := value.(bool); ok {
+			kind = discordgo.ApplicationCommandOptionBoolean
+		}
+		return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: kind, Value: value}
+	}
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   testGuild,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "alice"}},
+		Data: discordgo.ApplicationCommandInteractionData{Name: "poll", Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			option("question", "Lunch?"),
+			option("options", "ramen, sushi,ramen, ,curry"),
+			option("closes", "2h"),
+			option("multi", true),
+		}},
+	}}
+
+	resp, err := b.onPollCommand(ctx, i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Data.Content, "Poll **Lunch?** posted") || resp.Data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Errorf("answered %+v", resp.Data)
+	}
+
+	if len(session.sent) != 1 || len(session.sent[0].Embeds) != 1 {
+		t.Fatalf("posted %d messages, want the poll", len(session.sent))
+	}
+	post := session.sent[0]
+	if post.ChannelID != testChannel || post.Embeds[0].Title != "📊 Lunch?" {
+		t.Errorf("posted %q in %s", post.Embeds[0].Title, post.ChannelID)
+	}
+	var labels, customIds []string
+	for _, row := range post.Components {
+		for _, button := range row.(discordgo.ActionsRow).Components {
+			labels = append(labels, button.(discordgo.Button).Label)
+			customIds = append(customIds, button.(discordgo.Button).CustomID)
+		}
+	}
+	if want := []string{"ramen", "sushi", "curry", "Close poll"}; !slices.Equal(labels, want) {
+		t.Errorf("buttons %q, want %q", labels, want)
+	}
+	if footer := post.Embeds[0].Footer.Text; !strings.HasPrefix(footer, "Multiple choice · closes ") {
+		t.Errorf("footer %q", footer)
+	}
+
+	pollId, err := uuid.Parse(strings.Split(customIds[0], ":")[1])
+	if err != nil {
+		t.Fatalf("button %q: %v", customIds[0], err)
+	}
+	poll, err := b.Repos.Polls.GetPoll(ctx, pollId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.MessageId != post.ID || poll.ChannelId != testChannel {
+		t.Errorf("poll tied to %s in %s, want its post", poll.MessageId, poll.ChannelId)
+	}
+	if poll.UserId != "alice" || !poll.MultiChoice || poll.Anonymous || poll.NotificationId == nil {
+		t.Errorf("stored %+v", poll)
+	}
+	if wait := time.Until(*poll.ClosesAt); wait > 2*time.Hour || wait < 2*time.Hour-time.Minute {
+		t.Errorf("closes in %s, want 2h", wait)
+	}
+	closing, err := b.Repos.Notifications.GetNotification(ctx, *poll.NotificationId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closing.Service != configs.ServiceNames.Polls || !closing.NotifyAt.Equal(*poll.ClosesAt) {
+		t.Errorf("closing notification %+v", closing)
+	}
+}
+
+func TestPollCommandRejectsTooFewOptions(t *testing.T) {
+	b, session := newPollBot(t)
+	_, err := b.createPoll(context.Background(), polls.Input{Question: "Lunch?", Options: []string{"ramen", " ramen "}}, guildMeta("alice"))
+	if err == nil {
+		t.Fatal("created a poll with a single option")
+	}
+	if len(session.sent) != 0 {
+		t.Errorf("posted %d messages", len(session.sent))
+	}
+}
+
+func TestPollSingleChoiceVoting(t *testing.T) {
+	b, _ := newPollBot(t)
+	pollId := startPoll(t, b, polls.Input{Question: "Lunch?", Options: []string{"ramen", "sushi"}})
+
+	vote(t, b, pollId, "bob", 0)
+	vote(t, b, pollId, "carol", 1)
+	// picking another option moves the vote
+	embed := vote(t, b, pollId, "bob", 1)
+	if got := votesFor(t, b, pollId, 2); len(got[0]) != 0 || !slices.Equal(got[1], []string{"bob", "carol"}) {
+		t.Errorf("votes = %v, want both on sushi", got)
+	}
+	if !strings.Contains(embed.Description, "<@bob> <@carol>") && !strings.Contains(embed.Description, "<@carol> <@bob>") {
+		t.Errorf("voters not shown:\n%s", embed.Description)
+	}
+	if !strings.Contains(embed.Description, "2 (100%)") || !strings.HasSuffix(embed.Footer.Text, "· 2 votes") {
+		t.Errorf("tally not shown:\n%s\n%s", embed.Description, embed.Footer.Text)
+	}
+
+	// pressing the same option again takes the vote back
+	embed = vote(t, b, pollId, "bob", 1)
+	if got := votesFor(t, b, pollId, 2); !slices.Equal(got[1], []string{"carol"}) {
+		t.Errorf("votes = %v, want only carol's", got)
+	}
+	if !strings.HasSuffix(embed.Footer.Text, "· 1 votes") {
+		t.Errorf("footer %q", embed.Footer.Text)
+	}
+
+	if _, err := pressPoll(t, b, pollId, "bob", "vote", "7"); err == nil {
+		t.Error("voted for an option the poll doesn't have")
+	}
+}
+
+func TestPollMultiChoiceAnonymousVoting(t *testing.T) {
+	b, _ := newPollBot(t)
+	pollId := startPoll(t, b, polls.Input{Question: "Which days?", Options: []string{"Sat", "Sun", "Mon"}, MultiChoice: true, Anonymous: true})
+
+	vote(t, b, pollId, "bob", 0)
+	vote(t, b, pollId, "bob", 1)
+	embed := vote(t, b, pollId, "carol", 1)
+	got := votesFor(t, b, pollId, 3)
+	if !slices.Equal(got[0], []string{"bob"}) || !slices.Equal(got[1], []string{"bob", "carol"}) || len(got[2]) != 0 {
+		t.Errorf("votes = %v, want bob on Sat and Sun, carol on Sun", got)
+	}
+	if strings.Contains(embed.Description, "<@") {
+		t.Errorf("anonymous poll shows voters:\n%s", embed.Description)
+	}
+	if !strings.Contains(embed.Description, "2 (66%)") || !strings.Contains(embed.Description, "1 (33%)") {
+		t.Errorf("tally not shown:\n%s", embed.Description)
+	}
+	if embed.Footer.Text != "Multiple choice · anonymous · 3 votes" {
+		t.Errorf("footer %q", embed.Footer.Text)
+	}
+}
+
+func TestPollClosesOnSchedule(t *testing.T) {
+	b, session := newPollBot(t)
+	ctx := context.Background()
+	pollId := startPoll(t, b, polls.Input{Question: "Lunch?", Options: []string{"ramen", "sushi"}, Closes: "1h"})
+	vote(t, b, pollId, "bob", 0)
+	vote(t, b, pollId, "carol", 0)
+	vote(t, b, pollId, "dave", 1)
+	poll, err := b.Repos.Polls.GetPoll(ctx, pollId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// time passes, the closing notification comes due
+	moveClosing := func(at time.Time) {
+		t.Helper()
+		closing, err := b.Repos.Notifications.GetNotification(ctx, *poll.NotificationId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = b.Repos.Notifications.EditNotification(ctx, database.EditNotificationDto{
+			ID: closing.ID.String(), Service: closing.Service, Metadata: closing.Metadata, NotifyAt: at,
+			Title: closing.Title, Message: closing.Message, Actor: database.SystemActor,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// notifications are picked up ten minutes early, the poll waits
+	moveClosing(time.Now().Add(5 * time.Minute))
+	if err := b.DeliverNotifications(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if poll, _ := b.Repos.Polls.GetPoll(ctx, pollId); poll.Closed {
+		t.Fatal("closed before its time")
+	}
+
+	moveClosing(time.Now().Add(-time.Second))
+	if err := b.DeliverNotifications(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if poll, _ := b.Repos.Polls.GetPoll(ctx, pollId); !poll.Closed {
+		t.Fatal("still open after closing")
+	}
+	if len(session.edits) != 1 || (*session.edits[0].Embeds)[0].Title != "🔒 Lunch?" || len(*session.edits[0].Components) != 0 {
+		t.Errorf("poll post not updated to closed: %v", session.edits)
+	}
+	results := session.sentTo(testChannel)
+	if want := "📊 **Lunch?** closed, **ramen** wins with 2 votes."; len(results) != 2 || results[1] != want {
+		t.Errorf("announced %q, want %q", results, want)
+	}
+	if expired, _ := b.Repos.Notifications.GetAllExpiredNotifications(ctx); len(expired) != 0 {
+		t.Errorf("closing notification still queued")
+	}
+
+	if _, err := pressPoll(t, b, pollId, "erin", "vote", "1"); err == nil {
+		t.Error("voted on a closed poll")
+	}
+}
+
+func TestPollClosedEarlyByItsCreator(t *testing.T) {
+	b, session := newPollBot(t)
+	ctx := context.Background()
+	pollId := startPoll(t, b, polls.Input{Question: "Lunch?", Options: []string{"ramen", "sushi"}, Closes: "1h"})
+	vote(t, b, pollId, "bob", 0)
+	vote(t, b, pollId, "carol", 1)
+
+	session.perms["bob"] = discordgo.PermissionSendMessages
+	if _, err := pressPoll(t, b, pollId, "bob", "close"); err == nil {
+		t.Fatal("bob closed alice's poll")
+	}
+	session.perms["alice"] = discordgo.PermissionSendMessages
+	embed, err := pressPoll(t, b, pollId, "alice", "close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embed.Title != "🔒 Lunch?" {
+		t.Errorf("closed poll titled %q", embed.Title)
+	}
+	results := session.sentTo(testChannel)
+	if want := "📊 **Lunch?** closed, it's a tie between **ramen** and **sushi** with 1 vote each."; results[len(results)-1] != want {
+		t.Errorf("announced %q, want %q", results[len(results)-1], want)
+	}
+
+	poll, err := b.Repos.Polls.GetPoll(ctx, pollId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Repos.Notifications.GetNotification(ctx, *poll.NotificationId); err == nil {
+		t.Error("the scheduled closing was kept")
+	}
+}
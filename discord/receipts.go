@@ -0,0 +1,87 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/llm"
+	"biyobot/services/receipts"
+	"biyobot/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// handles the receipts service: scanning uploaded receipts, corrections,
+// reports, budgets and exports
+func (b *DiscordBot) handleReceipts(ctx context.Context, intent *llm.IntentResult, discordMeta *configs.DiscordMetadata) error {
+	metadata, err := utils.StructToJson(discordMeta)
+	if err != nil {
+		return fmt.Errorf("failed to serialize discord metadata: %s", err)
+	}
+	input, _ := json.Marshal(receipts.Input{
+		Action:      intent.Action,
+		ID:          utils.ParamString(intent.Params, "expense_id"),
+		Attachments: intent.Attachments,
+		GuildId:     discordMeta.GuildId,
+		UserId:      discordMeta.UserId,
+		Metadata:    metadata,
+		Merchant:    utils.ParamString(intent.Params, "merchant"),
+		Date:        utils.ParamString(intent.Params, "date"),
+		Total:       utils.ParamString(intent.Params, "total"),
+		Currency:    utils.ParamString(intent.Params, "currency"),
+		Category:    utils.ParamString(intent.Params, "category"),
+		Month:       utils.ParamString(intent.Params, "month"),
+		From:        utils.ParamString(intent.Params, "from"),
+		To:          utils.ParamString(intent.Params, "to"),
+		GroupBy:     utils.ParamString(intent.Params, "group_by"),
+		Format:      utils.ParamString(intent.Params, "format"),
+	})
+	var output receipts.Output
+	if err := b.Services.Run(ctx, configs.ServiceNames.Receipts, input).Decode(&output); err != nil {
+		return err
+	}
+
+	// reports and exports are worth keeping around, only confirmations expire
+	if output.File != nil {
+		_, err := b.Session.ChannelMessageSendComplex(discordMeta.ChannelId, &discordgo.MessageSend{
+			Content: output.ResultMessage,
+			Files: []*discordgo.File{{
+				Name:        output.File.Name,
+				ContentType: output.File.ContentType,
+				Reader:      bytes.NewReader(output.File.Data),
+			}},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to send export", "err", err)
+		}
+		return nil
+	}
+
+	// scanned receipts are the ledger record, reply to the upload and keep it
+	if intent.Action == "add" {
+		_, err := b.Session.ChannelMessageSendReply(discordMeta.ChannelId, output.ResultMessage, &discordgo.MessageReference{
+			MessageID: discordMeta.MessageId,
+			ChannelID: discordMeta.ChannelId,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to send receipt reply", "err", err)
+		}
+		return nil
+	}
+
+	msg, err := b.Session.ChannelMessageSend(discordMeta.ChannelId, output.ResultMessage)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send reply", "err", err)
+		return nil
+	}
+	if intent.Action == "summary" || (intent.Action == "budget" && utils.ParamString(intent.Params, "total") == "") {
+		return nil
+	}
+	if err := b.tagMessageToBeDeleted(msg, configs.MessageKinds.Reply); err != nil {
+		slog.ErrorContext(ctx, "failed to tag message for deletion", "err", err)
+	}
+	return nil
+}
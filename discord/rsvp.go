@@ -0,0 +1,251 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+)
+
+var rsvpStatuses = []struct {
+	Status string
+	Label  string
+	Style  discordgo.ButtonStyle
+}{
+	{"going", "Going", discordgo.SuccessButton},
+	{"maybe", "Maybe", discordgo.SecondaryButton},
+	{"declined", "Not going", discordgo.DangerButton},
+}
+
+// postRsvp posts an event with its RSVP buttons, the creator is going.
+func (b *DiscordBot) postRsvp(ctx context.Context, notification *models.Notification, channelId string) error {
+	_, err := b.Repos.Attendees.Rsvp(ctx, database.RsvpDto{
+		NotificationId: notification.ID,
+		UserId:         notification.UserId,
+		Status:         "going",
+		Capacity:       notification.Capacity,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add the creator as attendee: %s", err)
+	}
+	attendees, err := b.Repos.Attendees.GetAttendees(ctx, notification.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load attendees: %s", err)
+	}
+
+	embed, components := renderRsvp(notification, attendees, guildLocation(b.guildSettings(notification.GuildId)))
+	msg, err := b.Session.ChannelMessageSendComplex(channelId, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to post event: %s", err)
+	}
+	if err := b.Repos.Notifications.SetRsvpMessage(ctx, notification.ID, channelId, msg.ID); err != nil {
+		return err
+	}
+	notification.RsvpChannelId, notification.RsvpMessageId = channelId, msg.ID
+	return nil
+}
+
+// countdown is what an event's RSVP post says ahead of the event, by how
+// long before it starts.
+var countdown = []struct {
+	before  time.Duration
+	content string
+}{
+	{24 * time.Hour, "⏳ Starts in 24 hours"},
+	{time.Hour, "⏳ Starts in an hour"},
+	{0, "🔔 Starting now"},
+}
+
+// scheduleCountdown pins an event's RSVP post a day before the event, or
+// right away when it is sooner, counts down on it and unpins it once the
+// event starts. Stages already past are skipped.
+func (b *DiscordBot) scheduleCountdown(ctx context.Context, notification *models.Notification) error {
+	now := time.Now()
+	actions := []ScheduleActionDto{{Action: "pin", At: now}}
+	if pinAt := notification.NotifyAt.Add(-24 * time.Hour); pinAt.After(now) {
+		actions[0].At = pinAt
+	}
+	for _, stage := range countdown {
+		if at := notification.NotifyAt.Add(-stage.before); !at.Before(now) {
+			actions = append(actions, ScheduleActionDto{Action: "edit", At: at, Payload: ActionPayload{Content: stage.content}})
+		}
+	}
+	actions = append(actions, ScheduleActionDto{Action: "unpin", At: notification.NotifyAt})
+
+	for _, action := range actions {
+		action.ChannelId = notification.RsvpChannelId
+		action.MessageId = notification.RsvpMessageId
+		action.RefId = notification.ID.String()
+		if err := b.scheduleAction(ctx, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onRsvpComponent handles the RSVP buttons, args are the notification id
+// and the answer.
+func (b *DiscordBot) onRsvpComponent(ctx context.Context, i *discordgo.InteractionCreate, args []string) (*discordgo.InteractionResponse, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("malformed RSVP button")
+	}
+	notificationId, err := uuid.Parse(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed RSVP button")
+	}
+	notification, err := b.Repos.Notifications.GetNotification(ctx, notificationId)
+	if err != nil {
+		return nil, fmt.Errorf("this event is over or was cancelled")
+	}
+
+	userId := interactionUserId(i)
+	result, err := b.Repos.Attendees.Rsvp(ctx, database.RsvpDto{
+		NotificationId: notification.ID,
+		UserId:         userId,
+		Status:         args[1],
+		Capacity:       notification.Capacity,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save your answer: %s", err)
+	}
+	if result.Status == "waitlist" {
+		slog.InfoContext(ctx, "user waitlisted", "user_id", userId, "notification_id", notification.ID)
+	}
+	if promoted := result.Promoted; promoted != nil {
+		notice := fmt.Sprintf("🎉 A spot opened up, you're now going to **%s**", notification.Title)
+		b.handleLater(func(ctx context.Context) {
+			if err := b.dmUser(promoted.UserId, notice); err != nil {
+				slog.ErrorContext(ctx, "failed to notify promoted attendee", "err", err)
+			}
+		})
+	}
+
+	attendees, err := b.Repos.Attendees.GetAttendees(ctx, notification.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attendees: %s", err)
+	}
+	// the board shows headcounts, refreshing it must not hold up the reply
+	b.handleLater(b.updateNotifications)
+	embed, components := renderRsvp(notification, attendees, guildLocation(b.guildSettings(notification.GuildId)))
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	}, nil
+}
+
+func renderRsvp(notification *models.Notification, attendees []models.Attendee, loc *time.Location) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	byStatus := make(map[string][]string)
+	for _, a := range attendees {
+		byStatus[a.Status] = append(byStatus[a.Status], "<@"+a.UserId+">")
+	}
+
+	going := fmt.Sprintf("Going (%d)", len(byStatus["going"]))
+	if notification.Capacity > 0 {
+		going = fmt.Sprintf("Going (%d/%d)", len(byStatus["going"]), notification.Capacity)
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "🎟️ " + notification.Title,
+		Description: fmt.Sprintf("%s\n\n⏰ %s", notification.Message, notification.NotifyAt.In(loc).Format("Mon, Jan 02 15:04 MST")),
+		Color:       boardColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: going, Value: attendeeList(byStatus["going"]), Inline: true},
+			{Name: fmt.Sprintf("Maybe (%d)", len(byStatus["maybe"])), Value: attendeeList(byStatus["maybe"]), Inline: true},
+			{Name: fmt.Sprintf("Not going (%d)", len(byStatus["declined"])), Value: attendeeList(byStatus["declined"]), Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "Reminders go to everyone going"},
+	}
+	// in line order, attendees come in the order they first answered
+	waiting := slices.DeleteFunc(slices.Clone(attendees), func(a models.Attendee) bool { return a.Status != "waitlist" })
+	slices.SortStableFunc(waiting, func(a, b models.Attendee) int {
+		if a.WaitlistedAt == nil || b.WaitlistedAt == nil {
+			return 0
+		}
+		return a.WaitlistedAt.Compare(*b.WaitlistedAt)
+	})
+	var waitlist []string
+	for _, a := range waiting {
+		waitlist = append(waitlist, "<@"+a.UserId+">")
+	}
+	if len(waitlist) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Waitlist (%d)", len(waitlist)),
+			Value: attendeeList(waitlist),
+		})
+	}
+
+	row := discordgo.ActionsRow{}
+	for _, s := range rsvpStatuses {
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    s.Label,
+			Style:    s.Style,
+			CustomID: fmt.Sprintf("rsvp:%s:%s", notification.ID, s.Status),
+		})
+	}
+	return embed, []discordgo.MessageComponent{row}
+}
+
+func attendeeList(mentions []string) string {
+	if len(mentions) == 0 {
+		return "—"
+	}
+	return truncate(strings.Join(mentions, "\n"), embedFieldLimit)
+}
+
+// rsvpChannel is where an event is posted, the first channel or thread it
+// targets or else the channel it was created in.
+func rsvpChannel(targets []configs.NotificationTarget, discordMeta *configs.DiscordMetadata) string {
+	for _, target := range targets {
+		if target.Kind == "channel" || target.Kind == "thread" {
+			return target.ID
+		}
+	}
+	return discordMeta.ChannelId
+}
+
+// headcounts are the attendees going to each event among notifications.
+func (b *DiscordBot) headcounts(ctx context.Context, notifications []models.Notification) map[uuid.UUID]int {
+	var ids []uuid.UUID
+	for _, n := range notifications {
+		if n.IsEvent {
+			ids = append(ids, n.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	counts, err := b.Repos.Attendees.CountGoing(ctx, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to count attendees", "err", err)
+	}
+	return counts
+}
+
+// eventTargets are the attendees going to an event, reminders go to them
+// only.
+func (b *DiscordBot) eventTargets(ctx context.Context, notification *models.Notification) ([]configs.NotificationTarget, error) {
+	attendees, err := b.Repos.Attendees.GetAttendees(ctx, notification.ID)
+	if err != nil {
+		return nil, err
+	}
+	var targets []configs.NotificationTarget
+	for _, a := range attendees {
+		if a.Status == "going" {
+			targets = append(targets, configs.NotificationTarget{Kind: "dm", ID: a.UserId})
+		}
+	}
+	return targets, nil
+}
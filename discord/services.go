@@ -0,0 +1,68 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/llm"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// handleService runs services that need no Discord specific handling through
+// the registry, passing the detected params as input.
+func (b *DiscordBot) handleService(ctx context.Context, intent *llm.IntentResult, discordMeta *configs.DiscordMetadata) error {
+	params := make(map[string]any, len(intent.Params)+2)
+	for k, v := range intent.Params {
+		params[k] = v
+	}
+	params["action"] = intent.Action
+	if len(intent.Attachments) > 0 {
+		params["attachments"] = intent.Attachments
+	}
+	input, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to build %s input: %s", intent.Service, err)
+	}
+
+	result := b.Services.Run(ctx, intent.Service, input)
+	if !result.OK {
+		return fmt.Errorf("%s failed: %s", intent.Service, result.Error)
+	}
+
+	msg, err := b.Session.ChannelMessageSend(discordMeta.ChannelId, formatServiceData(result.Data))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send reply", "err", err)
+		return nil
+	}
+	if err := b.tagMessageToBeDeleted(msg, configs.MessageKinds.Reply); err != nil {
+		slog.ErrorContext(ctx, "failed to tag message for deletion", "err", err)
+	}
+	return nil
+}
+
+// formatServiceData prefers a service provided "message", otherwise lists
+// the top level fields of the result.
+func formatServiceData(data json.RawMessage) string {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "✅ " + string(data)
+	}
+	if msg, ok := fields["message"].(string); ok && msg != "" {
+		return msg
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("✅ Done\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "• %s: %v\n", k, fields[k])
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package discord
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the part of the Discord API the bot calls, a
+// *discordgo.Session when running and a fake in tests. The gateway and the
+// state cache are kept separately, see Gateway.
+type Session interface {
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+	ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error
+	ChannelMessagePin(channelID, messageID string, options ...discordgo.RequestOption) error
+	ChannelMessageUnpin(channelID, messageID string, options ...discordgo.RequestOption) error
+	MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error)
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	GuildLeave(guildID string, options ...discordgo.RequestOption) error
+	Application(appID string) (*discordgo.Application, error)
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+}
+
+// Database is what the bot needs of the database besides the repos, a
+// *database.DatabaseManager when running.
+type Database interface {
+	Dialect() string
+	Ping(ctx context.Context) error
+	Backup(dir string) (string, error)
+	PruneBackups(dir string, keep int) ([]string, error)
+	Close() error
+}
+
+// Gateway is the event connection to Discord, the discordgo session when
+// running and a fake in tests.
+type Gateway interface {
+	AddHandler(handler interface{}) func()
+	Open() error
+	Close() error
+	HeartbeatLatency() time.Duration
+	// Ready reports whether the connection is up, it is not while
+	// discordgo reconnects
+	Ready() bool
+}
+
+// discordGateway adds Ready to the discordgo session.
+type discordGateway struct {
+	*discordgo.Session
+}
+
+func (g discordGateway) Ready() bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.DataReady
+}
+
+var (
+	_ Session = (*discordgo.Session)(nil)
+	_ Gateway = discordGateway{}
+)
@@ -0,0 +1,102 @@
+package discord
+
+import (
+	"biyobot/logging"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// variables so tests can shorten them
+var (
+	// how long shutdown waits for background tasks and handlers to finish
+	shutdownTimeout = 30 * time.Second
+	// how long handlers get to return once their context is cancelled
+	cancelGrace = 5 * time.Second
+)
+
+// startHandling registers an in-flight gateway event handler and returns
+// the context it runs in, everything the handler does goes through it. It
+// returns false once the bot is shutting down, the event is dropped then.
+// Handlers call b.inflight.Done when finished.
+func (b *DiscordBot) startHandling() (context.Context, bool) {
+	b.closingMu.Lock()
+	defer b.closingMu.Unlock()
+	if b.closing {
+		return nil, false
+	}
+	b.inflight.Add(1)
+	return logging.WithRequestID(b.handlerCtx), true
+}
+
+// handleLater runs fn in the background as an in-flight handler of its own,
+// for work a handler leaves behind like refreshing boards after replying.
+// It is dropped once shutdown has begun.
+func (b *DiscordBot) handleLater(fn func(ctx context.Context)) {
+	ctx, ok := b.startHandling()
+	if !ok {
+		return
+	}
+	go func() {
+		defer b.inflight.Done()
+		fn(ctx)
+	}()
+}
+
+// shuttingDown reports whether shutdown has begun.
+func (b *DiscordBot) shuttingDown() bool {
+	b.closingMu.Lock()
+	defer b.closingMu.Unlock()
+	return b.closing
+}
+
+// shutdown stops the bot in order: new messages and interactions are
+// dropped, background tasks are cancelled, in-flight handlers get until the
+// timeout to finish before their context is cancelled too, then the database
+// is flushed and closed and the gateway connection last.
+func (b *DiscordBot) shutdown() {
+	slog.Info("shutting down")
+	b.closingMu.Lock()
+	b.closing = true
+	b.closingMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, name := range b.scheduler.Names() {
+		b.scheduler.Task(name).Stop()
+	}
+	for _, name := range b.scheduler.Names() {
+		select {
+		case <-b.scheduler.Task(name).Done():
+		case <-ctx.Done():
+			slog.Warn("background task still running at shutdown", "task", name)
+		}
+	}
+
+	handled := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-ctx.Done():
+		slog.Warn("handlers still running after the shutdown timeout, cancelling them", "timeout", shutdownTimeout)
+		b.cancelHandlers()
+		select {
+		case <-handled:
+		case <-time.After(cancelGrace):
+			slog.Error("handlers did not return after being cancelled, closing anyway")
+		}
+	}
+	b.cancelHandlers()
+
+	if err := b.Database.Close(); err != nil {
+		slog.Error("failed to close database", "err", err)
+	}
+	if err := b.gateway.Close(); err != nil {
+		slog.Error("failed to close gateway connection", "err", err)
+	}
+	slog.Info("shut down")
+}
@@ -0,0 +1,81 @@
+package discord
+
+import (
+	"biyobot/services/scheduler"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// shortTimeouts makes shutdown give up on handlers quickly.
+func shortTimeouts(t *testing.T) {
+	timeout, grace := shutdownTimeout, cancelGrace
+	shutdownTimeout, cancelGrace = 50*time.Millisecond, time.Second
+	t.Cleanup(func() { shutdownTimeout, cancelGrace = timeout, grace })
+}
+
+func TestShutdownOrder(t *testing.T) {
+	shortTimeouts(t)
+	b, _ := newTestBot(t)
+	order := &steps{}
+	b.gateway = &fakeGateway{steps: order}
+	b.Database = &fakeDatabase{steps: order}
+	b.scheduler = scheduler.New(context.Background(), b.Repos.TaskRuns)
+
+	started := make(chan struct{})
+	b.scheduler.Add("sweep", scheduler.Every(time.Hour, 0), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		order.add("task stopped")
+		return nil
+	})
+	<-started
+
+	// a handler that only returns once cancelled, it outlives the timeout
+	ctx, ok := b.startHandling()
+	if !ok {
+		t.Fatal("handler refused before shutdown")
+	}
+	go func() {
+		defer b.inflight.Done()
+		<-ctx.Done()
+		order.add("handler cancelled")
+	}()
+
+	b.shutdown()
+
+	want := []string{"task stopped", "handler cancelled", "database closed", "gateway closed"}
+	if got := order.get(); !slices.Equal(got, want) {
+		t.Errorf("shutdown order = %v, want %v", got, want)
+	}
+	if _, ok := b.startHandling(); ok {
+		t.Error("a handler started after shutdown")
+	}
+}
+
+func TestShutdownLetsHandlersFinish(t *testing.T) {
+	b, _ := newTestBot(t)
+	order := &steps{}
+	b.gateway = &fakeGateway{steps: order}
+	b.Database = &fakeDatabase{steps: order}
+	b.scheduler = scheduler.New(context.Background(), b.Repos.TaskRuns)
+
+	ctx, _ := b.startHandling()
+	go func() {
+		defer b.inflight.Done()
+		time.Sleep(20 * time.Millisecond)
+		if ctx.Err() != nil {
+			order.add("handler cancelled")
+			return
+		}
+		order.add("handler finished")
+	}()
+
+	b.shutdown()
+
+	want := []string{"handler finished", "database closed", "gateway closed"}
+	if got := order.get(); !slices.Equal(got, want) {
+		t.Errorf("shutdown order = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package discord
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// actorOf is who to record in the audit log for a change requested in a
+// message.
+func actorOf(discordMeta *configs.DiscordMetadata) database.Actor {
+	return database.Actor{
+		UserId:    discordMeta.UserId,
+		GuildId:   discordMeta.GuildId,
+		ChannelId: discordMeta.ChannelId,
+		MessageId: discordMeta.MessageId,
+	}
+}
+
+// cmdUndo reverts the author's last notification change in this server, or
+// in DMs when sent there.
+func (b *DiscordBot) cmdUndo(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	result, err := b.Repos.Notifications.UndoLastAction(ctx, actorOf(&configs.DiscordMetadata{
+		MessageId: m.ID,
+		ChannelId: m.ChannelID,
+		GuildId:   m.GuildID,
+		UserId:    m.Author.ID,
+	}), configs.ServiceNames.Scheduler)
+	if errors.Is(err, database.ErrNothingToUndo) || errors.Is(err, database.ErrCannotUndo) {
+		return "", fmt.Errorf("↩️ %s", err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to undo: %s", err)
+	}
+
+	notification := result.Notification
+	loc := guildLocation(b.guildSettings(m.GuildID))
+	var reply string
+	if result.Action == "create" {
+		if err := b.Repos.DiscordMessages.CancelActions(ctx, notification.ID.String()); err != nil {
+			slog.ErrorContext(ctx, "failed to cancel scheduled actions", "notification_id", notification.ID, "err", err)
+		}
+		reply = fmt.Sprintf("↩️ Removed **%s**", notification.Title)
+	} else {
+		// the notification is back as it was, so is its announcement
+		if discordMeta, err := utils.JsonToStruct[configs.DiscordMetadata](notification.Metadata); err == nil {
+			b.scheduleAnnouncements(ctx, notification, &discordMeta)
+		} else {
+			slog.ErrorContext(ctx, "failed to parse notification metadata", "notification_id", notification.ID, "err", err)
+		}
+		reply = fmt.Sprintf("↩️ Restored **%s** on %s", notification.Title, notification.NotifyAt.In(loc).Format("Jan 02, 2006 15:04 MST"))
+	}
+	slog.InfoContext(ctx, "notification change undone", "action", result.Action, "notification_id", notification.ID, "user_id", m.Author.ID)
+	b.updateNotifications(ctx)
+	return reply, nil
+}
+
+// PurgeDeleted removes notifications deleted longer ago than the retention
+// for good, a retention of 0 keeps them. Attachments are pruned regardless.
+func (b *DiscordBot) PurgeDeleted(ctx context.Context) error {
+	if err := b.pruneAttachments(ctx); err != nil {
+		return err
+	}
+	days := b.Config().Database.DeletedRetentionDays
+	if days == 0 {
+		return nil
+	}
+	before := utils.JapanTimeNow().Add(-time.Duration(days) * 24 * time.Hour)
+	purged, err := b.Repos.Notifications.PurgeDeleted(ctx, before)
+	if err != nil {
+		return fmt.Errorf("failed to purge deleted notifications: %w", err)
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "purged deleted notifications", "count", purged)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+#!/usr/bin/env python3
+import json
+import sys
+
+import pytesseract
+from PIL import Image, ImageOps
+from pydantic import BaseModel, field_validator
+
+
+class Input(BaseModel):
+    image_path: str
+    lang: str = "jpn+eng"
+
+    @field_validator("image_path", mode="before")
+    @classmethod
+    def strip_path(cls, v: str) -> str:
+        return v.strip() if isinstance(v, str) else v
+
+
+class OutputData(BaseModel):
+    text: str
+
+
+class Output(BaseModel):
+    ok: bool
+    data: OutputData | None = None
+    error: str | None = None
+
+
+def run(input: dict) -> dict:
+    parsed = Input.model_validate(input)
+    with Image.open(parsed.image_path) as img:
+        # receipts are mostly photographed at an angle on a busy background,
+        # grayscale + autocontrast gives tesseract a much cleaner input
+        img = ImageOps.exif_transpose(img)
+        img = ImageOps.autocontrast(ImageOps.grayscale(img))
+        text = pytesseract.image_to_string(img, lang=parsed.lang)
+    output = Output(ok=True, data=OutputData(text=text))
+    return output.model_dump()
+
+
+if __name__ == "__main__":
+    try:
+        raw = sys.stdin.read()
+        input_data = json.loads(raw) if raw.strip() else {}
+        result = run(input_data)
+    except Exception as e:
+        result = Output(ok=False, error=str(e)).model_dump()
+
+    print(json.dumps(result))
@@ -2,24 +2,28 @@ package llm
 
 import (
 	"biyobot/configs"
+	"biyobot/logging"
+	"biyobot/metrics"
+	"biyobot/models"
 	"biyobot/services/database"
 	"biyobot/utils"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ollama/ollama/api"
 )
 
 type Service struct {
-	DiscordChannelID string
-	Actions          []Action
-	KeywordsEN       []string
-	KeywordsJA       []string
+	Actions    []Action
+	KeywordsEN []string
+	KeywordsJA []string
 }
 
 type Action struct {
@@ -32,33 +36,80 @@ type Action struct {
 type IntentResult struct {
 	Service string `json:"service"`
 	// add | edit | delete
-	Action     string         `json:"action"`
-	Confidence float64        `json:"confidence"`
-	Params     map[string]any `json:"params,omitempty"`
+	Action      string                  `json:"action"`
+	Confidence  float64                 `json:"confidence"`
+	Params      map[string]any          `json:"params,omitempty"`
+	Attachments []configs.AttachmentRef `json:"attachments,omitempty"`
+}
+
+// IntentRequest is a message to detect an intent for, along with who sent
+// it and where.
+type IntentRequest struct {
+	GuildID     string
+	ChannelID   string
+	UserID      string
+	DM          bool // DMs are a personal context, see buildContext
+	Message     string
+	Attachments []configs.AttachmentRef
+	// per-guild settings, a nil Location means JST and nil Services all of them
+	Location *time.Location
+	Language string
+	Services []string
+}
+
+// now returns the current time in the requesting guild's time zone.
+func (r IntentRequest) now() time.Time {
+	if r.Location == nil {
+		return utils.JapanTimeNow()
+	}
+	return time.Now().In(r.Location)
 }
 
+// enabled reports whether the guild the request came from uses a service.
+func (r IntentRequest) enabled(serviceName string) bool {
+	return len(r.Services) == 0 || slices.Contains(r.Services, serviceName)
+}
+
+// Client is the part of the Ollama API the intent service calls, an
+// *api.Client when running and a fake in tests.
+type Client interface {
+	Chat(ctx context.Context, req *api.ChatRequest, fn api.ChatResponseFunc) error
+	Heartbeat(ctx context.Context) error
+	Show(ctx context.Context, req *api.ShowRequest) (*api.ShowResponse, error)
+}
+
+var _ Client = (*api.Client)(nil)
+
 type IntentService struct {
-	client           *api.Client
-	notificationRepo *database.NotificationsRepo
+	client           Client
+	notificationRepo database.Notifications
+	expenseRepo      database.Expenses
+	bindingRepo      database.ChannelBindings
 	services         map[string]Service
-	channelIdx       map[string]string
+
+	// changed on config reloads while intents are detected, see settings
+	mu    sync.RWMutex
+	model string
+	// routed intents below this confidence are reported as "unknown"
+	confidenceThreshold float64
 }
 
-func NewIntentService(client *api.Client, notificationRepo *database.NotificationsRepo, appConfig *configs.AppConfig) *IntentService {
+func NewIntentService(client Client, notificationRepo database.Notifications, expenseRepo database.Expenses, bindingRepo database.ChannelBindings, appConfig *configs.AppConfig) *IntentService {
 	services := map[string]Service{
 		configs.ServiceNames.Scheduler: {
-			DiscordChannelID: appConfig.DiscordSrvSchedulerCid,
-			KeywordsEN:       []string{"schedule", "event", "meeting", "party", "appointment"},
-			KeywordsJA:       []string{"スケジュール", "予定", "予約", "イベント"},
+			KeywordsEN: []string{"schedule", "event", "meeting", "party", "appointment"},
+			KeywordsJA: []string{"スケジュール", "予定", "予約", "イベント"},
 			Actions: []Action{
 				{
 					Name:       "add",
-					KeywordsEN: []string{"add", "create", "schedule", "set", "new"},
-					KeywordsJA: []string{"追加", "作成", "入れる", "設定"},
+					KeywordsEN: []string{"add", "create", "schedule", "set", "new", "rsvp"},
+					KeywordsJA: []string{"追加", "作成", "入れる", "設定", "参加"},
 					Schema: map[string]string{
 						"notify_at":   "RFC3339 (2006-01-02T15:04:05Z07:00) (required)",
 						"title":       "string (required)",
 						"description": "string (required)",
+						"is_event":    "boolean, true when others are invited to join (party, meetup, game night, RSVP)",
+						"capacity":    "integer, the maximum number of attendees, 0 when not mentioned",
 					},
 				},
 				{
@@ -80,70 +131,236 @@ func NewIntentService(client *api.Client, notificationRepo *database.Notificatio
 						"notification_id": "string (required)",
 					},
 				},
+				{
+					Name:       "list",
+					KeywordsEN: []string{"list", "show", "upcoming", "my reminders"},
+					KeywordsJA: []string{"一覧", "リスト", "確認", "表示"},
+					Schema:     map[string]string{},
+				},
 			},
 		},
-		"receipts": {
-			DiscordChannelID: "",
-			KeywordsEN:       []string{"receipt", "expense", "scan"},
-			KeywordsJA:       []string{"レシート", "領収書", "経費"},
+		configs.ServiceNames.Receipts: {
+			KeywordsEN: []string{"receipt", "expense", "scan"},
+			KeywordsJA: []string{"レシート", "領収書", "経費"},
 			Actions: []Action{
 				{
+					// receipts are added by posting an image, text only ever
+					// reaches this action through the LLM fallback
 					Name:       "add",
-					KeywordsEN: []string{"add", "scan", "log"},
-					KeywordsJA: []string{"追加", "スキャン", "記録"},
+					KeywordsEN: []string{"scan"},
+					KeywordsJA: []string{"スキャン"},
 					Schema: map[string]string{
 						"has_image": "boolean",
 					},
 				},
+				{
+					Name:       "budget",
+					KeywordsEN: []string{"budget", "limit"},
+					KeywordsJA: []string{"予算", "上限"},
+					Schema: map[string]string{
+						"category": "groceries | dining | transport | shopping | utilities | health | entertainment | other (required when setting)",
+						"total":    "monthly limit as number string, empty to show budget status",
+						"currency": "JPY | USD | EUR",
+					},
+				},
+				{
+					Name:       "export",
+					KeywordsEN: []string{"export", "csv", "chart", "graph", "download"},
+					KeywordsJA: []string{"エクスポート", "出力", "グラフ", "ダウンロード"},
+					Schema: map[string]string{
+						"month":  "YYYY-MM (optional)",
+						"from":   "YYYY-MM-DD (optional)",
+						"to":     "YYYY-MM-DD (optional)",
+						"format": "csv | chart",
+					},
+				},
+				{
+					Name:       "summary",
+					KeywordsEN: []string{"summary", "summarize", "spending", "spent", "report", "breakdown"},
+					KeywordsJA: []string{"集計", "支出", "まとめ", "レポート"},
+					Schema: map[string]string{
+						"month":    "YYYY-MM (optional)",
+						"from":     "YYYY-MM-DD (optional)",
+						"to":       "YYYY-MM-DD (optional)",
+						"group_by": "category | merchant | month",
+					},
+				},
+				{
+					Name:       "edit",
+					KeywordsEN: []string{"edit", "fix", "correct", "change", "update"},
+					KeywordsJA: []string{"編集", "修正", "変更", "訂正"},
+					Schema: map[string]string{
+						"expense_id": "string (required)",
+						"merchant":   "string",
+						"date":       "YYYY-MM-DD",
+						"total":      "number as string, no currency symbol",
+						"currency":   "JPY | USD | EUR",
+						"category":   "groceries | dining | transport | shopping | utilities | health | entertainment | other",
+					},
+				},
+				{
+					Name:       "delete",
+					KeywordsEN: []string{"delete", "remove"},
+					KeywordsJA: []string{"削除", "消去"},
+					Schema: map[string]string{
+						"expense_id": "string (required)",
+					},
+				},
+			},
+		},
+		configs.ServiceNames.Polls: {
+			KeywordsEN: []string{"poll", "vote", "survey"},
+			KeywordsJA: []string{"投票", "アンケート"},
+			Actions: []Action{
+				{
+					Name:       "create",
+					KeywordsEN: []string{"poll", "vote", "create"},
+					KeywordsJA: []string{"投票", "アンケート", "作成"},
+					Schema: map[string]string{
+						"question":     "string, what is being decided",
+						"options":      "array of strings, the choices (required)",
+						"closes_at":    "RFC3339 (2006-01-02T15:04:05Z07:00), empty when no closing time is mentioned",
+						"multi_choice": "boolean, true when several options may be picked",
+						"anonymous":    "boolean, true when votes should be secret",
+					},
+				},
 			},
 		},
 		"currency_converter": {
-			DiscordChannelID: "",
-			KeywordsEN:       []string{"convert", "exchange", "currency"},
-			KeywordsJA:       []string{"両替", "変換", "換算"},
+			KeywordsEN: []string{"convert", "exchange", "currency"},
+			KeywordsJA: []string{"両替", "変換", "換算"},
 			Actions: []Action{
 				{
 					Name:       "convert",
-					KeywordsEN: []string{"convert", "to", "exchange"},
+					KeywordsEN: []string{"convert", "exchange"},
 					KeywordsJA: []string{"変換", "換算", "両替"},
 					Schema: map[string]string{
-						"amount":        "number",
-						"from_currency": "string",
-						"to_currency":   "string",
+						"amount": "number as string",
+						"from":   "currency code, e.g. USD",
+						"to":     "currency code, e.g. JPY",
 					},
 				},
 			},
 		},
 	}
 
-	channelIndex := make(map[string]string, len(services))
-	for name, svc := range services {
-		if svc.DiscordChannelID != "" {
-			channelIndex[svc.DiscordChannelID] = name
-		}
-	}
-
 	return &IntentService{
 		client:           client,
 		notificationRepo: notificationRepo,
+		expenseRepo:      expenseRepo,
+		bindingRepo:      bindingRepo,
 		services:         services,
-		channelIdx:       channelIndex,
+		model:            appConfig.LLMModel,
+
+		confidenceThreshold: appConfig.IntentConfidenceThreshold,
 	}
 }
 
-func (s *IntentService) DetectIntent(channelID, message string) (*IntentResult, error) {
-	serviceName, ok := s.channelIdx[channelID]
+// SetModel changes the model prompts are sent to, e.g. after a config
+// reload.
+func (s *IntentService) SetModel(model string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model = model
+}
+
+// SetConfidenceThreshold changes the routing threshold, e.g. after a config
+// reload.
+func (s *IntentService) SetConfidenceThreshold(threshold float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confidenceThreshold = threshold
+}
+
+// settings returns the current model and routing threshold.
+func (s *IntentService) settings() (model string, threshold float64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.model, s.confidenceThreshold
+}
+
+// Health checks the LLM backend is reachable and has the model pulled.
+func (s *IntentService) Health(ctx context.Context) error {
+	if err := s.client.Heartbeat(ctx); err != nil {
+		return fmt.Errorf("ollama unreachable: %w", err)
+	}
+	model, _ := s.settings()
+	if _, err := s.client.Show(ctx, &api.ShowRequest{Model: model}); err != nil {
+		return fmt.Errorf("model %s unavailable: %w", model, err)
+	}
+	return nil
+}
+
+// ServiceNames lists every service intents can be detected for, sorted.
+func (s *IntentService) ServiceNames() []string {
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ChannelServices returns the services bound to a channel.
+func (s *IntentService) ChannelServices(ctx context.Context, channelID string) []string {
+	names, err := s.bindingRepo.GetChannelServices(ctx, channelID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load channel bindings", "channel_id", channelID, "err", err)
+		return nil
+	}
+	return names
+}
+
+func (s *IntentService) DetectIntent(ctx context.Context, req IntentRequest) (*IntentResult, error) {
+	message, attachments := req.Message, req.Attachments
+
+	// a channel bound to one service needs no routing, otherwise the router
+	// picks among the bound services, or all of them in DMs and unbound
+	// channels
+	serviceName, serviceConfidence := "", 1.0
+	var candidates []string
+	if !req.DM {
+		candidates = s.ChannelServices(ctx, req.ChannelID)
+	}
+	candidates = slices.DeleteFunc(candidates, func(name string) bool { return !req.enabled(name) })
+	if len(candidates) == 1 {
+		serviceName = candidates[0]
+	} else {
+		if len(candidates) == 0 {
+			candidates = slices.DeleteFunc(s.ServiceNames(), func(name string) bool { return !req.enabled(name) })
+		}
+		serviceName, serviceConfidence = s.routeService(ctx, candidates, message)
+		if _, threshold := s.settings(); serviceName == "" || serviceConfidence < threshold {
+			slog.InfoContext(ctx, "routing refused", "service", serviceName, "confidence", serviceConfidence, "threshold", threshold)
+			return &IntentResult{Service: "unknown", Confidence: serviceConfidence}, nil
+		}
+	}
+
+	service, ok := s.services[serviceName]
 	if !ok {
 		return &IntentResult{Service: "unknown", Confidence: 0.0}, nil
 	}
 
-	service := s.services[serviceName]
+	// a bare upload has no text to classify, it goes to the default action
+	if strings.TrimSpace(message) == "" {
+		if len(attachments) == 0 || len(service.Actions) == 0 {
+			return &IntentResult{Service: "unknown", Confidence: 0.0}, nil
+		}
+		action := service.Actions[0]
+		return &IntentResult{
+			Service:     serviceName,
+			Action:      action.Name,
+			Params:      attachmentParams(action.Schema, nil, attachments),
+			Confidence:  serviceConfidence,
+			Attachments: attachments,
+		}, nil
+	}
 
 	var usingLLM bool
 
 	actionName := keywordMatchAction(service, message)
 	if actionName == "" {
-		actionName = s.llmDetectAction(serviceName, service, message)
+		actionName = s.llmDetectAction(ctx, req, serviceName, service)
 		usingLLM = true
 	}
 
@@ -159,24 +376,43 @@ func (s *IntentService) DetectIntent(channelID, message string) (*IntentResult,
 		}
 	}
 
-	params := s.extractParams(serviceName, actionName, action.Schema, message)
+	var params map[string]any
+	if len(action.Schema) > 0 {
+		params = s.extractParams(ctx, req, serviceName, actionName, action.Schema)
+	}
+	params = attachmentParams(action.Schema, params, attachments)
 
-	confidence := 1.0
+	confidence := serviceConfidence
 	if usingLLM {
-		confidence = 0.85
+		confidence *= 0.85
 	}
 
 	return &IntentResult{
-		Service:    serviceName,
-		Action:     actionName,
-		Params:     params,
-		Confidence: confidence,
+		Service:     serviceName,
+		Action:      actionName,
+		Params:      params,
+		Confidence:  confidence,
+		Attachments: attachments,
 	}, nil
 }
 
-func (s *IntentService) llmDetectAction(serviceName string, service Service, message string) string {
-	log.Println("Detecting action")
-	contextStr := s.buildContext(serviceName)
+// attachmentParams fills params the LLM can't know about from the message
+// text, like whether an image was attached.
+func attachmentParams(schema map[string]string, params map[string]any, attachments []configs.AttachmentRef) map[string]any {
+	if _, ok := schema["has_image"]; !ok {
+		return params
+	}
+	if params == nil {
+		params = make(map[string]any)
+	}
+	params["has_image"] = slices.ContainsFunc(attachments, configs.AttachmentRef.IsImage)
+	return params
+}
+
+func (s *IntentService) llmDetectAction(ctx context.Context, req IntentRequest, serviceName string, service Service) string {
+	slog.DebugContext(ctx, "detecting action", "service", serviceName)
+	message := req.Message
+	contextStr := s.buildContext(ctx, req, serviceName)
 
 	var actionList strings.Builder
 	for _, action := range service.Actions {
@@ -188,11 +424,11 @@ func (s *IntentService) llmDetectAction(serviceName string, service Service, mes
 		fmt.Fprintf(&actionList, "- %s: %s%s\n", action.Name, enKw, jaKw)
 	}
 
-	now := utils.JapanTimeNow()
+	now := req.now()
 	prompt := fmt.Sprintf(`Detect which action the user wants for the %s service.
 
 Context:
-- Current Time (JST): %s
+- Current Time (%s): %s
 - Current Data: %s
 
 Available actions:
@@ -205,9 +441,9 @@ Rules:
 - If user says "add", "create", "new", or describes a new event, likely "add"
 - If context is unclear, default to "add"
 
-Return ONLY JSON: {"action": "action_name"}`, serviceName, now.Format(time.RFC3339), contextStr, actionList.String(), message)
+Return ONLY JSON: {"action": "action_name"}`, serviceName, now.Location(), now.Format(time.RFC3339), contextStr, actionList.String(), message)
 
-	response := s.callLLM(prompt)
+	response := s.callLLM(ctx, prompt)
 
 	var result struct {
 		Action string `json:"action"`
@@ -219,44 +455,51 @@ Return ONLY JSON: {"action": "action_name"}`, serviceName, now.Format(time.RFC33
 	return result.Action
 }
 
-func (s *IntentService) extractParams(serviceName, actionName string, schema map[string]string, message string) map[string]any {
-	log.Println("Extracting params")
-	now := utils.JapanTimeNow()
-	contextStr := s.buildContext(serviceName)
+func (s *IntentService) extractParams(ctx context.Context, req IntentRequest, serviceName, actionName string, schema map[string]string) map[string]any {
+	slog.DebugContext(ctx, "extracting params", "service", serviceName, "action", actionName)
+	message := req.Message
+	now := req.now()
+	zone, offset := now.Location().String(), now.Format("-07:00")
+	contextStr := s.buildContext(ctx, req, serviceName)
 	schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+	language := req.Language
+	if language == "" {
+		language = "en"
+	}
 
-	prompt := fmt.Sprintf(`Extract parameters from this message for the %s.%s action.
+	prompt := fmt.Sprintf(`Extract parameters from this message for the %[1]s.%[2]s action.
 
 Context:
-- Current Time (JST): %s
-- Current Data: %s
+- Current Time (%[3]s): %[4]s
+- Current Data: %[5]s
 
 Required schema:
-%s
+%[6]s
 
-Message: "%s"
+Message: "%[7]s"
 
 Rules:
 - For edit/delete: If user mentions an event by name, find the matching ID from "Current Data" Context above.
 - For edit: If the generated JSON has empty values, use the current data's field instead of leaving it empty.
-- For datetime: The user is in Japan. Times they mention are already in JST — do NOT add or subtract any hours.
-  The +09:00 suffix is a label only, not a math operation.
-  If user says "14:00", the output must contain T14:00:00+09:00, never T05:00:00+09:00 or any other hour.
-  Format: YYYY-MM-DDT{EXACT_TIME_USER_SAID}+09:00
-  Reference Current Time (JST) for relative expressions like "today", "tomorrow", "now".
-  "today" always means the current date (%s), even if the time has already passed. Do NOT advance to the next day.
+- For datetime: The user is in %[3]s. Times they mention are already in that time zone — do NOT add or subtract any hours.
+  The %[8]s suffix is a label only, not a math operation.
+  If user says "14:00", the output must contain T14:00:00%[8]s, never any other hour.
+  Format: YYYY-MM-DDT{EXACT_TIME_USER_SAID}%[8]s
+  Reference Current Time (%[3]s) for relative expressions like "today", "tomorrow", "now".
+  "today" always means the current date (%[4]s), even if the time has already passed. Do NOT advance to the next day.
 - For title: use a clean, concise name extracted from the message, not the raw message itself.
 - For description: briefly describe the event, do not repeat the raw message.
-- Use 2026 for missing years.
+- Write title and description in the language with code "%[9]s".
+- Use %[10]d for missing years.
 
 Return ONLY valid JSON matching the schema.`,
-		serviceName, actionName, now.Format(time.RFC3339), contextStr, string(schemaJSON), message, now.Format(time.RFC3339))
+		serviceName, actionName, zone, now.Format(time.RFC3339), contextStr, string(schemaJSON), message, offset, language, now.Year())
 
-	log.Printf("%s", prompt)
+	slog.DebugContext(ctx, "params prompt", "prompt", logging.Content(prompt))
 
-	response := s.callLLM(prompt)
+	response := s.callLLM(ctx, prompt)
 
-	log.Printf("Extracted: %s\n", response)
+	slog.DebugContext(ctx, "params extracted", "response", logging.Content(response))
 	var params map[string]any
 	if jsonStr := extractJSON(response); jsonStr != "" {
 		json.Unmarshal([]byte(jsonStr), &params)
@@ -265,12 +508,26 @@ Return ONLY valid JSON matching the schema.`,
 	return params
 }
 
-func (s *IntentService) buildContext(serviceName string) string {
-	if serviceName != "scheduler" {
-		return ""
+// buildContext lists the data the user may refer to. In DMs that is only
+// their own data, in channels everything shared there.
+func (s *IntentService) buildContext(ctx context.Context, req IntentRequest, serviceName string) string {
+	switch serviceName {
+	case configs.ServiceNames.Scheduler:
+		return s.buildSchedulerContext(ctx, req)
+	case configs.ServiceNames.Receipts:
+		return s.buildReceiptsContext(ctx, req)
 	}
+	return ""
+}
 
-	notifications, err := s.notificationRepo.GetAllNotifications()
+func (s *IntentService) buildSchedulerContext(ctx context.Context, req IntentRequest) string {
+	var notifications []models.Notification
+	var err error
+	if req.DM {
+		notifications, err = s.notificationRepo.GetUserNotifications(ctx, "", req.UserID)
+	} else {
+		notifications, err = s.notificationRepo.GetPublicNotifications(ctx, req.GuildID)
+	}
 	if err != nil || len(notifications) == 0 {
 		return ""
 	}
@@ -283,20 +540,42 @@ func (s *IntentService) buildContext(serviceName string) string {
 	return b.String()
 }
 
-func (s *IntentService) callLLM(prompt string) string {
-	log.Println("Using LLM...")
+func (s *IntentService) buildReceiptsContext(ctx context.Context, req IntentRequest) string {
+	expenses, err := s.expenseRepo.GetRecentExpenses(ctx, req.GuildID, req.UserID, 20)
+	if err != nil || len(expenses) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Recent expenses:\n")
+	for _, e := range expenses {
+		fmt.Fprintf(&b, "- ID:%s, Merchant:\"%s\", Date:%s, Total:%s, Category:%s\n",
+			e.ID.String(), e.Merchant, e.PurchasedAt.Format("2006-01-02"), utils.ToAmount(e.TotalRaw, e.Currency), e.Category)
+	}
+	return b.String()
+}
+
+func (s *IntentService) callLLM(ctx context.Context, prompt string) string {
+	model, _ := s.settings()
+	slog.DebugContext(ctx, "calling LLM", "model", model)
 	req := &api.ChatRequest{
-		Model: "qwen2.5:3b",
+		Model: model,
 		Messages: []api.Message{
 			{Role: "user", Content: prompt},
 		},
 	}
 
 	var b strings.Builder
-	s.client.Chat(context.Background(), req, func(resp api.ChatResponse) error {
+	start := time.Now()
+	err := s.client.Chat(ctx, req, func(resp api.ChatResponse) error {
 		b.WriteString(resp.Message.Content)
 		return nil
 	})
+	metrics.LLMLatency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.LLMErrors.Inc()
+		slog.ErrorContext(ctx, "LLM request failed", "model", model, "err", err)
+	}
 
 	return b.String()
 }
@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database/memory"
+	"context"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+// fakeClient answers every prompt with the same response.
+type fakeClient struct {
+	response string
+	prompts  []string
+}
+
+func (f *fakeClient) Chat(ctx context.Context, req *api.ChatRequest, fn api.ChatResponseFunc) error {
+	f.prompts = append(f.prompts, req.Messages[len(req.Messages)-1].Content)
+	return fn(api.ChatResponse{Message: api.Message{Role: "assistant", Content: f.response}})
+}
+
+func (f *fakeClient) Heartbeat(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeClient) Show(ctx context.Context, req *api.ShowRequest) (*api.ShowResponse, error) {
+	return &api.ShowResponse{}, nil
+}
+
+func newTestService(client Client) *IntentService {
+	repos := memory.NewRepos()
+	return NewIntentService(client, repos.Notifications, repos.Expenses, repos.ChannelBindings, &configs.AppConfig{IntentConfidenceThreshold: 0.6})
+}
+
+func TestCleanReceipt(t *testing.T) {
+	client := &fakeClient{response: "Sure! Here it is:\n```json\n{\"merchant\": \"Lawson\", \"total\": \"540\", \"items\": [{\"name\": \"onigiri\"}]}\n```"}
+	params, err := newTestService(client).CleanReceipt(context.Background(), "LAWS0N\n合計 ¥540")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["merchant"] != "Lawson" || params["total"] != "540" {
+		t.Errorf("params = %v", params)
+	}
+	if len(client.prompts) != 1 {
+		t.Fatalf("%d prompts sent, want 1", len(client.prompts))
+	}
+
+	client.response = "I could not read that receipt."
+	if _, err := newTestService(client).CleanReceipt(context.Background(), "???"); err == nil {
+		t.Error("expected an error when the model returns no JSON")
+	}
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"biyobot/logging"
+	"biyobot/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// CleanReceipt turns noisy OCR output of a receipt into structured fields.
+func (s *IntentService) CleanReceipt(ctx context.Context, ocrText string) (map[string]any, error) {
+	slog.DebugContext(ctx, "cleaning receipt OCR text", "text", logging.Content(ocrText))
+	now := utils.JapanTimeNow()
+	prompt := fmt.Sprintf(`You are reading OCR output from a shopping receipt. The text may contain recognition errors.
+
+Context:
+- Current Time (JST): %s
+
+OCR text:
+"""
+%s
+"""
+
+Return ONLY valid JSON in this shape:
+{
+  "merchant": "store name",
+  "date": "YYYY-MM-DD",
+  "total": "1234.56",
+  "currency": "JPY | USD | EUR",
+  "category": "groceries | dining | transport | shopping | utilities | health | entertainment | other",
+  "items": [{"name": "item name", "quantity": 1, "amount": "123.45"}]
+}
+
+Rules:
+- "total" is the final amount paid (合計), not a subtotal or the change (お釣り).
+- Amounts are plain numbers without currency symbols or thousands separators.
+- Receipts in Japanese are JPY unless another currency is printed.
+- If the date is missing, use today's date (%s).
+- Fix obvious OCR mistakes in names (e.g. "0" read as "O"), but do not invent items.
+- Leave "items" empty if no line items can be read.`,
+		now.Format(time.RFC3339), ocrText, now.Format("2006-01-02"))
+
+	response := s.callLLM(ctx, prompt)
+
+	jsonStr := extractJSONObject(response)
+	if jsonStr == "" {
+		return nil, fmt.Errorf("receipt cleanup returned no JSON")
+	}
+	var params map[string]any
+	if err := json.Unmarshal([]byte(jsonStr), &params); err != nil {
+		return nil, fmt.Errorf("receipt cleanup returned invalid JSON: %w", err)
+	}
+	return params, nil
+}
+
+// extractJSONObject returns the outermost {...} block, allowing nested
+// objects and arrays unlike extractJSON.
+func extractJSONObject(s string) string {
+	start := strings.Index(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start == -1 || end <= start {
+		return ""
+	}
+	return s[start : end+1]
+}
@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// llmConfidenceScale discounts the confidence the model reports about its own
+// choice, small models answer 0.9+ for nearly everything.
+const llmConfidenceScale = 0.8
+
+// routeService picks the service a message is meant for out of candidates,
+// with a confidence in [0, 1]. Keyword hits decide when there is a clear
+// winner, otherwise the LLM classifies the message.
+func (s *IntentService) routeService(ctx context.Context, candidates []string, message string) (string, float64) {
+	if strings.TrimSpace(message) == "" {
+		// bare uploads go to the first service that takes images
+		for _, name := range candidates {
+			if acceptsImages(s.services[name]) {
+				return name, 1.0
+			}
+		}
+		return "", 0.0
+	}
+
+	kwName, kwConfidence := s.keywordRoute(candidates, message)
+	if kwConfidence >= 0.9 {
+		return kwName, kwConfidence
+	}
+
+	llmName, llmConfidence := s.llmDetectService(ctx, candidates, message)
+	switch {
+	case llmName == "":
+		return kwName, kwConfidence
+	case llmName == kwName:
+		// both signals agree, trust the stronger one
+		return llmName, max(llmConfidence, kwConfidence)
+	case llmConfidence >= kwConfidence:
+		return llmName, llmConfidence
+	}
+	return kwName, kwConfidence
+}
+
+// keywordRoute scores candidates by keyword hits. Confidence grows with the
+// margin between the best and the runner-up service, ties score zero.
+func (s *IntentService) keywordRoute(candidates []string, message string) (string, float64) {
+	best, bestScore, secondScore := "", 0, 0
+	for _, name := range candidates {
+		score := keywordScore(s.services[name], message)
+		switch {
+		case score > bestScore:
+			best, bestScore, secondScore = name, score, bestScore
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+	if bestScore == 0 || bestScore == secondScore {
+		return "", 0.0
+	}
+	if secondScore == 0 {
+		// unambiguous, a couple of hits make it near certain
+		return best, min(0.95, 0.85+0.05*float64(bestScore-1))
+	}
+	return best, 0.5 + 0.4*float64(bestScore-secondScore)/float64(bestScore)
+}
+
+func (s *IntentService) llmDetectService(ctx context.Context, candidates []string, message string) (string, float64) {
+	slog.DebugContext(ctx, "detecting service", "candidates", candidates)
+	var serviceList strings.Builder
+	for _, name := range candidates {
+		svc := s.services[name]
+		actions := make([]string, 0, len(svc.Actions))
+		for _, a := range svc.Actions {
+			actions = append(actions, a.Name)
+		}
+		fmt.Fprintf(&serviceList, "- %s (actions: %s; keywords: %s / %s)\n", name,
+			strings.Join(actions, ", "), strings.Join(svc.KeywordsEN, ", "), strings.Join(svc.KeywordsJA, ", "))
+	}
+
+	prompt := fmt.Sprintf(`Detect which service the user's message is meant for.
+
+Available services:
+%s
+Message: "%s"
+
+Rules:
+- If the message is casual conversation or fits none of the services, answer "none".
+- "confidence" is how sure you are, from 0.0 to 1.0.
+
+Return ONLY JSON: {"service": "service_name", "confidence": 0.0}`, serviceList.String(), message)
+
+	response := s.callLLM(ctx, prompt)
+
+	var result struct {
+		Service    string  `json:"service"`
+		Confidence float64 `json:"confidence"`
+	}
+	if jsonStr := extractJSON(response); jsonStr != "" {
+		json.Unmarshal([]byte(jsonStr), &result)
+	}
+	if !slices.Contains(candidates, result.Service) {
+		return "", 0.0
+	}
+	return result.Service, min(max(result.Confidence, 0), 1) * llmConfidenceScale
+}
+
+// keywordScore counts the service and action keywords found in message.
+func keywordScore(service Service, message string) int {
+	msgLower := strings.ToLower(message)
+	score := 0
+	count := func(en, ja []string) {
+		for _, kw := range en {
+			if strings.Contains(msgLower, strings.ToLower(kw)) {
+				score++
+			}
+		}
+		for _, kw := range ja {
+			if strings.Contains(message, kw) {
+				score++
+			}
+		}
+	}
+	count(service.KeywordsEN, service.KeywordsJA)
+	for _, action := range service.Actions {
+		count(action.KeywordsEN, action.KeywordsJA)
+	}
+	return score
+}
+
+func acceptsImages(service Service) bool {
+	for _, action := range service.Actions {
+		if _, ok := action.Schema["has_image"]; ok {
+			return true
+		}
+	}
+	return false
+}
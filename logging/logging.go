@@ -0,0 +1,158 @@
+// Package logging sets up the bot's structured logs: the level, text or JSON
+// output, request IDs carried in contexts and redaction of secrets and what
+// users wrote.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// Options are the log settings, see the log section of the config.
+type Options struct {
+	Level  string `yaml:"level"`  // debug | info | warn | error
+	Format string `yaml:"format"` // text | json
+	Redact string `yaml:"redact"` // none | secrets | content
+}
+
+// Redaction is how much is left out of the logs, each level hides what the
+// ones before it do.
+type Redaction int32
+
+const (
+	RedactNone Redaction = iota
+	// tokens, passwords and connection strings
+	RedactSecrets
+	// messages, prompts and LLM replies, anything users wrote or that
+	// was built from it
+	RedactContent
+)
+
+var (
+	level  slog.LevelVar
+	redact atomic.Int32
+)
+
+func init() {
+	// until Setup, nothing users wrote is logged
+	redact.Store(int32(RedactContent))
+}
+
+// ParseLevel reads a level name as used in the config.
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return l, fmt.Errorf("unknown log level %q, want debug, info, warn or error", name)
+	}
+	return l, nil
+}
+
+// ParseRedaction reads a redaction name as used in the config.
+func ParseRedaction(name string) (Redaction, error) {
+	switch strings.ToLower(name) {
+	case "none":
+		return RedactNone, nil
+	case "secrets":
+		return RedactSecrets, nil
+	case "content":
+		return RedactContent, nil
+	}
+	return 0, fmt.Errorf("unknown redaction %q, want none, secrets or content", name)
+}
+
+// Setup makes slog log as opts say, the std log package included. The
+// options are expected to be validated already, the ones that don't parse
+// are left as they were.
+func Setup(opts Options) {
+	SetLevels(opts)
+	slog.SetDefault(slog.New(newHandler(os.Stderr, opts.Format)))
+}
+
+// newHandler returns the handler writing logs to w in format.
+func newHandler(w io.Writer, format string) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{Level: &level}
+	if format == "json" {
+		return contextHandler{slog.NewJSONHandler(w, handlerOpts)}
+	}
+	return contextHandler{slog.NewTextHandler(w, handlerOpts)}
+}
+
+// SetLevels applies the level and the redaction of opts, they can change at
+// runtime unlike the format.
+func SetLevels(opts Options) {
+	if l, err := ParseLevel(opts.Level); err == nil {
+		level.Set(l)
+	}
+	if r, err := ParseRedaction(opts.Redact); err == nil {
+		redact.Store(int32(r))
+	}
+}
+
+// Fatal logs an error and exits, for failures the bot can't start without.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID starts a request, e.g. handling a Discord message. Every
+// line logged with the returned context carries its ID.
+func WithRequestID(ctx context.Context) context.Context {
+	id := uuid.NewString()[:8]
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request ctx belongs to, empty outside one.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID of the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Secret is logged as a placeholder unless redaction is off.
+type Secret string
+
+func (s Secret) LogValue() slog.Value {
+	if Redaction(redact.Load()) >= RedactSecrets && s != "" {
+		return slog.StringValue("[secret]")
+	}
+	return slog.StringValue(string(s))
+}
+
+// Content is text users wrote, or built from it like prompts and LLM
+// replies. Only its length is logged when content is redacted.
+type Content string
+
+func (c Content) LogValue() slog.Value {
+	if Redaction(redact.Load()) >= RedactContent {
+		return slog.StringValue(fmt.Sprintf("[%d chars]", len(c)))
+	}
+	return slog.StringValue(string(c))
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logJSON logs one line through the JSON handler with opts applied and
+// returns it decoded.
+func logJSON(t *testing.T, opts Options, log func(ctx context.Context, logger *slog.Logger)) (string, map[string]any) {
+	t.Helper()
+	SetLevels(opts)
+	t.Cleanup(func() { SetLevels(Options{Level: "info", Redact: "content"}) })
+	var buf bytes.Buffer
+	log(WithRequestID(context.Background()), slog.New(newHandler(&buf, "json")))
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("not a JSON line: %q", buf.String())
+	}
+	return buf.String(), line
+}
+
+const (
+	prompt = "remind me to call mom about the surprise party"
+	token  = "MTIzNDU2Nzg5.abcdef.token"
+)
+
+func logRequest(ctx context.Context, logger *slog.Logger) {
+	logger.With("prompt", Content(prompt)).InfoContext(ctx, "routed message",
+		slog.Group("llm", "reply", Content("sure, "+prompt), "token", Secret(token)))
+}
+
+func TestContentRedactedInJSON(t *testing.T) {
+	out, line := logJSON(t, Options{Level: "info", Redact: "content"}, logRequest)
+
+	if strings.Contains(out, "mom") || strings.Contains(out, token) {
+		t.Fatalf("redacted values reached the log: %s", out)
+	}
+	if line["prompt"] != "[46 chars]" {
+		t.Errorf("prompt = %v, want its length", line["prompt"])
+	}
+	group, _ := line["llm"].(map[string]any)
+	if group["reply"] != "[52 chars]" || group["token"] != "[secret]" {
+		t.Errorf("llm group = %v", group)
+	}
+	if id, _ := line["request_id"].(string); len(id) != 8 {
+		t.Errorf("request_id = %v", line["request_id"])
+	}
+}
+
+func TestSecretsRedactedWithoutContent(t *testing.T) {
+	out, line := logJSON(t, Options{Level: "info", Redact: "secrets"}, logRequest)
+
+	if strings.Contains(out, token) {
+		t.Fatalf("the token reached the log: %s", out)
+	}
+	if line["prompt"] != prompt {
+		t.Errorf("prompt = %v, want it logged", line["prompt"])
+	}
+}
+
+func TestNothingRedacted(t *testing.T) {
+	_, line := logJSON(t, Options{Level: "info", Redact: "none"}, logRequest)
+
+	group, _ := line["llm"].(map[string]any)
+	if line["prompt"] != prompt || group["token"] != token {
+		t.Errorf("logged %v", line)
+	}
+}
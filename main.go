@@ -4,58 +4,94 @@ import (
 	"biyobot/configs"
 	"biyobot/discord"
 	"biyobot/llm"
+	"biyobot/logging"
 	"biyobot/services"
 	"biyobot/services/currency_conversion"
 	"biyobot/services/database"
+	"biyobot/services/filestore"
 	"biyobot/services/notifications"
+	"biyobot/services/polls"
+	"biyobot/services/receipts"
 	"context"
-	"log"
-	"time"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/ollama/ollama/api"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	// init configs
 	appConf, err := configs.NewAppConfig()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("invalid config", "err", err)
 	}
+	logging.Setup(appConf.Log)
+
+	// Start shuts the bot down once a signal cancels ctx
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// startup db services
-	dbm := database.NewDatabaseManager()
-	// TODO make a better repo collection system
-	notifyRepo := database.NewNotificationsRepo(dbm)
-	discordMessageRepo := database.NewDiscordMessageRepo(dbm)
+	dbm, err := database.NewDatabaseManager(appConf.Database)
+	if err != nil {
+		logging.Fatal("failed to open database", "driver", appConf.Database.Driver, "err", err)
+	}
+	slog.Info("opened database", "driver", dbm.Dialect(), "path", appConf.Database.Path, "url", logging.Secret(appConf.Database.URL))
+	migrateOnBoot(dbm)
+	repos := database.NewRepos(dbm)
+	seedMasterGuild(ctx, repos.Guilds, appConf)
+	seedChannelBindings(ctx, repos.ChannelBindings, appConf)
+
+	// uploaded attachments, content addressed
+	files, err := filestore.NewFileStore(filepath.Join(dbm.Dir(), "files"))
+	if err != nil {
+		logging.Fatal("failed to open file store", "err", err)
+	}
 
 	// ollama client
 	client, err := api.ClientFromEnvironment()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("failed to create Ollama client", "err", err)
 	}
-	log.Println("Loaded Ollama client.")
+	slog.Info("loaded Ollama client")
 
 	// testMessages := []string{
 	// 	"schedule party at 2/18 at 19:00",
 	// 	"2月18日のパーティーを削除",
 	// 	"edit meeting to tomorrow 3pm",
 	// }
-	intentService := llm.NewIntentService(client, notifyRepo, appConf)
+	intentService := llm.NewIntentService(client, repos.Notifications, repos.Expenses, repos.ChannelBindings, appConf)
 	// for _, msg := range testMessages {
 	// 	fmt.Printf("\nMessage: %s\n", msg)
-	// 	result, _ := intentService.DetectIntent(appConf.DiscordSrvSchedulerCid, msg)
+	// 	result, _ := intentService.DetectIntent(schedulerChannelID, msg, nil)
 	// 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 	// 	fmt.Printf("Result: %s\n", string(resultJSON))
 	// }
 
 	// register services
 	reg := services.NewRegistry()
-	reg.Register(configs.ServiceNames.Scheduler, notifications.NewService(notifyRepo))
+	reg.Register(configs.ServiceNames.Scheduler, notifications.NewService(repos.Notifications))
+	ocr := appConf.Services.Receipts.OCR
+	reg.Register(configs.ServiceNames.Receipts, receipts.NewService(repos.Expenses, repos.Budgets, repos.Notifications, &services.ExternalRunner{
+		Executable: ocr.Executable,
+		Args:       ocr.Args,
+		Timeout:    ocr.Timeout,
+	}, intentService))
+	reg.Register(configs.ServiceNames.Polls, polls.NewService(repos.Polls, repos.Notifications))
 	reg.Register("currency_converter", &currency_conversion.Service{})
 	reg.Register("pythonService", &services.ExternalRunner{
-		Executable: "external/test/venv/bin/python3",
-		Args:       []string{"external/test/test.py"},
-		Timeout:    10 * time.Second,
+		Executable: appConf.Services.Python.Executable,
+		Args:       appConf.Services.Python.Args,
+		Timeout:    appConf.Services.Python.Timeout,
 	})
 	// // golang service sample
 	// convert_input, _ := json.Marshal(map[string]any{"from": "USD", "to": "JPY", "amount": "15.25"})
@@ -73,9 +109,51 @@ func main() {
 	// } else {
 	// 	fmt.Printf("Result: %s\n", string(py_result.Data))
 	// }
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	discordBot := discord.NewDiscordBot(appConf, reg, intentService, discordMessageRepo, notifyRepo)
+	discordBot := discord.NewDiscordBot(appConf, reg, intentService, repos, dbm, files)
 	discordBot.Start(ctx)
 }
+
+// seedMasterGuild allows the master server and hands it the rows created
+// before data was scoped per guild.
+func seedMasterGuild(ctx context.Context, guildRepo database.Guilds, appConf *configs.AppConfig) {
+	_, err := guildRepo.EnsureGuild(ctx, database.UpsertGuildDto{GuildId: appConf.DiscordMasterServerId})
+	if err != nil {
+		logging.Fatal("failed to seed master guild", "guild_id", appConf.DiscordMasterServerId, "err", err)
+	}
+	if err := guildRepo.SetAllowed(ctx, appConf.DiscordMasterServerId, true); err != nil {
+		logging.Fatal("failed to seed master guild", "guild_id", appConf.DiscordMasterServerId, "err", err)
+	}
+	if err := guildRepo.AdoptLegacyRows(ctx, appConf.DiscordMasterServerId); err != nil {
+		logging.Fatal("failed to adopt legacy rows", "guild_id", appConf.DiscordMasterServerId, "err", err)
+	}
+}
+
+// seedChannelBindings binds the channels given through env vars, so existing
+// deployments keep working without running !bind first.
+func seedChannelBindings(ctx context.Context, bindingRepo database.ChannelBindings, appConf *configs.AppConfig) {
+	seeds := map[string]string{
+		configs.ServiceNames.Scheduler: appConf.DiscordSrvSchedulerCid,
+		configs.ServiceNames.Receipts:  appConf.DiscordSrvReceiptsCid,
+	}
+	for service, channelID := range seeds {
+		if channelID == "" {
+			continue
+		}
+		// once a service is bound anywhere, !bind/!unbind own its channels
+		bound, err := bindingRepo.GetServiceBindings(ctx, service)
+		if err != nil {
+			logging.Fatal("failed to load channel bindings", "service", service, "err", err)
+		}
+		if len(bound) > 0 {
+			continue
+		}
+		err = bindingRepo.BindChannel(ctx, database.BindChannelDto{
+			GuildId:   appConf.DiscordMasterServerId,
+			ChannelId: channelID,
+			Service:   service,
+		})
+		if err != nil {
+			logging.Fatal("failed to seed channel binding", "service", service, "channel_id", channelID, "err", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package metrics holds the bot's Prometheus metrics and the HTTP server
+// exposing them along with health checks.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// registry holds the bot's metrics and the Go runtime and process ones, it
+// is what /metrics serves.
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+var (
+	// Messages counts the messages the bot handled, by kind: command or
+	// intent.
+	Messages = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "biyobot_messages_handled_total",
+		Help: "Messages handled, by kind.",
+	}, []string{"kind"})
+
+	// Intents counts detected intents by service, action and outcome: ok,
+	// failed, denied, unknown or error when no intent could be detected.
+	Intents = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "biyobot_intents_total",
+		Help: "Intents handled, by service, action and outcome.",
+	}, []string{"service", "action", "outcome"})
+
+	LLMLatency = factory.NewHistogram(prometheus.HistogramOpts{
+		Name:    "biyobot_llm_request_duration_seconds",
+		Help:    "How long LLM requests take.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	})
+
+	LLMErrors = factory.NewCounter(prometheus.CounterOpts{
+		Name: "biyobot_llm_errors_total",
+		Help: "LLM requests that failed.",
+	})
+
+	// ServiceRuns is how long services run through the registry take, by
+	// service and whether they succeeded.
+	ServiceRuns = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "biyobot_service_run_duration_seconds",
+		Help:    "How long service runs take, by service and outcome.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"service", "outcome"})
+
+	// DispatchLag is how late notifications are delivered after they were
+	// due. Notifications delivered early count as on time.
+	DispatchLag = factory.NewHistogram(prometheus.HistogramOpts{
+		Name:    "biyobot_notification_dispatch_lag_seconds",
+		Help:    "How long after their time notifications are delivered.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	})
+
+	// DeletionBacklog is the number of expired messages the last cleanup
+	// run found still waiting to be deleted.
+	DeletionBacklog = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "biyobot_deletion_backlog",
+		Help: "Expired messages waiting to be deleted as of the last cleanup run.",
+	})
+
+	// TaskRuns counts scheduled task runs by task and outcome: ok, failed,
+	// panic, or skipped when the previous run was still going.
+	TaskRuns = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "biyobot_task_runs_total",
+		Help: "Scheduled task runs, by task and outcome.",
+	}, []string{"task", "outcome"})
+
+	TaskDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "biyobot_task_run_duration_seconds",
+		Help:    "How long scheduled task runs take, by task.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 60, 300},
+	}, []string{"task"})
+)
+
+// Outcome is the outcome label of a run that failed when err is set.
+func Outcome(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "ok"
+}
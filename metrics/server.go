@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// checkTimeout bounds each health check, a dependency that hangs is down.
+const checkTimeout = 5 * time.Second
+
+// Check is a dependency of the bot, Run returns why it isn't usable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+	// Live checks are also part of /healthz, they fail when only a restart
+	// helps. The others only make the bot not ready.
+	Live bool
+}
+
+// Serve serves Handler on addr until ctx is done.
+func Serve(ctx context.Context, addr string, checks []Check) error {
+	server := &http.Server{Addr: addr, Handler: Handler(checks), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	slog.Info("serving metrics and health checks", "addr", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler serves /metrics, /healthz and /readyz. /healthz runs the live
+// checks and /readyz all of them, both answer 503 when one fails.
+func Handler(checks []Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		var live []Check
+		for _, check := range checks {
+			if check.Live {
+				live = append(live, check)
+			}
+		}
+		writeHealth(w, runChecks(r.Context(), live))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, runChecks(r.Context(), checks))
+	})
+	return mux
+}
+
+// runChecks runs checks side by side, the result has "ok" or the error of
+// each by name.
+func runChecks(ctx context.Context, checks []Check) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]string, len(checks))
+	for _, check := range checks {
+		wg.Go(func() {
+			status := "ok"
+			if err := check.Run(ctx); err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			results[check.Name] = status
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+func writeHealth(w http.ResponseWriter, results map[string]string) {
+	status := "ok"
+	for _, result := range results {
+		if result != "ok" {
+			status = "unavailable"
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": results})
+}
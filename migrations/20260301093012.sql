@@ -0,0 +1,39 @@
+-- Create "expenses" table
+CREATE TABLE `expenses` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `user_id` varchar NULL,
+  `metadata` text NOT NULL,
+  `merchant` varchar NOT NULL,
+  `category` varchar NULL,
+  `purchased_at` datetime NULL,
+  `total_raw` integer NULL,
+  `currency` varchar NOT NULL,
+  `ocr_text` text NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_expenses_deleted_at" to table: "expenses"
+CREATE INDEX `idx_expenses_deleted_at` ON `expenses` (`deleted_at`);
+-- Create index "idx_expenses_user_id" to table: "expenses"
+CREATE INDEX `idx_expenses_user_id` ON `expenses` (`user_id`);
+-- Create index "idx_expenses_purchased_at" to table: "expenses"
+CREATE INDEX `idx_expenses_purchased_at` ON `expenses` (`purchased_at`);
+-- Create "expense_items" table
+CREATE TABLE `expense_items` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `expense_id` varchar NULL,
+  `name` varchar NOT NULL,
+  `quantity` integer NULL,
+  `amount_raw` integer NULL,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_expenses_items` FOREIGN KEY (`expense_id`) REFERENCES `expenses` (`id`) ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_expense_items_deleted_at" to table: "expense_items"
+CREATE INDEX `idx_expense_items_deleted_at` ON `expense_items` (`deleted_at`);
+-- Create index "idx_expense_items_expense_id" to table: "expense_items"
+CREATE INDEX `idx_expense_items_expense_id` ON `expense_items` (`expense_id`);
@@ -0,0 +1,19 @@
+-- Create "budgets" table
+CREATE TABLE `budgets` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `user_id` varchar NULL,
+  `category` varchar NULL,
+  `limit_raw` integer NULL,
+  `currency` varchar NOT NULL,
+  `metadata` text NOT NULL,
+  `alerted_month` varchar NULL,
+  `alerted_percent` integer NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_budgets_deleted_at" to table: "budgets"
+CREATE INDEX `idx_budgets_deleted_at` ON `budgets` (`deleted_at`);
+-- Create index "idx_budgets_user_category" to table: "budgets"
+CREATE UNIQUE INDEX `idx_budgets_user_category` ON `budgets` (`user_id`, `category`);
@@ -0,0 +1,18 @@
+-- Create "channel_bindings" table
+CREATE TABLE `channel_bindings` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `guild_id` varchar NULL,
+  `channel_id` varchar NULL,
+  `service` varchar NULL,
+  `created_by` varchar NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_channel_bindings_deleted_at" to table: "channel_bindings"
+CREATE INDEX `idx_channel_bindings_deleted_at` ON `channel_bindings` (`deleted_at`);
+-- Create index "idx_channel_bindings_guild_id" to table: "channel_bindings"
+CREATE INDEX `idx_channel_bindings_guild_id` ON `channel_bindings` (`guild_id`);
+-- Create index "idx_channel_bindings_channel_service" to table: "channel_bindings"
+CREATE UNIQUE INDEX `idx_channel_bindings_channel_service` ON `channel_bindings` (`channel_id`, `service`);
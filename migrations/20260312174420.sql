@@ -0,0 +1,8 @@
+-- Add column "user_id" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `user_id` varchar NULL;
+-- Add column "private" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `private` numeric NOT NULL DEFAULT false;
+-- Create index "idx_notifications_user_id" to table: "notifications"
+CREATE INDEX `idx_notifications_user_id` ON `notifications` (`user_id`);
+-- Backfill owners of existing notifications from their discord metadata
+UPDATE `notifications` SET `user_id` = json_extract(`metadata`, '$.UserId') WHERE `user_id` IS NULL;
@@ -0,0 +1,37 @@
+-- Create "guilds" table
+CREATE TABLE `guilds` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `guild_id` varchar NULL,
+  `name` varchar NULL,
+  `allowed` numeric NOT NULL DEFAULT false,
+  `time_zone` varchar NOT NULL DEFAULT 'Asia/Tokyo',
+  `language` varchar NOT NULL DEFAULT 'en',
+  `enabled_services` text NULL,
+  `admin_role_id` varchar NULL,
+  `added_by` varchar NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_guilds_deleted_at" to table: "guilds"
+CREATE INDEX `idx_guilds_deleted_at` ON `guilds` (`deleted_at`);
+-- Create index "idx_guilds_guild_id" to table: "guilds"
+CREATE UNIQUE INDEX `idx_guilds_guild_id` ON `guilds` (`guild_id`);
+-- Add column "guild_id" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `guild_id` varchar NULL;
+-- Create index "idx_notifications_guild_id" to table: "notifications"
+CREATE INDEX `idx_notifications_guild_id` ON `notifications` (`guild_id`);
+-- Add column "guild_id" to table: "expenses"
+ALTER TABLE `expenses` ADD COLUMN `guild_id` varchar NULL;
+-- Create index "idx_expenses_guild_id" to table: "expenses"
+CREATE INDEX `idx_expenses_guild_id` ON `expenses` (`guild_id`);
+-- Add column "guild_id" to table: "budgets"
+ALTER TABLE `budgets` ADD COLUMN `guild_id` varchar NULL;
+-- Drop index "idx_budgets_user_category" from table: "budgets"
+DROP INDEX `idx_budgets_user_category`;
+-- Create index "idx_budgets_guild_user_category" to table: "budgets"
+CREATE UNIQUE INDEX `idx_budgets_guild_user_category` ON `budgets` (`guild_id`, `user_id`, `category`);
+-- Notifications created in DMs belong to no guild, the remaining legacy rows
+-- are assigned to the master server on startup (see GuildsRepo.AdoptLegacyRows)
+UPDATE `notifications` SET `guild_id` = '' WHERE `private` = true;
@@ -0,0 +1,19 @@
+-- Create "permissions" table
+CREATE TABLE `permissions` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `guild_id` varchar NULL,
+  `subject_type` varchar NULL,
+  `subject_id` varchar NULL,
+  `service` varchar NULL,
+  `action` varchar NULL,
+  `effect` varchar NULL,
+  `created_by` varchar NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_permissions_deleted_at" to table: "permissions"
+CREATE INDEX `idx_permissions_deleted_at` ON `permissions` (`deleted_at`);
+-- Create index "idx_permissions_rule" to table: "permissions"
+CREATE UNIQUE INDEX `idx_permissions_rule` ON `permissions` (`guild_id`, `subject_type`, `subject_id`, `service`, `action`);
@@ -0,0 +1,22 @@
+-- Create "boards" table
+CREATE TABLE `boards` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `guild_id` varchar NULL,
+  `channel_id` varchar NULL,
+  `service` varchar NULL,
+  `message_id` varchar NULL,
+  `view` varchar NOT NULL DEFAULT 'all',
+  `page` integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_boards_deleted_at" to table: "boards"
+CREATE INDEX `idx_boards_deleted_at` ON `boards` (`deleted_at`);
+-- Create index "idx_boards_guild_id" to table: "boards"
+CREATE INDEX `idx_boards_guild_id` ON `boards` (`guild_id`);
+-- Create index "idx_boards_channel_service" to table: "boards"
+CREATE UNIQUE INDEX `idx_boards_channel_service` ON `boards` (`channel_id`, `service`);
+-- Create index "idx_boards_message_id" to table: "boards"
+CREATE INDEX `idx_boards_message_id` ON `boards` (`message_id`);
@@ -0,0 +1,4 @@
+-- Add column "kind" to table: "discord_messages"
+ALTER TABLE `discord_messages` ADD COLUMN `kind` varchar NULL;
+-- Add column "attempts" to table: "discord_messages"
+ALTER TABLE `discord_messages` ADD COLUMN `attempts` integer NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Add column "payload" to table: "discord_messages"
+ALTER TABLE `discord_messages` ADD COLUMN `payload` text NULL;
+-- Add column "ref_id" to table: "discord_messages"
+ALTER TABLE `discord_messages` ADD COLUMN `ref_id` varchar NULL;
+-- Create index "idx_discord_messages_ref_id" to table: "discord_messages"
+CREATE INDEX `idx_discord_messages_ref_id` ON `discord_messages` (`ref_id`);
@@ -0,0 +1,2 @@
+-- Add column "targets" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `targets` text NULL;
@@ -0,0 +1,23 @@
+-- Add column "is_event" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `is_event` numeric NOT NULL DEFAULT false;
+-- Add column "capacity" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `capacity` integer NOT NULL DEFAULT 0;
+-- Add column "rsvp_channel_id" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `rsvp_channel_id` varchar NULL;
+-- Add column "rsvp_message_id" to table: "notifications"
+ALTER TABLE `notifications` ADD COLUMN `rsvp_message_id` varchar NULL;
+-- Create "attendees" table
+CREATE TABLE `attendees` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `notification_id` varchar NULL,
+  `user_id` varchar NULL,
+  `status` varchar NOT NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_attendees_deleted_at" to table: "attendees"
+CREATE INDEX `idx_attendees_deleted_at` ON `attendees` (`deleted_at`);
+-- Create index "idx_attendees_notification_user" to table: "attendees"
+CREATE UNIQUE INDEX `idx_attendees_notification_user` ON `attendees` (`notification_id`, `user_id`);
@@ -0,0 +1,42 @@
+-- Create "polls" table
+CREATE TABLE `polls` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `guild_id` varchar NULL,
+  `channel_id` varchar NULL,
+  `message_id` varchar NULL,
+  `user_id` varchar NULL,
+  `question` varchar NOT NULL,
+  `options` text NOT NULL,
+  `multi_choice` numeric NOT NULL DEFAULT false,
+  `anonymous` numeric NOT NULL DEFAULT false,
+  `closes_at` datetime NULL,
+  `notification_id` varchar NULL,
+  `closed` numeric NOT NULL DEFAULT false,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_polls_deleted_at" to table: "polls"
+CREATE INDEX `idx_polls_deleted_at` ON `polls` (`deleted_at`);
+-- Create index "idx_polls_guild_id" to table: "polls"
+CREATE INDEX `idx_polls_guild_id` ON `polls` (`guild_id`);
+-- Create index "idx_polls_message_id" to table: "polls"
+CREATE INDEX `idx_polls_message_id` ON `polls` (`message_id`);
+-- Create index "idx_polls_notification_id" to table: "polls"
+CREATE INDEX `idx_polls_notification_id` ON `polls` (`notification_id`);
+-- Create "poll_votes" table
+CREATE TABLE `poll_votes` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `poll_id` varchar NULL,
+  `user_id` varchar NULL,
+  `option` integer NOT NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_poll_votes_deleted_at" to table: "poll_votes"
+CREATE INDEX `idx_poll_votes_deleted_at` ON `poll_votes` (`deleted_at`);
+-- Create index "idx_poll_votes_poll_user_option" to table: "poll_votes"
+CREATE UNIQUE INDEX `idx_poll_votes_poll_user_option` ON `poll_votes` (`poll_id`, `user_id`, `option`);
@@ -0,0 +1,25 @@
+-- Create "audit_logs" table
+CREATE TABLE `audit_logs` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `entity` varchar NULL,
+  `entity_id` varchar NULL,
+  `service` varchar NULL,
+  `action` varchar NOT NULL,
+  `guild_id` varchar NULL,
+  `user_id` varchar NULL,
+  `channel_id` varchar NULL,
+  `message_id` varchar NULL,
+  `before` text NULL,
+  `after` text NULL,
+  `undone_at` datetime NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_audit_logs_deleted_at" to table: "audit_logs"
+CREATE INDEX `idx_audit_logs_deleted_at` ON `audit_logs` (`deleted_at`);
+-- Create index "idx_audit_logs_entity" to table: "audit_logs"
+CREATE INDEX `idx_audit_logs_entity` ON `audit_logs` (`entity`, `entity_id`);
+-- Create index "idx_audit_logs_guild_user" to table: "audit_logs"
+CREATE INDEX `idx_audit_logs_guild_user` ON `audit_logs` (`guild_id`, `user_id`);
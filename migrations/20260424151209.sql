@@ -0,0 +1,16 @@
+-- Create "task_runs" table
+CREATE TABLE `task_runs` (
+  `id` varchar NULL,
+  `created_at` datetime NULL,
+  `updated_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `name` varchar NULL,
+  `last_run_at` datetime NULL,
+  `duration_ms` integer NOT NULL DEFAULT 0,
+  `error` text NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_task_runs_deleted_at" to table: "task_runs"
+CREATE INDEX `idx_task_runs_deleted_at` ON `task_runs` (`deleted_at`);
+-- Create index "idx_task_runs_name" to table: "task_runs"
+CREATE UNIQUE INDEX `idx_task_runs_name` ON `task_runs` (`name`);
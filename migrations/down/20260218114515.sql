@@ -0,0 +1,2 @@
+-- Drop "notifications" table
+DROP TABLE `notifications`;
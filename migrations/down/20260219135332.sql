@@ -0,0 +1,2 @@
+-- Drop "discord_messages" table
+DROP TABLE `discord_messages`;
@@ -0,0 +1,4 @@
+-- Drop "expense_items" table
+DROP TABLE `expense_items`;
+-- Drop "expenses" table
+DROP TABLE `expenses`;
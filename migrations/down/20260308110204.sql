@@ -0,0 +1,2 @@
+-- Drop "channel_bindings" table
+DROP TABLE `channel_bindings`;
@@ -0,0 +1,6 @@
+-- Drop index "idx_notifications_user_id" from table: "notifications"
+DROP INDEX `idx_notifications_user_id`;
+-- Drop column "private" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `private`;
+-- Drop column "user_id" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `user_id`;
@@ -0,0 +1,17 @@
+-- Drop index "idx_budgets_guild_user_category" from table: "budgets"
+DROP INDEX `idx_budgets_guild_user_category`;
+-- Drop column "guild_id" from table: "budgets"
+ALTER TABLE `budgets` DROP COLUMN `guild_id`;
+-- Create index "idx_budgets_user_category" to table: "budgets", fails when
+-- users have budgets for the same category in several guilds
+CREATE UNIQUE INDEX `idx_budgets_user_category` ON `budgets` (`user_id`, `category`);
+-- Drop index "idx_expenses_guild_id" from table: "expenses"
+DROP INDEX `idx_expenses_guild_id`;
+-- Drop column "guild_id" from table: "expenses"
+ALTER TABLE `expenses` DROP COLUMN `guild_id`;
+-- Drop index "idx_notifications_guild_id" from table: "notifications"
+DROP INDEX `idx_notifications_guild_id`;
+-- Drop column "guild_id" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `guild_id`;
+-- Drop "guilds" table
+DROP TABLE `guilds`;
@@ -0,0 +1,2 @@
+-- Drop "permissions" table
+DROP TABLE `permissions`;
@@ -0,0 +1,2 @@
+-- Drop "boards" table
+DROP TABLE `boards`;
@@ -0,0 +1,4 @@
+-- Drop column "attempts" from table: "discord_messages"
+ALTER TABLE `discord_messages` DROP COLUMN `attempts`;
+-- Drop column "kind" from table: "discord_messages"
+ALTER TABLE `discord_messages` DROP COLUMN `kind`;
@@ -0,0 +1,6 @@
+-- Drop index "idx_discord_messages_ref_id" from table: "discord_messages"
+DROP INDEX `idx_discord_messages_ref_id`;
+-- Drop column "ref_id" from table: "discord_messages"
+ALTER TABLE `discord_messages` DROP COLUMN `ref_id`;
+-- Drop column "payload" from table: "discord_messages"
+ALTER TABLE `discord_messages` DROP COLUMN `payload`;
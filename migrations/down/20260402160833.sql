@@ -0,0 +1,2 @@
+-- Drop column "targets" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `targets`;
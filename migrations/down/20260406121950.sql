@@ -0,0 +1,10 @@
+-- Drop "attendees" table
+DROP TABLE `attendees`;
+-- Drop column "rsvp_message_id" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `rsvp_message_id`;
+-- Drop column "rsvp_channel_id" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `rsvp_channel_id`;
+-- Drop column "capacity" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `capacity`;
+-- Drop column "is_event" from table: "notifications"
+ALTER TABLE `notifications` DROP COLUMN `is_event`;
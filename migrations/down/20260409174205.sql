@@ -0,0 +1,4 @@
+-- Drop "poll_votes" table
+DROP TABLE `poll_votes`;
+-- Drop "polls" table
+DROP TABLE `polls`;
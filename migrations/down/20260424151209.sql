@@ -0,0 +1,2 @@
+-- Drop "task_runs" table
+DROP TABLE `task_runs`;
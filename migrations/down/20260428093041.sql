@@ -0,0 +1,2 @@
+-- Drop column "waitlisted_at" from table: "attendees"
+ALTER TABLE `attendees` DROP COLUMN `waitlisted_at`;
@@ -0,0 +1,26 @@
+// Package migrations embeds the schema migrations into the binary, one
+// directory per database dialect: SQLite at the top level and PostgreSQL in
+// postgres/. The migrations are generated with atlas (see
+// scripts/migrate.sh), each has a hand written counterpart in down/
+// reverting it.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed *.sql down/*.sql postgres/*.sql postgres/down/*.sql
+var files embed.FS
+
+// For returns the migrations of a dialect.
+func For(dialect string) (fs.FS, error) {
+	switch dialect {
+	case "sqlite":
+		return files, nil
+	case "postgres":
+		return fs.Sub(files, "postgres")
+	}
+	return nil, fmt.Errorf("no migrations for %s databases", dialect)
+}
@@ -0,0 +1,247 @@
+-- Create "notifications" table
+CREATE TABLE "notifications" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "service" character varying(50) NULL,
+  "metadata" text NOT NULL,
+  "notify_at" timestamptz NULL,
+  "title" character varying(200) NOT NULL,
+  "message" character varying(200) NOT NULL,
+  "guild_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "private" boolean NOT NULL DEFAULT false,
+  "targets" text NULL,
+  "is_event" boolean NOT NULL DEFAULT false,
+  "capacity" bigint NOT NULL DEFAULT 0,
+  "rsvp_channel_id" character varying(36) NULL,
+  "rsvp_message_id" character varying(36) NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_notifications_deleted_at" to table: "notifications"
+CREATE INDEX "idx_notifications_deleted_at" ON "notifications" ("deleted_at");
+-- Create index "idx_notifications_guild_id" to table: "notifications"
+CREATE INDEX "idx_notifications_guild_id" ON "notifications" ("guild_id");
+-- Create index "idx_notifications_user_id" to table: "notifications"
+CREATE INDEX "idx_notifications_user_id" ON "notifications" ("user_id");
+-- Create "discord_messages" table
+CREATE TABLE "discord_messages" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "action" character varying(50) NULL,
+  "channel_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "message_id" character varying(36) NULL,
+  "content" text NOT NULL,
+  "execute_action_on" timestamptz NULL,
+  "kind" character varying(20) NULL,
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "payload" text NULL,
+  "ref_id" character varying(36) NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_discord_messages_deleted_at" to table: "discord_messages"
+CREATE INDEX "idx_discord_messages_deleted_at" ON "discord_messages" ("deleted_at");
+-- Create index "idx_discord_messages_ref_id" to table: "discord_messages"
+CREATE INDEX "idx_discord_messages_ref_id" ON "discord_messages" ("ref_id");
+-- Create "expenses" table
+CREATE TABLE "expenses" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "metadata" text NOT NULL,
+  "merchant" character varying(200) NOT NULL,
+  "category" character varying(50) NULL,
+  "purchased_at" timestamptz NULL,
+  "total_raw" bigint NULL,
+  "currency" character varying(3) NOT NULL,
+  "ocr_text" text NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_expenses_deleted_at" to table: "expenses"
+CREATE INDEX "idx_expenses_deleted_at" ON "expenses" ("deleted_at");
+-- Create index "idx_expenses_guild_id" to table: "expenses"
+CREATE INDEX "idx_expenses_guild_id" ON "expenses" ("guild_id");
+-- Create index "idx_expenses_user_id" to table: "expenses"
+CREATE INDEX "idx_expenses_user_id" ON "expenses" ("user_id");
+-- Create index "idx_expenses_purchased_at" to table: "expenses"
+CREATE INDEX "idx_expenses_purchased_at" ON "expenses" ("purchased_at");
+-- Create "expense_items" table
+CREATE TABLE "expense_items" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "expense_id" character varying(36) NULL,
+  "name" character varying(200) NOT NULL,
+  "quantity" bigint NULL,
+  "amount_raw" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_expenses_items" FOREIGN KEY ("expense_id") REFERENCES "expenses" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_expense_items_deleted_at" to table: "expense_items"
+CREATE INDEX "idx_expense_items_deleted_at" ON "expense_items" ("deleted_at");
+-- Create index "idx_expense_items_expense_id" to table: "expense_items"
+CREATE INDEX "idx_expense_items_expense_id" ON "expense_items" ("expense_id");
+-- Create "budgets" table
+CREATE TABLE "budgets" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "category" character varying(50) NULL,
+  "limit_raw" bigint NULL,
+  "currency" character varying(3) NOT NULL,
+  "metadata" text NOT NULL,
+  "alerted_month" character varying(7) NULL,
+  "alerted_percent" bigint NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_budgets_deleted_at" to table: "budgets"
+CREATE INDEX "idx_budgets_deleted_at" ON "budgets" ("deleted_at");
+-- Create index "idx_budgets_guild_user_category" to table: "budgets"
+CREATE UNIQUE INDEX "idx_budgets_guild_user_category" ON "budgets" ("guild_id", "user_id", "category");
+-- Create "channel_bindings" table
+CREATE TABLE "channel_bindings" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "channel_id" character varying(36) NULL,
+  "service" character varying(50) NULL,
+  "created_by" character varying(36) NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_channel_bindings_deleted_at" to table: "channel_bindings"
+CREATE INDEX "idx_channel_bindings_deleted_at" ON "channel_bindings" ("deleted_at");
+-- Create index "idx_channel_bindings_guild_id" to table: "channel_bindings"
+CREATE INDEX "idx_channel_bindings_guild_id" ON "channel_bindings" ("guild_id");
+-- Create index "idx_channel_bindings_channel_service" to table: "channel_bindings"
+CREATE UNIQUE INDEX "idx_channel_bindings_channel_service" ON "channel_bindings" ("channel_id", "service");
+-- Create "guilds" table
+CREATE TABLE "guilds" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "name" character varying(200) NULL,
+  "allowed" boolean NOT NULL DEFAULT false,
+  "time_zone" character varying(64) NOT NULL DEFAULT 'Asia/Tokyo',
+  "language" character varying(8) NOT NULL DEFAULT 'en',
+  "enabled_services" text NULL,
+  "admin_role_id" character varying(36) NULL,
+  "added_by" character varying(36) NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_guilds_deleted_at" to table: "guilds"
+CREATE INDEX "idx_guilds_deleted_at" ON "guilds" ("deleted_at");
+-- Create index "idx_guilds_guild_id" to table: "guilds"
+CREATE UNIQUE INDEX "idx_guilds_guild_id" ON "guilds" ("guild_id");
+-- Create "permissions" table
+CREATE TABLE "permissions" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "subject_type" character varying(10) NULL,
+  "subject_id" character varying(36) NULL,
+  "service" character varying(50) NULL,
+  "action" character varying(50) NULL,
+  "effect" character varying(10) NULL,
+  "created_by" character varying(36) NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_permissions_deleted_at" to table: "permissions"
+CREATE INDEX "idx_permissions_deleted_at" ON "permissions" ("deleted_at");
+-- Create index "idx_permissions_rule" to table: "permissions"
+CREATE UNIQUE INDEX "idx_permissions_rule" ON "permissions" ("guild_id", "subject_type", "subject_id", "service", "action");
+-- Create "boards" table
+CREATE TABLE "boards" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "channel_id" character varying(36) NULL,
+  "service" character varying(50) NULL,
+  "message_id" character varying(36) NULL,
+  "view" character varying(10) NOT NULL DEFAULT 'all',
+  "page" bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_boards_deleted_at" to table: "boards"
+CREATE INDEX "idx_boards_deleted_at" ON "boards" ("deleted_at");
+-- Create index "idx_boards_guild_id" to table: "boards"
+CREATE INDEX "idx_boards_guild_id" ON "boards" ("guild_id");
+-- Create index "idx_boards_channel_service" to table: "boards"
+CREATE UNIQUE INDEX "idx_boards_channel_service" ON "boards" ("channel_id", "service");
+-- Create index "idx_boards_message_id" to table: "boards"
+CREATE INDEX "idx_boards_message_id" ON "boards" ("message_id");
+-- Create "attendees" table
+CREATE TABLE "attendees" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "notification_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "status" character varying(10) NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_attendees_deleted_at" to table: "attendees"
+CREATE INDEX "idx_attendees_deleted_at" ON "attendees" ("deleted_at");
+-- Create index "idx_attendees_notification_user" to table: "attendees"
+CREATE UNIQUE INDEX "idx_attendees_notification_user" ON "attendees" ("notification_id", "user_id");
+-- Create "polls" table
+CREATE TABLE "polls" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "guild_id" character varying(36) NULL,
+  "channel_id" character varying(36) NULL,
+  "message_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "question" character varying(200) NOT NULL,
+  "options" text NOT NULL,
+  "multi_choice" boolean NOT NULL DEFAULT false,
+  "anonymous" boolean NOT NULL DEFAULT false,
+  "closes_at" timestamptz NULL,
+  "notification_id" character varying(36) NULL,
+  "closed" boolean NOT NULL DEFAULT false,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_polls_deleted_at" to table: "polls"
+CREATE INDEX "idx_polls_deleted_at" ON "polls" ("deleted_at");
+-- Create index "idx_polls_guild_id" to table: "polls"
+CREATE INDEX "idx_polls_guild_id" ON "polls" ("guild_id");
+-- Create index "idx_polls_message_id" to table: "polls"
+CREATE INDEX "idx_polls_message_id" ON "polls" ("message_id");
+-- Create index "idx_polls_notification_id" to table: "polls"
+CREATE INDEX "idx_polls_notification_id" ON "polls" ("notification_id");
+-- Create "poll_votes" table
+CREATE TABLE "poll_votes" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "poll_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "option" bigint NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_poll_votes_deleted_at" to table: "poll_votes"
+CREATE INDEX "idx_poll_votes_deleted_at" ON "poll_votes" ("deleted_at");
+-- Create index "idx_poll_votes_poll_user_option" to table: "poll_votes"
+CREATE UNIQUE INDEX "idx_poll_votes_poll_user_option" ON "poll_votes" ("poll_id", "user_id", "option");
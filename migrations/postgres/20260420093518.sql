@@ -0,0 +1,25 @@
+-- Create "audit_logs" table
+CREATE TABLE "audit_logs" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "entity" character varying(50) NULL,
+  "entity_id" character varying(36) NULL,
+  "service" character varying(50) NULL,
+  "action" character varying(10) NOT NULL,
+  "guild_id" character varying(36) NULL,
+  "user_id" character varying(36) NULL,
+  "channel_id" character varying(36) NULL,
+  "message_id" character varying(36) NULL,
+  "before" text NULL,
+  "after" text NULL,
+  "undone_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_audit_logs_deleted_at" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_deleted_at" ON "audit_logs" ("deleted_at");
+-- Create index "idx_audit_logs_entity" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_entity" ON "audit_logs" ("entity", "entity_id");
+-- Create index "idx_audit_logs_guild_user" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_guild_user" ON "audit_logs" ("guild_id", "user_id");
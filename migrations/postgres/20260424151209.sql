@@ -0,0 +1,16 @@
+-- Create "task_runs" table
+CREATE TABLE "task_runs" (
+  "id" character varying(36) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "name" character varying(50) NULL,
+  "last_run_at" timestamptz NULL,
+  "duration_ms" bigint NOT NULL DEFAULT 0,
+  "error" text NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_task_runs_deleted_at" to table: "task_runs"
+CREATE INDEX "idx_task_runs_deleted_at" ON "task_runs" ("deleted_at");
+-- Create index "idx_task_runs_name" to table: "task_runs"
+CREATE UNIQUE INDEX "idx_task_runs_name" ON "task_runs" ("name");
@@ -0,0 +1,4 @@
+-- Add column "waitlisted_at" to table: "attendees"
+ALTER TABLE "attendees" ADD COLUMN "waitlisted_at" timestamptz NULL;
+-- Backfill the waitlist, its last change is when the status was set
+UPDATE "attendees" SET "waitlisted_at" = "updated_at" WHERE "status" = 'waitlist';
@@ -0,0 +1,24 @@
+-- Drop "poll_votes" table
+DROP TABLE "poll_votes";
+-- Drop "polls" table
+DROP TABLE "polls";
+-- Drop "attendees" table
+DROP TABLE "attendees";
+-- Drop "boards" table
+DROP TABLE "boards";
+-- Drop "permissions" table
+DROP TABLE "permissions";
+-- Drop "guilds" table
+DROP TABLE "guilds";
+-- Drop "channel_bindings" table
+DROP TABLE "channel_bindings";
+-- Drop "budgets" table
+DROP TABLE "budgets";
+-- Drop "expense_items" table
+DROP TABLE "expense_items";
+-- Drop "expenses" table
+DROP TABLE "expenses";
+-- Drop "discord_messages" table
+DROP TABLE "discord_messages";
+-- Drop "notifications" table
+DROP TABLE "notifications";
@@ -15,11 +15,31 @@ type BaseModel struct {
 
 func (b *BaseModel) BeforeCreate(tx *gorm.DB) error {
 	if b.ID == uuid.Nil {
-		if newId, err := uuid.NewV7(); err == nil {
-			b.ID = newId
-		} else {
-			b.ID = uuid.New()
-		}
+		b.ID = newId()
 	}
 	return nil
 }
+
+// SoftDeleteModel is BaseModel for rows deletes must be able to take back.
+// Deletes through gorm set DeletedAt and queries skip the row, repos that
+// want rows gone for good delete Unscoped.
+type SoftDeleteModel struct {
+	ID        uuid.UUID      `gorm:"type:varchar(36);primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+func (b *SoftDeleteModel) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = newId()
+	}
+	return nil
+}
+
+func newId() uuid.UUID {
+	if id, err := uuid.NewV7(); err == nil {
+		return id
+	}
+	return uuid.New()
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"biyobot/mixins"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attendee is a user's RSVP to an event notification.
+type Attendee struct {
+	mixins.SoftDeleteModel
+	NotificationId uuid.UUID `gorm:"type:varchar(36);uniqueIndex:idx_attendees_notification_user" json:"notification_id"`
+	UserId         string    `gorm:"type:varchar(36);uniqueIndex:idx_attendees_notification_user" json:"user_id"`
+	Status         string    `gorm:"type:varchar(10);not null" json:"status"` // going | maybe | declined | waitlist
+	// when the user joined the waitlist, the longest waiting is promoted
+	// first. Nil unless the status is waitlist.
+	WaitlistedAt *time.Time `json:"waitlisted_at"`
+}
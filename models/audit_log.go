@@ -0,0 +1,24 @@
+package models
+
+import (
+	"biyobot/mixins"
+	"time"
+)
+
+// AuditLog is one change made to a row, who made it and from which Discord
+// message, with the row as JSON before and after. Undone entries are kept
+// and marked, the undo is an entry of its own.
+type AuditLog struct {
+	mixins.BaseModel
+	Entity    string     `gorm:"type:varchar(50);index:idx_audit_logs_entity" json:"entity"` // notification
+	EntityId  string     `gorm:"type:varchar(36);index:idx_audit_logs_entity" json:"entity_id"`
+	Service   string     `gorm:"type:varchar(50)" json:"service"`
+	Action    string     `gorm:"type:varchar(10);not null" json:"action"` // create | edit | delete | undo
+	GuildId   string     `gorm:"type:varchar(36);index:idx_audit_logs_guild_user" json:"guild_id"`
+	UserId    string     `gorm:"type:varchar(36);index:idx_audit_logs_guild_user" json:"user_id"` // empty when the bot made the change
+	ChannelId string     `gorm:"type:varchar(36)" json:"channel_id"`
+	MessageId string     `gorm:"type:varchar(36)" json:"message_id"`
+	Before    string     `gorm:"type:text" json:"before"`
+	After     string     `gorm:"type:text" json:"after"`
+	UndoneAt  *time.Time `json:"undone_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"biyobot/mixins"
+)
+
+// Board is a message the bot keeps up to date with a service's data, e.g.
+// the upcoming notifications of a scheduler channel.
+type Board struct {
+	mixins.BaseModel
+	GuildId   string `gorm:"type:varchar(36);index" json:"guild_id"`
+	ChannelId string `gorm:"type:varchar(36);uniqueIndex:idx_boards_channel_service" json:"channel_id"`
+	Service   string `gorm:"type:varchar(50);uniqueIndex:idx_boards_channel_service" json:"service"`
+	MessageId string `gorm:"type:varchar(36);index" json:"message_id"`
+	View      string `gorm:"type:varchar(10);not null;default:all" json:"view"` // all | week | today
+	Page      int    `gorm:"not null;default:0" json:"page"`
+}
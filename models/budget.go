@@ -0,0 +1,17 @@
+package models
+
+import (
+	"biyobot/mixins"
+)
+
+type Budget struct {
+	mixins.BaseModel
+	GuildId        string `gorm:"type:varchar(36);uniqueIndex:idx_budgets_guild_user_category" json:"guild_id"`
+	UserId         string `gorm:"type:varchar(36);uniqueIndex:idx_budgets_guild_user_category" json:"user_id"`
+	Category       string `gorm:"type:varchar(50);uniqueIndex:idx_budgets_guild_user_category" json:"category"`
+	LimitRaw       int64  `json:"limit_raw"` // monthly limit in minor units of Currency
+	Currency       string `gorm:"type:varchar(3);not null" json:"currency"`
+	Metadata       string `gorm:"type:text;not null" json:"metadata"`
+	AlertedMonth   string `gorm:"type:varchar(7)" json:"alerted_month"` // YYYY-MM of the last threshold alert
+	AlertedPercent int    `json:"alerted_percent"`
+}
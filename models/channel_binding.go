@@ -0,0 +1,13 @@
+package models
+
+import (
+	"biyobot/mixins"
+)
+
+type ChannelBinding struct {
+	mixins.BaseModel
+	GuildId   string `gorm:"type:varchar(36);index" json:"guild_id"`
+	ChannelId string `gorm:"type:varchar(36);uniqueIndex:idx_channel_bindings_channel_service" json:"channel_id"`
+	Service   string `gorm:"type:varchar(50);uniqueIndex:idx_channel_bindings_channel_service" json:"service"`
+	CreatedBy string `gorm:"type:varchar(36)" json:"created_by"`
+}
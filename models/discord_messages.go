@@ -7,10 +7,14 @@ import (
 
 type DiscordMessage struct {
 	mixins.BaseModel
-	Action          string    `gorm:"type:varchar(50)" json:"action"` // delete | edit
+	Action          string    `gorm:"type:varchar(50)" json:"action"` // post | edit | pin | unpin | delete
 	ChannelId       string    `gorm:"type:varchar(36)" json:"channel_id"`
 	UserId          string    `gorm:"type:varchar(36)" json:"user_id"`
 	MessageId       string    `gorm:"type:varchar(36)" json:"message_id"`
 	Content         string    `gorm:"type:text;not null" json:"content"`
 	ExecuteActionOn time.Time `json:"execute_action_on"`
+	Kind            string    `gorm:"type:varchar(20)" json:"kind"` // see configs.MessageKinds
+	Attempts        int       `gorm:"not null;default:0" json:"attempts"`
+	Payload         string    `gorm:"type:text" json:"payload"`             // JSON, what to post or edit in
+	RefId           string    `gorm:"type:varchar(36);index" json:"ref_id"` // what the action belongs to, e.g. a notification
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"biyobot/mixins"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Expense struct {
+	mixins.BaseModel
+	GuildId     string        `gorm:"type:varchar(36);index" json:"guild_id"` // empty when scanned in a DM
+	UserId      string        `gorm:"type:varchar(36);index" json:"user_id"`
+	Metadata    string        `gorm:"type:text;not null" json:"metadata"`
+	Merchant    string        `gorm:"type:varchar(200);not null" json:"merchant"`
+	Category    string        `gorm:"type:varchar(50)" json:"category"`
+	PurchasedAt time.Time     `gorm:"index" json:"purchased_at"`
+	TotalRaw    int64         `json:"total_raw"` // minor units of Currency
+	Currency    string        `gorm:"type:varchar(3);not null" json:"currency"`
+	OcrText     string        `gorm:"type:text" json:"ocr_text"`
+	Items       []ExpenseItem `gorm:"foreignKey:ExpenseId" json:"items"`
+}
+
+type ExpenseItem struct {
+	mixins.BaseModel
+	ExpenseId uuid.UUID `gorm:"type:varchar(36);index" json:"expense_id"`
+	Name      string    `gorm:"type:varchar(200);not null" json:"name"`
+	Quantity  int       `json:"quantity"`
+	AmountRaw int64     `json:"amount_raw"` // minor units of Expense.Currency
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"biyobot/mixins"
+)
+
+type Guild struct {
+	mixins.BaseModel
+	GuildId         string `gorm:"type:varchar(36);uniqueIndex" json:"guild_id"`
+	Name            string `gorm:"type:varchar(200)" json:"name"`
+	Allowed         bool   `gorm:"not null;default:false" json:"allowed"`
+	TimeZone        string `gorm:"type:varchar(64);not null;default:Asia/Tokyo" json:"time_zone"`
+	Language        string `gorm:"type:varchar(8);not null;default:en" json:"language"` // en | ja
+	EnabledServices string `gorm:"type:text" json:"enabled_services"`                   // comma separated, empty enables all
+	AdminRoleId     string `gorm:"type:varchar(36)" json:"admin_role_id"`
+	AddedBy         string `gorm:"type:varchar(36)" json:"added_by"`
+}
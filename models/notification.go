@@ -6,10 +6,20 @@ import (
 )
 
 type Notification struct {
-	mixins.BaseModel
+	mixins.SoftDeleteModel
 	Service  string    `gorm:"type:varchar(50)" json:"service"`
 	Metadata string    `gorm:"type:text;not null" json:"metadata"`
 	NotifyAt time.Time `json:"notify_at"`
 	Title    string    `gorm:"type:varchar(200);not null" json:"title"`
 	Message  string    `gorm:"type:varchar(200);not null" json:"message"`
+	GuildId  string    `gorm:"type:varchar(36);index" json:"guild_id"` // empty when created in a DM
+	UserId   string    `gorm:"type:varchar(36);index" json:"user_id"`
+	Private  bool      `gorm:"not null;default:false" json:"private"` // created in a DM, hidden from boards
+	Targets  string    `gorm:"type:text" json:"targets"`              // JSON []configs.NotificationTarget, empty DMs the creator
+	// events take RSVPs through buttons on a post, reminders only go to
+	// attendees who are going
+	IsEvent       bool   `gorm:"not null;default:false" json:"is_event"`
+	Capacity      int    `gorm:"not null;default:0" json:"capacity"` // 0 is unlimited
+	RsvpChannelId string `gorm:"type:varchar(36)" json:"rsvp_channel_id"`
+	RsvpMessageId string `gorm:"type:varchar(36)" json:"rsvp_message_id"`
 }
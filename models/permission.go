@@ -0,0 +1,18 @@
+package models
+
+import (
+	"biyobot/mixins"
+)
+
+// Permission allows or denies a Discord role or user a service, or a single
+// action of it. "*" matches every service or action.
+type Permission struct {
+	mixins.BaseModel
+	GuildId     string `gorm:"type:varchar(36);uniqueIndex:idx_permissions_rule" json:"guild_id"`
+	SubjectType string `gorm:"type:varchar(10);uniqueIndex:idx_permissions_rule" json:"subject_type"` // role | user
+	SubjectId   string `gorm:"type:varchar(36);uniqueIndex:idx_permissions_rule" json:"subject_id"`
+	Service     string `gorm:"type:varchar(50);uniqueIndex:idx_permissions_rule" json:"service"`
+	Action      string `gorm:"type:varchar(50);uniqueIndex:idx_permissions_rule" json:"action"`
+	Effect      string `gorm:"type:varchar(10)" json:"effect"` // allow | deny
+	CreatedBy   string `gorm:"type:varchar(36)" json:"created_by"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"biyobot/mixins"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Poll is a question members vote on with buttons, closed by hand or at
+// ClosesAt through a scheduled notification.
+type Poll struct {
+	mixins.BaseModel
+	GuildId        string     `gorm:"type:varchar(36);index" json:"guild_id"`
+	ChannelId      string     `gorm:"type:varchar(36)" json:"channel_id"`
+	MessageId      string     `gorm:"type:varchar(36);index" json:"message_id"`
+	UserId         string     `gorm:"type:varchar(36)" json:"user_id"`
+	Question       string     `gorm:"type:varchar(200);not null" json:"question"`
+	Options        string     `gorm:"type:text;not null" json:"options"` // JSON []string
+	MultiChoice    bool       `gorm:"not null;default:false" json:"multi_choice"`
+	Anonymous      bool       `gorm:"not null;default:false" json:"anonymous"` // tallies only, voters are not shown
+	ClosesAt       *time.Time `json:"closes_at"`
+	NotificationId *uuid.UUID `gorm:"type:varchar(36);index" json:"notification_id"` // closes the poll at ClosesAt
+	Closed         bool       `gorm:"not null;default:false" json:"closed"`
+}
+
+// PollVote is one option a user picked, single choice polls keep at most
+// one per user.
+type PollVote struct {
+	mixins.BaseModel
+	PollId uuid.UUID `gorm:"type:varchar(36);uniqueIndex:idx_poll_votes_poll_user_option" json:"poll_id"`
+	UserId string    `gorm:"type:varchar(36);uniqueIndex:idx_poll_votes_poll_user_option" json:"user_id"`
+	Option int       `gorm:"not null;uniqueIndex:idx_poll_votes_poll_user_option" json:"option"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"biyobot/mixins"
+	"time"
+)
+
+// TaskRun is the last run of a scheduled task, kept so a restart doesn't
+// run a daily task again before it is due.
+type TaskRun struct {
+	mixins.BaseModel
+	Name       string    `gorm:"type:varchar(50);uniqueIndex:idx_task_runs_name" json:"name"`
+	LastRunAt  time.Time `json:"last_run_at"`
+	DurationMs int64     `gorm:"not null;default:0" json:"duration_ms"`
+	Error      string    `gorm:"type:text" json:"error"` // empty when the run succeeded
+}
@@ -5,7 +5,7 @@ cd ..
 
 case "$1" in
 "revert")
-    atlas migrate down 1 --env app --url "sqlite://dbs/app.db"
+    go run . migrate down
     if [ $? -eq 0 ]; then
         echo "✓ Successfully reverted"
     else
@@ -14,23 +14,33 @@ case "$1" in
     ;;
 "refresh")
     atlas migrate hash --env app
+    atlas migrate hash --env postgres
     echo "✓ Successfully refreshed"
     ;;
 "apply")
-    atlas migrate apply --env app --url "sqlite://dbs/app.db"
+    go run . migrate up
     if [ $? -eq 0 ]; then
         echo "✓ Successfully migrated: $db_file"
     else
         echo "✗ Failed to migrate: $db_file"
     fi
     ;;
+"status")
+    go run . migrate status
+    ;;
 "add")
+    # new migrations also need a down/<version>.sql reverting them, for
+    # both dialects
     atlas migrate diff --env app
+    atlas migrate diff --env postgres
     ;;
 *)
     echo "$1 not a command"
     echo "Commands:"
     echo "  add"
     echo "  apply"
+    echo "  revert"
+    echo "  status"
+    echo "  refresh"
     ;;
 esac
@@ -2,6 +2,7 @@ package currency_conversion
 
 import (
 	"biyobot/configs"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -65,7 +66,7 @@ func toAmount(raw int64, currency string) string {
 
 type Service struct{}
 
-func (s *Service) Run(msg json.RawMessage) configs.ServiceResult {
+func (s *Service) Run(ctx context.Context, msg json.RawMessage) configs.ServiceResult {
 	var input Input
 	if err := json.Unmarshal(msg, &input); err != nil {
 		return configs.Failure("invalid input: " + err.Error())
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"biyobot/models"
+	"biyobot/utils"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AttendeesRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewAttendeesRepo(dbm *DatabaseManager) *AttendeesRepo {
+	return &AttendeesRepo{dbm: dbm}
+}
+
+// GetAttendees returns an event's RSVPs in the order they were first made.
+// The waitlist goes by WaitlistedAt instead, see Rsvp.
+func (r *AttendeesRepo) GetAttendees(ctx context.Context, notificationId uuid.UUID) ([]models.Attendee, error) {
+	var attendees []models.Attendee
+	err := r.dbm.App().WithContext(ctx).
+		Where("notification_id = ?", notificationId).
+		Order("created_at ASC").
+		Find(&attendees).Error
+	return attendees, err
+}
+
+// CountGoing returns how many attendees are going to each of the events.
+func (r *AttendeesRepo) CountGoing(ctx context.Context, notificationIds []uuid.UUID) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		NotificationId uuid.UUID
+		Count          int
+	}
+	err := r.dbm.App().WithContext(ctx).Model(&models.Attendee{}).
+		Select("notification_id, COUNT(*) AS count").
+		Where("notification_id IN ? AND status = ?", notificationIds, "going").
+		Group("notification_id").
+		Scan(&rows).Error
+	counts := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		counts[row.NotificationId] = row.Count
+	}
+	return counts, err
+}
+
+type RsvpDto struct {
+	NotificationId uuid.UUID `json:"notification_id"`
+	UserId         string    `json:"user_id"`
+	Status         string    `json:"status"` // going | maybe | declined
+	Capacity       int       `json:"capacity"`
+}
+
+// RsvpResult is the status the user ended up with, "waitlist" when the
+// event was full, and who moved up from the waitlist to take a freed spot.
+type RsvpResult struct {
+	Status   string
+	Promoted *models.Attendee
+}
+
+// Rsvp records a user's answer to an event, keeping going attendees within
+// its capacity.
+func (r *AttendeesRepo) Rsvp(ctx context.Context, data RsvpDto) (*RsvpResult, error) {
+	result := &RsvpResult{Status: data.Status}
+	err := r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// answers to the same event take turns on PostgreSQL, so two of
+		// them can't both take the last spot. SQLite lets one transaction
+		// write at a time, a concurrent answer fails there instead. A
+		// deleted event takes no answers, its attendees wait for an undo.
+		query := tx.Select("id")
+		if r.dbm.Dialect() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		if err := query.First(&models.Notification{}, "id = ?", data.NotificationId).Error; err != nil {
+			return err
+		}
+
+		var attendee models.Attendee
+		err := tx.First(&attendee, "notification_id = ? AND user_id = ?", data.NotificationId, data.UserId).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		wasGoing := attendee.Status == "going"
+
+		if data.Status == "going" && !wasGoing && data.Capacity > 0 {
+			var going int64
+			err := tx.Model(&models.Attendee{}).
+				Where("notification_id = ? AND status = ?", data.NotificationId, "going").
+				Count(&going).Error
+			if err != nil {
+				return err
+			}
+			if going >= int64(data.Capacity) {
+				result.Status = "waitlist"
+			}
+		}
+
+		// answering going again keeps a waiting user's place in line
+		waitlistedAt := attendee.WaitlistedAt
+		if result.Status != "waitlist" {
+			waitlistedAt = nil
+		} else if attendee.Status != "waitlist" {
+			now := utils.JapanTimeNow()
+			waitlistedAt = &now
+		}
+
+		if attendee.ID == uuid.Nil {
+			attendee = models.Attendee{NotificationId: data.NotificationId, UserId: data.UserId, Status: result.Status, WaitlistedAt: waitlistedAt}
+			if err := tx.Create(&attendee).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&attendee).Updates(map[string]any{"status": result.Status, "waitlisted_at": waitlistedAt}).Error; err != nil {
+			return err
+		}
+
+		if !wasGoing || result.Status == "going" {
+			return nil
+		}
+		// a spot opened up, the longest waiting attendee takes it
+		var next models.Attendee
+		err = tx.Where("notification_id = ? AND status = ?", data.NotificationId, "waitlist").
+			Order("waitlisted_at ASC").
+			First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&next).Updates(map[string]any{"status": "going", "waitlisted_at": nil}).Error; err != nil {
+			return err
+		}
+		result.Promoted = &next
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"biyobot/models"
+	"biyobot/utils"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Actor is who made a change and the Discord message it came from, it is
+// kept in the audit log.
+type Actor struct {
+	UserId    string `json:"user_id"`
+	GuildId   string `json:"guild_id"` // empty for DMs
+	ChannelId string `json:"channel_id"`
+	MessageId string `json:"message_id"`
+}
+
+// SystemActor is the bot itself, for changes no user asked for like
+// removing delivered notifications.
+var SystemActor = Actor{UserId: "system"}
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrCannotUndo is returned when the row changed since, e.g. a
+	// notification that was delivered or purged.
+	ErrCannotUndo = errors.New("that can no longer be undone")
+)
+
+// UndoResult is the action that was undone and the notification as it is
+// after the undo.
+type UndoResult struct {
+	Action       string // create | edit | delete
+	Notification *models.Notification
+}
+
+// auditNotification records a change to a notification in tx, before is nil
+// for creates and after for deletes.
+func auditNotification(tx *gorm.DB, actor Actor, action string, before, after *models.Notification) error {
+	entry := models.AuditLog{
+		Entity:    "notification",
+		Action:    action,
+		GuildId:   actor.GuildId,
+		UserId:    actor.UserId,
+		ChannelId: actor.ChannelId,
+		MessageId: actor.MessageId,
+	}
+	var err error
+	if before != nil {
+		entry.EntityId, entry.Service = before.ID.String(), before.Service
+		if entry.Before, err = utils.StructToJson(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		entry.EntityId, entry.Service = after.ID.String(), after.Service
+		if entry.After, err = utils.StructToJson(after); err != nil {
+			return err
+		}
+	}
+	return tx.Create(&entry).Error
+}
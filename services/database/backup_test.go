@@ -0,0 +1,53 @@
+package database_test
+
+import (
+	"biyobot/configs"
+	"biyobot/services/database"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "app.db")
+	dbm := openMigrated(t, configs.DatabaseConfig{Driver: "sqlite", Path: dbPath})
+	repos := database.NewRepos(dbm)
+	if _, err := repos.Guilds.EnsureGuild(ctx, database.UpsertGuildDto{GuildId: "g1", Name: "before"}); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := dbm.Backup(filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Guilds.EnsureGuild(ctx, database.UpsertGuildDto{GuildId: "g2", Name: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	// restores happen with the bot stopped
+	dbm.Close()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.RestoreBackup(dbPath, garbage); err == nil {
+		t.Error("restored a file that is not a database")
+	}
+
+	previous, err := database.RestoreBackup(dbPath, backup)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := os.Stat(previous); err != nil {
+		t.Errorf("replaced database not kept: %v", err)
+	}
+	restored := database.NewRepos(openMigrated(t, configs.DatabaseConfig{Driver: "sqlite", Path: dbPath}))
+	if guild, err := restored.Guilds.GetGuild(ctx, "g2"); err == nil && guild != nil {
+		t.Error("guild added after the backup survived the restore")
+	}
+	if _, err := restored.Guilds.GetGuild(ctx, "g1"); err != nil {
+		t.Errorf("guild from the backup is gone: %v", err)
+	}
+}
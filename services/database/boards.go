@@ -0,0 +1,79 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BoardsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewBoardsRepo(dbm *DatabaseManager) *BoardsRepo {
+	return &BoardsRepo{dbm: dbm}
+}
+
+// GetBoard returns nil without error when the channel has no board yet.
+func (r *BoardsRepo) GetBoard(ctx context.Context, channelId, service string) (*models.Board, error) {
+	var board models.Board
+	err := r.dbm.App().WithContext(ctx).First(&board, "channel_id = ? AND service = ?", channelId, service).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+func (r *BoardsRepo) GetBoardById(ctx context.Context, id uuid.UUID) (*models.Board, error) {
+	var board models.Board
+	if err := r.dbm.App().WithContext(ctx).First(&board, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+type SaveBoardDto struct {
+	GuildId   string `json:"guild_id"`
+	ChannelId string `json:"channel_id"`
+	Service   string `json:"service"`
+	MessageId string `json:"message_id"`
+}
+
+// SaveBoard records the message a channel's board lives in, keeping the
+// view of an existing board.
+func (r *BoardsRepo) SaveBoard(ctx context.Context, data SaveBoardDto) (*models.Board, error) {
+	board := &models.Board{
+		GuildId:   data.GuildId,
+		ChannelId: data.ChannelId,
+		Service:   data.Service,
+		MessageId: data.MessageId,
+		View:      "all",
+	}
+	err := r.dbm.App().WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "channel_id"}, {Name: "service"}},
+			DoUpdates: clause.AssignmentColumns([]string{"message_id", "updated_at"}),
+		}).
+		Create(board).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetBoard(ctx, data.ChannelId, data.Service)
+}
+
+func (r *BoardsRepo) SetBoardView(ctx context.Context, id uuid.UUID, view string, page int) error {
+	return r.dbm.App().WithContext(ctx).Model(&models.Board{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"view": view, "page": page}).Error
+}
+
+func (r *BoardsRepo) DeleteBoard(ctx context.Context, id uuid.UUID) error {
+	return r.dbm.App().WithContext(ctx).Where("id = ?", id).Delete(&models.Board{}).Error
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BudgetsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewBudgetsRepo(dbm *DatabaseManager) *BudgetsRepo {
+	return &BudgetsRepo{dbm: dbm}
+}
+
+// GetBudget returns nil without error when the user has no budget for category.
+// Budgets belong to exactly one guild, or to DMs when guildId is empty.
+func (r *BudgetsRepo) GetBudget(ctx context.Context, guildId, userId, category string) (*models.Budget, error) {
+	var budget models.Budget
+	err := r.dbm.App().WithContext(ctx).First(&budget, "guild_id = ? AND user_id = ? AND category = ?", guildId, userId, category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *BudgetsRepo) GetBudgets(ctx context.Context, guildId, userId string) ([]models.Budget, error) {
+	var budgets []models.Budget
+	err := r.dbm.App().WithContext(ctx).
+		Where("guild_id = ? AND user_id = ?", guildId, userId).
+		Order("category ASC").
+		Find(&budgets).Error
+	return budgets, err
+}
+
+type SetBudgetDto struct {
+	GuildId  string `json:"guild_id"`
+	UserId   string `json:"user_id"`
+	Category string `json:"category"`
+	LimitRaw int64  `json:"limit_raw"`
+	Currency string `json:"currency"`
+	Metadata string `json:"metadata"`
+}
+
+// SetBudget creates or replaces the monthly budget of a category and resets
+// its alert state so the new limit is evaluated from scratch.
+func (r *BudgetsRepo) SetBudget(ctx context.Context, data SetBudgetDto) (*models.Budget, error) {
+	existing, err := r.GetBudget(ctx, data.GuildId, data.UserId, data.Category)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		budget := &models.Budget{
+			GuildId:  data.GuildId,
+			UserId:   data.UserId,
+			Category: data.Category,
+			LimitRaw: data.LimitRaw,
+			Currency: data.Currency,
+			Metadata: data.Metadata,
+		}
+		err := r.dbm.App().WithContext(ctx).Create(budget).Error
+		return budget, err
+	}
+
+	err = r.dbm.App().WithContext(ctx).Model(existing).Updates(map[string]any{
+		"limit_raw":       data.LimitRaw,
+		"currency":        data.Currency,
+		"metadata":        data.Metadata,
+		"alerted_month":   "",
+		"alerted_percent": 0,
+	}).Error
+	return existing, err
+}
+
+func (r *BudgetsRepo) MarkAlerted(ctx context.Context, budgetId uuid.UUID, month string, percent int) error {
+	return r.dbm.App().WithContext(ctx).Model(&models.Budget{}).
+		Where("id = ?", budgetId).
+		Updates(map[string]any{
+			"alerted_month":   month,
+			"alerted_percent": percent,
+		}).Error
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+
+	"gorm.io/gorm/clause"
+)
+
+type ChannelBindingsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewChannelBindingsRepo(dbm *DatabaseManager) *ChannelBindingsRepo {
+	return &ChannelBindingsRepo{dbm: dbm}
+}
+
+// GetChannelServices returns the names of the services bound to a channel.
+func (r *ChannelBindingsRepo) GetChannelServices(ctx context.Context, channelId string) ([]string, error) {
+	var services []string
+	err := r.dbm.App().WithContext(ctx).Model(&models.ChannelBinding{}).
+		Where("channel_id = ?", channelId).
+		Order("service ASC").
+		Pluck("service", &services).Error
+	return services, err
+}
+
+// GetServiceBindings returns every channel binding of a service, across guilds.
+func (r *ChannelBindingsRepo) GetServiceBindings(ctx context.Context, service string) ([]models.ChannelBinding, error) {
+	var bindings []models.ChannelBinding
+	err := r.dbm.App().WithContext(ctx).
+		Where("service = ?", service).
+		Order("guild_id ASC, channel_id ASC").
+		Find(&bindings).Error
+	return bindings, err
+}
+
+func (r *ChannelBindingsRepo) GetGuildBindings(ctx context.Context, guildId string) ([]models.ChannelBinding, error) {
+	var bindings []models.ChannelBinding
+	err := r.dbm.App().WithContext(ctx).
+		Where("guild_id = ?", guildId).
+		Order("channel_id ASC, service ASC").
+		Find(&bindings).Error
+	return bindings, err
+}
+
+type BindChannelDto struct {
+	GuildId   string `json:"guild_id"`
+	ChannelId string `json:"channel_id"`
+	Service   string `json:"service"`
+	CreatedBy string `json:"created_by"`
+}
+
+// BindChannel is idempotent, binding an already bound service is a no-op.
+func (r *ChannelBindingsRepo) BindChannel(ctx context.Context, data BindChannelDto) error {
+	binding := &models.ChannelBinding{
+		GuildId:   data.GuildId,
+		ChannelId: data.ChannelId,
+		Service:   data.Service,
+		CreatedBy: data.CreatedBy,
+	}
+	return r.dbm.App().WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(binding).Error
+}
+
+// UnbindChannel removes the given services from a guild's channel, or every
+// binding of the channel when services is empty. It returns how many were
+// removed.
+func (r *ChannelBindingsRepo) UnbindChannel(ctx context.Context, guildId, channelId string, services []string) (int64, error) {
+	query := r.dbm.App().WithContext(ctx).Where("guild_id = ? AND channel_id = ?", guildId, channelId)
+	if len(services) > 0 {
+		query = query.Where("service IN ?", services)
+	}
+	result := query.Delete(&models.ChannelBinding{})
+	return result.RowsAffected, result.Error
+}
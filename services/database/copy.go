@@ -0,0 +1,85 @@
+package database
+
+import (
+	"biyobot/models"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const copyBatchSize = 500
+
+// copyTables copies a table each, in an order that keeps foreign keys
+// satisfied.
+var copyTables = []struct {
+	Name string
+	Copy func(src, dst *gorm.DB) (int64, error)
+}{
+	{"guilds", copyTable[models.Guild]},
+	{"notifications", copyTable[models.Notification]},
+	{"discord_messages", copyTable[models.DiscordMessage]},
+	{"expenses", copyTable[models.Expense]},
+	{"expense_items", copyTable[models.ExpenseItem]},
+	{"budgets", copyTable[models.Budget]},
+	{"channel_bindings", copyTable[models.ChannelBinding]},
+	{"permissions", copyTable[models.Permission]},
+	{"boards", copyTable[models.Board]},
+	{"attendees", copyTable[models.Attendee]},
+	{"polls", copyTable[models.Poll]},
+	{"poll_votes", copyTable[models.PollVote]},
+	{"audit_logs", copyTable[models.AuditLog]},
+	{"task_runs", copyTable[models.TaskRun]},
+}
+
+// CopyResult is how many rows of a table were copied.
+type CopyResult struct {
+	Table string
+	Rows  int64
+}
+
+// CopyDatabase copies every row of src into dst, e.g. moving a SQLite
+// database to PostgreSQL. Both must be migrated to the same schema and dst
+// must be empty, the copy is one transaction so a failure leaves it so.
+func CopyDatabase(src, dst *DatabaseManager) ([]CopyResult, error) {
+	for _, table := range copyTables {
+		var count int64
+		if err := dst.App().Table(table.Name).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", table.Name, err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("destination table %s is not empty", table.Name)
+		}
+	}
+
+	var results []CopyResult
+	err := dst.App().Transaction(func(tx *gorm.DB) error {
+		for _, table := range copyTables {
+			rows, err := table.Copy(src.App(), tx)
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", table.Name, err)
+			}
+			results = append(results, CopyResult{Table: table.Name, Rows: rows})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func copyTable[T any](src, dst *gorm.DB) (int64, error) {
+	var batch []T
+	var copied int64
+	// soft deleted rows are copied too, they can still be undone
+	result := src.Unscoped().FindInBatches(&batch, copyBatchSize, func(_ *gorm.DB, _ int) error {
+		// rows are copied as they are, associations included separately
+		if err := dst.Omit(clause.Associations).Create(&batch).Error; err != nil {
+			return err
+		}
+		copied += int64(len(batch))
+		return nil
+	})
+	return copied, result.Error
+}
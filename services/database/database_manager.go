@@ -1,33 +1,217 @@
 package database
 
 import (
-	"log"
+	"biyobot/configs"
+	"biyobot/utils"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 type DatabaseManager struct {
-	appDB  *gorm.DB
-	dbsDir string
+	appDB   *gorm.DB
+	dialect string
+	dbPath  string
+	dbsDir  string
 }
 
-func NewDatabaseManager() *DatabaseManager {
-	db, err := gorm.Open(sqlite.Open("dbs/app.db"), &gorm.Config{})
+func NewDatabaseManager(conf configs.DatabaseConfig) (*DatabaseManager, error) {
+	dir := filepath.Dir(conf.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create db directory: %w", err)
+	}
+
+	var dialector gorm.Dialector
+	switch conf.Driver {
+	case "postgres":
+		dialector = postgres.Open(conf.URL)
+	default:
+		dialector = sqlite.Open(conf.Path)
+	}
+	// gorm's own timestamps, deleted_at included, are in JST like the
+	// rest, SQLite compares them as text
+	db, err := gorm.Open(dialector, &gorm.Config{NowFunc: utils.JapanTimeNow})
 	if err != nil {
-		log.Fatal("Failed to load db: ", err)
+		return nil, fmt.Errorf("failed to load db: %w", err)
+	}
+	if conf.Driver != "postgres" {
+		db.Exec("PRAGMA journal_mode=WAL")
+		db.Exec("PRAGMA synchronous=NORMAL")
+		db.Exec("PRAGMA foreign_keys=ON")
+		db.Exec("PRAGMA busy_timeout=5000")
 	}
-	db.Exec("PRAGMA journal_mode=WAL")
-	db.Exec("PRAGMA synchronous=NORMAL")
-	db.Exec("PRAGMA foreign_keys=ON")
-	db.Exec("PRAGMA busy_timeout=5000")
 
 	return &DatabaseManager{
-		appDB:  db,
-		dbsDir: "./dbs",
-	}
+		appDB:   db,
+		dialect: db.Dialector.Name(),
+		dbPath:  conf.Path,
+		dbsDir:  dir,
+	}, nil
 }
 
 func (dm *DatabaseManager) App() *gorm.DB {
 	return dm.appDB
 }
+
+// Dialect is the database in use, "sqlite" or "postgres".
+func (dm *DatabaseManager) Dialect() string {
+	return dm.dialect
+}
+
+// Close closes the database connections. SQLite's write-ahead log is folded
+// into the database file first, so the file on its own is complete.
+func (dm *DatabaseManager) Close() error {
+	if dm.dialect == "sqlite" {
+		if err := dm.appDB.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+			slog.Warn("failed to checkpoint database", "path", dm.dbPath, "err", err)
+		}
+	}
+	sqlDB, err := dm.appDB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Ping checks the database answers.
+func (dm *DatabaseManager) Ping(ctx context.Context) error {
+	sqlDB, err := dm.appDB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Dir is the directory holding the databases and other local state.
+func (dm *DatabaseManager) Dir() string {
+	return dm.dbsDir
+}
+
+// Backup writes a consistent copy of the database into dir while it stays in
+// use, and returns the path of the copy.
+func (dm *DatabaseManager) Backup(dir string) (string, error) {
+	if dm.dialect != "sqlite" {
+		return "", fmt.Errorf("%s databases are backed up with their own tools, e.g. pg_dump", dm.dialect)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.db", backupPrefix(dm.dbPath), time.Now().Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	if err := dm.appDB.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+	return path, nil
+}
+
+// PruneBackups deletes all but the keep newest backups in dir and returns
+// the deleted paths.
+func (dm *DatabaseManager) PruneBackups(dir string, keep int) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix(dm.dbPath)+"-*.db"))
+	if err != nil {
+		return nil, err
+	}
+	// the timestamps in the names sort by age
+	slices.Sort(backups)
+	var deleted []string
+	for _, path := range backups[:max(0, len(backups)-keep)] {
+		if err := os.Remove(path); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup: %w", err)
+		}
+		deleted = append(deleted, path)
+	}
+	return deleted, nil
+}
+
+func backupPrefix(dbPath string) string {
+	return strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+}
+
+// RestoreBackup replaces the database at dbPath with a backup. The bot must
+// not be running. The replaced database is kept next to it, its path is
+// returned.
+func RestoreBackup(dbPath, backupPath string) (string, error) {
+	if err := checkIntegrity(backupPath); err != nil {
+		return "", fmt.Errorf("backup %s is unusable: %w", backupPath, err)
+	}
+
+	var previous string
+	if _, err := os.Stat(dbPath); err == nil {
+		// fold the write-ahead log in, so the kept copy is complete
+		if err := checkpoint(dbPath); err != nil {
+			slog.Warn("failed to checkpoint, recent writes may be missing from the kept copy", "path", dbPath, "err", err)
+		}
+		previous = fmt.Sprintf("%s.before-restore-%s", dbPath, time.Now().Format("20060102-150405"))
+		if err := os.Rename(dbPath, previous); err != nil {
+			return "", fmt.Errorf("failed to move current database aside: %w", err)
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return previous, fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := copyFile(backupPath, dbPath); err != nil {
+		return previous, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	return previous, nil
+}
+
+func checkIntegrity(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	return nil
+}
+
+func checkpoint(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	return db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
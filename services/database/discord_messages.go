@@ -3,9 +3,11 @@ package database
 import (
 	"biyobot/models"
 	"biyobot/utils"
+	"context"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type DiscordMessageRepo struct {
@@ -16,10 +18,32 @@ func NewDiscordMessageRepo(dbm *DatabaseManager) *DiscordMessageRepo {
 	return &DiscordMessageRepo{dbm: dbm}
 }
 
-func (r *DiscordMessageRepo) GetAllExpiredMessages() ([]models.DiscordMessage, error) {
+func (r *DiscordMessageRepo) GetAllExpiredMessages(ctx context.Context) ([]models.DiscordMessage, error) {
 	var messages []models.DiscordMessage
-	err := r.dbm.App().
+	err := r.dbm.App().WithContext(ctx).
 		Where("execute_action_on <= ? AND action = ?", utils.JapanTimeNow(), "delete").
+		Order("channel_id ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+// CountQueuedMessages counts the messages waiting to be deleted.
+func (r *DiscordMessageRepo) CountQueuedMessages(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.dbm.App().WithContext(ctx).Model(&models.DiscordMessage{}).
+		Where("action = ?", "delete").
+		Count(&count).Error
+	return count, err
+}
+
+// GetDueActions returns the scheduled actions other than deletions that are
+// due, oldest first. Deletions are batched separately, see
+// GetAllExpiredMessages.
+func (r *DiscordMessageRepo) GetDueActions(ctx context.Context) ([]models.DiscordMessage, error) {
+	var messages []models.DiscordMessage
+	err := r.dbm.App().WithContext(ctx).
+		Where("execute_action_on <= ? AND action <> ?", utils.JapanTimeNow(), "delete").
+		Order("execute_action_on ASC").
 		Find(&messages).Error
 	return messages, err
 }
@@ -31,9 +55,12 @@ type AddDiscordMessageDto struct {
 	MessageId       string
 	Content         string
 	ExecuteActionOn time.Time
+	Kind            string
+	Payload         string
+	RefId           string
 }
 
-func (r *DiscordMessageRepo) AddMessage(data AddDiscordMessageDto) (*models.DiscordMessage, error) {
+func (r *DiscordMessageRepo) AddMessage(ctx context.Context, data AddDiscordMessageDto) (*models.DiscordMessage, error) {
 	message := &models.DiscordMessage{
 		Action:          data.Action,
 		ChannelId:       data.ChannelId,
@@ -41,13 +68,35 @@ func (r *DiscordMessageRepo) AddMessage(data AddDiscordMessageDto) (*models.Disc
 		MessageId:       data.MessageId,
 		Content:         data.Content,
 		ExecuteActionOn: data.ExecuteActionOn,
+		Kind:            data.Kind,
+		Payload:         data.Payload,
+		RefId:           data.RefId,
 	}
-	err := r.dbm.App().Create(message).Error
+	err := r.dbm.App().WithContext(ctx).Create(message).Error
 	return message, err
 }
 
-func (r *DiscordMessageRepo) DeleteMessageBatch(ids []uuid.UUID) error {
-	return r.dbm.App().
+func (r *DiscordMessageRepo) DeleteMessageBatch(ctx context.Context, ids []uuid.UUID) error {
+	return r.dbm.App().WithContext(ctx).
 		Where("id IN ?", ids).
 		Delete(&models.DiscordMessage{}).Error
 }
+
+// RetryMessageBatch counts a failed attempt for each message and moves its
+// action to retryAt.
+func (r *DiscordMessageRepo) RetryMessageBatch(ctx context.Context, ids []uuid.UUID, retryAt time.Time) error {
+	return r.dbm.App().WithContext(ctx).Model(&models.DiscordMessage{}).
+		Where("id IN ?", ids).
+		Updates(map[string]any{
+			"attempts":          gorm.Expr("attempts + 1"),
+			"execute_action_on": retryAt,
+		}).Error
+}
+
+// CancelActions drops the pending actions scheduled for refId, deletions of
+// messages already sent are kept.
+func (r *DiscordMessageRepo) CancelActions(ctx context.Context, refId string) error {
+	return r.dbm.App().WithContext(ctx).
+		Where("ref_id = ? AND action <> ?", refId, "delete").
+		Delete(&models.DiscordMessage{}).Error
+}
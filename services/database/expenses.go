@@ -0,0 +1,138 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExpensesRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewExpensesRepo(dbm *DatabaseManager) *ExpensesRepo {
+	return &ExpensesRepo{dbm: dbm}
+}
+
+func (r *ExpensesRepo) GetExpense(ctx context.Context, expenseId uuid.UUID) (*models.Expense, error) {
+	var expense models.Expense
+	err := r.dbm.App().WithContext(ctx).Preload("Items").First(&expense, "id = ?", expenseId).Error
+	if err != nil {
+		return nil, err
+	}
+	return &expense, nil
+}
+
+// GetRecentExpenses returns a user's latest expenses, newest purchase first.
+func (r *ExpensesRepo) GetRecentExpenses(ctx context.Context, guildId, userId string, limit int) ([]models.Expense, error) {
+	var expenses []models.Expense
+	err := r.dbm.App().WithContext(ctx).
+		Scopes(scopeGuild(guildId)).
+		Where("user_id = ?", userId).
+		Order("purchased_at DESC").
+		Limit(limit).
+		Find(&expenses).Error
+	return expenses, err
+}
+
+type AddExpenseItemDto struct {
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	AmountRaw int64  `json:"amount_raw"`
+}
+
+type AddExpenseDto struct {
+	GuildId     string              `json:"guild_id"`
+	UserId      string              `json:"user_id"`
+	Metadata    string              `json:"metadata"`
+	Merchant    string              `json:"merchant"`
+	Category    string              `json:"category"`
+	PurchasedAt time.Time           `json:"purchased_at"`
+	TotalRaw    int64               `json:"total_raw"`
+	Currency    string              `json:"currency"`
+	OcrText     string              `json:"ocr_text"`
+	Items       []AddExpenseItemDto `json:"items"`
+}
+
+func (r *ExpensesRepo) AddExpense(ctx context.Context, data AddExpenseDto) (*models.Expense, error) {
+	expense := &models.Expense{
+		GuildId:     data.GuildId,
+		UserId:      data.UserId,
+		Metadata:    data.Metadata,
+		Merchant:    data.Merchant,
+		Category:    data.Category,
+		PurchasedAt: data.PurchasedAt,
+		TotalRaw:    data.TotalRaw,
+		Currency:    data.Currency,
+		OcrText:     data.OcrText,
+	}
+	for _, item := range data.Items {
+		expense.Items = append(expense.Items, models.ExpenseItem{
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			AmountRaw: item.AmountRaw,
+		})
+	}
+	err := r.dbm.App().WithContext(ctx).Create(expense).Error
+	return expense, err
+}
+
+type EditExpenseDto struct {
+	ID          uuid.UUID `json:"id"`
+	Merchant    string    `json:"merchant"`
+	Category    string    `json:"category"`
+	PurchasedAt time.Time `json:"purchased_at"`
+	TotalRaw    int64     `json:"total_raw"`
+	Currency    string    `json:"currency"`
+}
+
+// EditExpense overwrites the header fields of an expense. Line items are left
+// untouched since corrections from chat only ever target the summary.
+func (r *ExpensesRepo) EditExpense(ctx context.Context, data EditExpenseDto) (*models.Expense, error) {
+	var expense models.Expense
+	if err := r.dbm.App().WithContext(ctx).First(&expense, "id = ?", data.ID).Error; err != nil {
+		return nil, err
+	}
+
+	err := r.dbm.App().WithContext(ctx).Model(&expense).Updates(map[string]any{
+		"merchant":     data.Merchant,
+		"category":     data.Category,
+		"purchased_at": data.PurchasedAt,
+		"total_raw":    data.TotalRaw,
+		"currency":     data.Currency,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetExpense(ctx, data.ID)
+}
+
+func (r *ExpensesRepo) DeleteExpense(ctx context.Context, expenseId uuid.UUID) error {
+	return r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expense_id = ?", expenseId).Delete(&models.ExpenseItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Expense{}, "id = ?", expenseId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// GetExpensesBetween returns a user's expenses purchased in [from, to), oldest first.
+func (r *ExpensesRepo) GetExpensesBetween(ctx context.Context, guildId, userId string, from, to time.Time) ([]models.Expense, error) {
+	var expenses []models.Expense
+	err := r.dbm.App().WithContext(ctx).
+		Scopes(scopeGuild(guildId)).
+		Where("user_id = ? AND purchased_at >= ? AND purchased_at < ?", userId, from, to).
+		Order("purchased_at ASC").
+		Find(&expenses).Error
+	return expenses, err
+}
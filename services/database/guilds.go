@@ -0,0 +1,136 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GuildsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewGuildsRepo(dbm *DatabaseManager) *GuildsRepo {
+	return &GuildsRepo{dbm: dbm}
+}
+
+// GetGuild returns nil without error for guilds the bot has never seen.
+func (r *GuildsRepo) GetGuild(ctx context.Context, guildId string) (*models.Guild, error) {
+	var guild models.Guild
+	err := r.dbm.App().WithContext(ctx).First(&guild, "guild_id = ?", guildId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &guild, nil
+}
+
+func (r *GuildsRepo) GetAllGuilds(ctx context.Context) ([]models.Guild, error) {
+	var guilds []models.Guild
+	err := r.dbm.App().WithContext(ctx).Order("allowed DESC, name ASC").Find(&guilds).Error
+	return guilds, err
+}
+
+type UpsertGuildDto struct {
+	GuildId string `json:"guild_id"`
+	Name    string `json:"name"`
+	AddedBy string `json:"added_by"`
+}
+
+// EnsureGuild records a guild, refreshing its name if it is already known
+// and a name is given.
+// New guilds start out not allowed.
+func (r *GuildsRepo) EnsureGuild(ctx context.Context, data UpsertGuildDto) (*models.Guild, error) {
+	guild := &models.Guild{
+		GuildId: data.GuildId,
+		Name:    data.Name,
+		AddedBy: data.AddedBy,
+	}
+	onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "guild_id"}}, DoNothing: true}
+	if data.Name != "" {
+		onConflict = clause.OnConflict{
+			Columns:   []clause.Column{{Name: "guild_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"name": data.Name}),
+		}
+	}
+	err := r.dbm.App().WithContext(ctx).Clauses(onConflict).Create(guild).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetGuild(ctx, data.GuildId)
+}
+
+func (r *GuildsRepo) SetAllowed(ctx context.Context, guildId string, allowed bool) error {
+	result := r.dbm.App().WithContext(ctx).Model(&models.Guild{}).
+		Where("guild_id = ?", guildId).
+		Update("allowed", allowed)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+type GuildSettingsDto struct {
+	TimeZone        *string `json:"time_zone,omitempty"`
+	Language        *string `json:"language,omitempty"`
+	EnabledServices *string `json:"enabled_services,omitempty"`
+	AdminRoleId     *string `json:"admin_role_id,omitempty"`
+}
+
+// UpdateSettings only writes the settings that are set in data.
+func (r *GuildsRepo) UpdateSettings(ctx context.Context, guildId string, data GuildSettingsDto) error {
+	updates := make(map[string]any)
+	if data.TimeZone != nil {
+		updates["time_zone"] = *data.TimeZone
+	}
+	if data.Language != nil {
+		updates["language"] = *data.Language
+	}
+	if data.EnabledServices != nil {
+		updates["enabled_services"] = *data.EnabledServices
+	}
+	if data.AdminRoleId != nil {
+		updates["admin_role_id"] = *data.AdminRoleId
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return r.dbm.App().WithContext(ctx).Model(&models.Guild{}).
+		Where("guild_id = ?", guildId).
+		Updates(updates).Error
+}
+
+// AdoptLegacyRows assigns rows created before guild scoping existed to
+// guildId, the only guild the bot could be in back then.
+func (r *GuildsRepo) AdoptLegacyRows(ctx context.Context, guildId string) error {
+	return r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{&models.Notification{}, &models.Expense{}, &models.Budget{}} {
+			err := tx.Model(model).
+				Where("guild_id IS NULL").
+				Update("guild_id", guildId).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scopeGuild limits a query to the rows of one guild. An empty guildId is
+// the DM context, where users see their own rows from every guild.
+func scopeGuild(guildId string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if guildId == "" {
+			return db
+		}
+		return db.Where("guild_id = ?", guildId)
+	}
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AttendeesRepo struct {
+	s *Store
+}
+
+// GetAttendees returns an event's RSVPs in the order they were first made.
+// The waitlist goes by WaitlistedAt instead, see Rsvp.
+func (r *AttendeesRepo) GetAttendees(ctx context.Context, notificationId uuid.UUID) ([]models.Attendee, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	return r.of(notificationId), nil
+}
+
+// CountGoing returns how many attendees are going to each of the events.
+func (r *AttendeesRepo) CountGoing(ctx context.Context, notificationIds []uuid.UUID) (map[uuid.UUID]int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	counts := make(map[uuid.UUID]int)
+	for _, attendee := range r.s.attendees {
+		if !attendee.DeletedAt.Valid && attendee.Status == "going" && slices.Contains(notificationIds, attendee.NotificationId) {
+			counts[attendee.NotificationId]++
+		}
+	}
+	return counts, nil
+}
+
+// Rsvp records a user's answer to an event, keeping going attendees within
+// its capacity.
+func (r *AttendeesRepo) Rsvp(ctx context.Context, data database.RsvpDto) (*database.RsvpResult, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	if first(r.s.notifications, func(n *models.Notification) bool { return n.ID == data.NotificationId && !n.DeletedAt.Valid }) == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	result := &database.RsvpResult{Status: data.Status}
+	attendee := first(r.s.attendees, func(a *models.Attendee) bool {
+		return !a.DeletedAt.Valid && a.NotificationId == data.NotificationId && a.UserId == data.UserId
+	})
+	wasGoing := attendee != nil && attendee.Status == "going"
+
+	if data.Status == "going" && !wasGoing && data.Capacity > 0 {
+		going := 0
+		for _, a := range r.of(data.NotificationId) {
+			if a.Status == "going" {
+				going++
+			}
+		}
+		if going >= data.Capacity {
+			result.Status = "waitlist"
+		}
+	}
+
+	// answering going again keeps a waiting user's place in line
+	var waitlistedAt *time.Time
+	if attendee != nil {
+		waitlistedAt = attendee.WaitlistedAt
+	}
+	if result.Status != "waitlist" {
+		waitlistedAt = nil
+	} else if attendee == nil || attendee.Status != "waitlist" {
+		now := time.Now()
+		waitlistedAt = &now
+	}
+
+	if attendee == nil {
+		r.s.attendees = append(r.s.attendees, models.Attendee{
+			SoftDeleteModel: newSoftDeleteBase(),
+			NotificationId:  data.NotificationId,
+			UserId:          data.UserId,
+			Status:          result.Status,
+			WaitlistedAt:    waitlistedAt,
+		})
+	} else {
+		attendee.Status = result.Status
+		attendee.WaitlistedAt = waitlistedAt
+		attendee.UpdatedAt = time.Now()
+	}
+
+	if !wasGoing || result.Status == "going" {
+		return result, nil
+	}
+	// a spot opened up, the longest waiting attendee takes it
+	var next *models.Attendee
+	for i := range r.s.attendees {
+		a := &r.s.attendees[i]
+		if a.DeletedAt.Valid || a.NotificationId != data.NotificationId || a.Status != "waitlist" {
+			continue
+		}
+		if next == nil || a.WaitlistedAt.Before(*next.WaitlistedAt) {
+			next = a
+		}
+	}
+	if next != nil {
+		next.Status = "going"
+		next.WaitlistedAt = nil
+		next.UpdatedAt = time.Now()
+		promoted := *next
+		result.Promoted = &promoted
+	}
+	return result, nil
+}
+
+// of returns an event's attendees, oldest first.
+func (r *AttendeesRepo) of(notificationId uuid.UUID) []models.Attendee {
+	attendees := filter(r.s.attendees, func(a *models.Attendee) bool {
+		return !a.DeletedAt.Valid && a.NotificationId == notificationId
+	})
+	slices.SortStableFunc(attendees, func(a, b models.Attendee) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return attendees
+}
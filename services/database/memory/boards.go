@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BoardsRepo struct {
+	s *Store
+}
+
+// GetBoard returns nil without error when the channel has no board yet.
+func (r *BoardsRepo) GetBoard(ctx context.Context, channelId, service string) (*models.Board, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	b := r.get(channelId, service)
+	if b == nil {
+		return nil, nil
+	}
+	board := *b
+	return &board, nil
+}
+
+func (r *BoardsRepo) GetBoardById(ctx context.Context, id uuid.UUID) (*models.Board, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	b := first(r.s.boards, func(b *models.Board) bool { return b.ID == id })
+	if b == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	board := *b
+	return &board, nil
+}
+
+// SaveBoard records the message a channel's board lives in, keeping the
+// view of an existing board.
+func (r *BoardsRepo) SaveBoard(ctx context.Context, data database.SaveBoardDto) (*models.Board, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	b := r.get(data.ChannelId, data.Service)
+	if b == nil {
+		r.s.boards = append(r.s.boards, models.Board{
+			BaseModel: newBase(),
+			GuildId:   data.GuildId,
+			ChannelId: data.ChannelId,
+			Service:   data.Service,
+			MessageId: data.MessageId,
+			View:      "all",
+		})
+		b = &r.s.boards[len(r.s.boards)-1]
+	} else {
+		b.MessageId = data.MessageId
+		b.UpdatedAt = time.Now()
+	}
+	board := *b
+	return &board, nil
+}
+
+func (r *BoardsRepo) SetBoardView(ctx context.Context, id uuid.UUID, view string, page int) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	if b := first(r.s.boards, func(b *models.Board) bool { return b.ID == id }); b != nil {
+		b.View, b.Page = view, page
+		b.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *BoardsRepo) DeleteBoard(ctx context.Context, id uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	r.s.boards = slices.DeleteFunc(r.s.boards, func(b models.Board) bool { return b.ID == id })
+	return nil
+}
+
+func (r *BoardsRepo) get(channelId, service string) *models.Board {
+	return first(r.s.boards, func(b *models.Board) bool {
+		return b.ChannelId == channelId && b.Service == service
+	})
+}
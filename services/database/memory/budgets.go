@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BudgetsRepo struct {
+	s *Store
+}
+
+// GetBudget returns nil without error when the user has no budget for category.
+func (r *BudgetsRepo) GetBudget(ctx context.Context, guildId, userId, category string) (*models.Budget, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	b := r.get(guildId, userId, category)
+	if b == nil {
+		return nil, nil
+	}
+	budget := *b
+	return &budget, nil
+}
+
+func (r *BudgetsRepo) GetBudgets(ctx context.Context, guildId, userId string) ([]models.Budget, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	budgets := filter(r.s.budgets, func(b *models.Budget) bool {
+		return b.GuildId == guildId && b.UserId == userId
+	})
+	slices.SortStableFunc(budgets, func(a, b models.Budget) int {
+		return strings.Compare(a.Category, b.Category)
+	})
+	return budgets, nil
+}
+
+// SetBudget creates or replaces the monthly budget of a category and resets
+// its alert state.
+func (r *BudgetsRepo) SetBudget(ctx context.Context, data database.SetBudgetDto) (*models.Budget, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	b := r.get(data.GuildId, data.UserId, data.Category)
+	if b == nil {
+		r.s.budgets = append(r.s.budgets, models.Budget{
+			BaseModel: newBase(),
+			GuildId:   data.GuildId,
+			UserId:    data.UserId,
+			Category:  data.Category,
+		})
+		b = &r.s.budgets[len(r.s.budgets)-1]
+	}
+	b.LimitRaw = data.LimitRaw
+	b.Currency = data.Currency
+	b.Metadata = data.Metadata
+	b.AlertedMonth, b.AlertedPercent = "", 0
+	b.UpdatedAt = time.Now()
+	budget := *b
+	return &budget, nil
+}
+
+func (r *BudgetsRepo) MarkAlerted(ctx context.Context, budgetId uuid.UUID, month string, percent int) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	if b := first(r.s.budgets, func(b *models.Budget) bool { return b.ID == budgetId }); b != nil {
+		b.AlertedMonth, b.AlertedPercent = month, percent
+		b.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *BudgetsRepo) get(guildId, userId, category string) *models.Budget {
+	return first(r.s.budgets, func(b *models.Budget) bool {
+		return b.GuildId == guildId && b.UserId == userId && b.Category == category
+	})
+}
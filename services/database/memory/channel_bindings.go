@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+)
+
+type ChannelBindingsRepo struct {
+	s *Store
+}
+
+// GetChannelServices returns the names of the services bound to a channel.
+func (r *ChannelBindingsRepo) GetChannelServices(ctx context.Context, channelId string) ([]string, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	var services []string
+	for _, binding := range r.s.bindings {
+		if binding.ChannelId == channelId {
+			services = append(services, binding.Service)
+		}
+	}
+	slices.Sort(services)
+	return services, nil
+}
+
+// GetServiceBindings returns every channel binding of a service, across guilds.
+func (r *ChannelBindingsRepo) GetServiceBindings(ctx context.Context, service string) ([]models.ChannelBinding, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	bindings := filter(r.s.bindings, func(b *models.ChannelBinding) bool { return b.Service == service })
+	slices.SortStableFunc(bindings, func(a, b models.ChannelBinding) int {
+		return cmp.Or(strings.Compare(a.GuildId, b.GuildId), strings.Compare(a.ChannelId, b.ChannelId))
+	})
+	return bindings, nil
+}
+
+func (r *ChannelBindingsRepo) GetGuildBindings(ctx context.Context, guildId string) ([]models.ChannelBinding, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	bindings := filter(r.s.bindings, func(b *models.ChannelBinding) bool { return b.GuildId == guildId })
+	slices.SortStableFunc(bindings, func(a, b models.ChannelBinding) int {
+		return cmp.Or(strings.Compare(a.ChannelId, b.ChannelId), strings.Compare(a.Service, b.Service))
+	})
+	return bindings, nil
+}
+
+// BindChannel is idempotent, binding an already bound service is a no-op.
+func (r *ChannelBindingsRepo) BindChannel(ctx context.Context, data database.BindChannelDto) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	bound := slices.ContainsFunc(r.s.bindings, func(b models.ChannelBinding) bool {
+		return b.ChannelId == data.ChannelId && b.Service == data.Service
+	})
+	if !bound {
+		r.s.bindings = append(r.s.bindings, models.ChannelBinding{
+			BaseModel: newBase(),
+			GuildId:   data.GuildId,
+			ChannelId: data.ChannelId,
+			Service:   data.Service,
+			CreatedBy: data.CreatedBy,
+		})
+	}
+	return nil
+}
+
+// UnbindChannel removes the given services from a guild's channel, or every
+// binding of the channel when services is empty. It returns how many were
+// removed.
+func (r *ChannelBindingsRepo) UnbindChannel(ctx context.Context, guildId, channelId string, services []string) (int64, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.s.unlock()
+	before := len(r.s.bindings)
+	r.s.bindings = slices.DeleteFunc(r.s.bindings, func(b models.ChannelBinding) bool {
+		return b.GuildId == guildId && b.ChannelId == channelId && (len(services) == 0 || slices.Contains(services, b.Service))
+	})
+	return int64(before - len(r.s.bindings)), nil
+}
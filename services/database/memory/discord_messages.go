@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DiscordMessageRepo struct {
+	s *Store
+}
+
+func (r *DiscordMessageRepo) GetAllExpiredMessages(ctx context.Context) ([]models.DiscordMessage, error) {
+	now := utils.JapanTimeNow()
+	messages, err := r.find(ctx, func(m *models.DiscordMessage) bool {
+		return !m.ExecuteActionOn.After(now) && m.Action == "delete"
+	})
+	slices.SortStableFunc(messages, func(a, b models.DiscordMessage) int {
+		return strings.Compare(a.ChannelId, b.ChannelId)
+	})
+	return messages, err
+}
+
+// CountQueuedMessages counts the messages waiting to be deleted.
+func (r *DiscordMessageRepo) CountQueuedMessages(ctx context.Context) (int64, error) {
+	messages, err := r.find(ctx, func(m *models.DiscordMessage) bool { return m.Action == "delete" })
+	return int64(len(messages)), err
+}
+
+// GetDueActions returns the scheduled actions other than deletions that are
+// due, oldest first.
+func (r *DiscordMessageRepo) GetDueActions(ctx context.Context) ([]models.DiscordMessage, error) {
+	now := utils.JapanTimeNow()
+	messages, err := r.find(ctx, func(m *models.DiscordMessage) bool {
+		return !m.ExecuteActionOn.After(now) && m.Action != "delete"
+	})
+	slices.SortStableFunc(messages, func(a, b models.DiscordMessage) int {
+		return a.ExecuteActionOn.Compare(b.ExecuteActionOn)
+	})
+	return messages, err
+}
+
+func (r *DiscordMessageRepo) AddMessage(ctx context.Context, data database.AddDiscordMessageDto) (*models.DiscordMessage, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	message := models.DiscordMessage{
+		BaseModel:       newBase(),
+		Action:          data.Action,
+		ChannelId:       data.ChannelId,
+		UserId:          data.UserId,
+		MessageId:       data.MessageId,
+		Content:         data.Content,
+		ExecuteActionOn: data.ExecuteActionOn,
+		Kind:            data.Kind,
+		Payload:         data.Payload,
+		RefId:           data.RefId,
+	}
+	r.s.discordMessages = append(r.s.discordMessages, message)
+	return &message, nil
+}
+
+func (r *DiscordMessageRepo) DeleteMessageBatch(ctx context.Context, ids []uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	r.s.discordMessages = slices.DeleteFunc(r.s.discordMessages, func(m models.DiscordMessage) bool {
+		return slices.Contains(ids, m.ID)
+	})
+	return nil
+}
+
+// RetryMessageBatch counts a failed attempt for each message and moves its
+// action to retryAt.
+func (r *DiscordMessageRepo) RetryMessageBatch(ctx context.Context, ids []uuid.UUID, retryAt time.Time) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	for i := range r.s.discordMessages {
+		m := &r.s.discordMessages[i]
+		if slices.Contains(ids, m.ID) {
+			m.Attempts++
+			m.ExecuteActionOn = retryAt
+			m.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+// CancelActions drops the pending actions scheduled for refId, deletions of
+// messages already sent are kept.
+func (r *DiscordMessageRepo) CancelActions(ctx context.Context, refId string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	r.s.discordMessages = slices.DeleteFunc(r.s.discordMessages, func(m models.DiscordMessage) bool {
+		return m.RefId == refId && m.Action != "delete"
+	})
+	return nil
+}
+
+func (r *DiscordMessageRepo) find(ctx context.Context, match func(*models.DiscordMessage) bool) ([]models.DiscordMessage, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	return filter(r.s.discordMessages, match), nil
+}
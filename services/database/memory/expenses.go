@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExpensesRepo struct {
+	s *Store
+}
+
+func (r *ExpensesRepo) GetExpense(ctx context.Context, expenseId uuid.UUID) (*models.Expense, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	e := r.get(expenseId)
+	if e == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	expense := *e
+	expense.Items = slices.Clone(e.Items)
+	return &expense, nil
+}
+
+// GetRecentExpenses returns a user's latest expenses, newest purchase first.
+func (r *ExpensesRepo) GetRecentExpenses(ctx context.Context, guildId, userId string, limit int) ([]models.Expense, error) {
+	expenses, err := r.find(ctx, func(e *models.Expense) bool {
+		return inGuild(e.GuildId, guildId) && e.UserId == userId
+	})
+	slices.SortStableFunc(expenses, func(a, b models.Expense) int {
+		return b.PurchasedAt.Compare(a.PurchasedAt)
+	})
+	if limit >= 0 && limit < len(expenses) {
+		expenses = expenses[:limit]
+	}
+	return expenses, err
+}
+
+func (r *ExpensesRepo) AddExpense(ctx context.Context, data database.AddExpenseDto) (*models.Expense, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	expense := models.Expense{
+		BaseModel:   newBase(),
+		GuildId:     data.GuildId,
+		UserId:      data.UserId,
+		Metadata:    data.Metadata,
+		Merchant:    data.Merchant,
+		Category:    data.Category,
+		PurchasedAt: data.PurchasedAt,
+		TotalRaw:    data.TotalRaw,
+		Currency:    data.Currency,
+		OcrText:     data.OcrText,
+	}
+	for _, item := range data.Items {
+		expense.Items = append(expense.Items, models.ExpenseItem{
+			BaseModel: newBase(),
+			ExpenseId: expense.ID,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			AmountRaw: item.AmountRaw,
+		})
+	}
+	r.s.expenses = append(r.s.expenses, expense)
+	expense.Items = slices.Clone(expense.Items)
+	return &expense, nil
+}
+
+// EditExpense overwrites the header fields of an expense, line items are
+// left untouched.
+func (r *ExpensesRepo) EditExpense(ctx context.Context, data database.EditExpenseDto) (*models.Expense, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	e := r.get(data.ID)
+	if e == nil {
+		r.s.unlock()
+		return nil, gorm.ErrRecordNotFound
+	}
+	e.Merchant = data.Merchant
+	e.Category = data.Category
+	e.PurchasedAt = data.PurchasedAt
+	e.TotalRaw = data.TotalRaw
+	e.Currency = data.Currency
+	e.UpdatedAt = time.Now()
+	r.s.unlock()
+	return r.GetExpense(ctx, data.ID)
+}
+
+func (r *ExpensesRepo) DeleteExpense(ctx context.Context, expenseId uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	if r.get(expenseId) == nil {
+		return gorm.ErrRecordNotFound
+	}
+	r.s.expenses = slices.DeleteFunc(r.s.expenses, func(e models.Expense) bool { return e.ID == expenseId })
+	return nil
+}
+
+// GetExpensesBetween returns a user's expenses purchased in [from, to), oldest first.
+func (r *ExpensesRepo) GetExpensesBetween(ctx context.Context, guildId, userId string, from, to time.Time) ([]models.Expense, error) {
+	expenses, err := r.find(ctx, func(e *models.Expense) bool {
+		return inGuild(e.GuildId, guildId) && e.UserId == userId &&
+			!e.PurchasedAt.Before(from) && e.PurchasedAt.Before(to)
+	})
+	slices.SortStableFunc(expenses, func(a, b models.Expense) int {
+		return a.PurchasedAt.Compare(b.PurchasedAt)
+	})
+	return expenses, err
+}
+
+// find leaves out the line items, like the gorm repo which only preloads
+// them for a single expense.
+func (r *ExpensesRepo) find(ctx context.Context, match func(*models.Expense) bool) ([]models.Expense, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	expenses := filter(r.s.expenses, match)
+	for i := range expenses {
+		expenses[i].Items = nil
+	}
+	return expenses, nil
+}
+
+func (r *ExpensesRepo) get(id uuid.UUID) *models.Expense {
+	return first(r.s.expenses, func(e *models.Expense) bool { return e.ID == id })
+}
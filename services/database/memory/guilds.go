@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GuildsRepo struct {
+	s *Store
+}
+
+// GetGuild returns nil without error for guilds the bot has never seen.
+func (r *GuildsRepo) GetGuild(ctx context.Context, guildId string) (*models.Guild, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	g := r.get(guildId)
+	if g == nil {
+		return nil, nil
+	}
+	guild := *g
+	return &guild, nil
+}
+
+func (r *GuildsRepo) GetAllGuilds(ctx context.Context) ([]models.Guild, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	guilds := slices.Clone(r.s.guilds)
+	slices.SortStableFunc(guilds, func(a, b models.Guild) int {
+		// allowed guilds first
+		if a.Allowed != b.Allowed {
+			if a.Allowed {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return guilds, nil
+}
+
+// EnsureGuild records a guild, refreshing its name if it is already known
+// and a name is given. New guilds start out not allowed.
+func (r *GuildsRepo) EnsureGuild(ctx context.Context, data database.UpsertGuildDto) (*models.Guild, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	g := r.get(data.GuildId)
+	if g == nil {
+		r.s.guilds = append(r.s.guilds, models.Guild{
+			BaseModel: newBase(),
+			GuildId:   data.GuildId,
+			Name:      data.Name,
+			TimeZone:  "Asia/Tokyo",
+			Language:  "en",
+			AddedBy:   data.AddedBy,
+		})
+		g = &r.s.guilds[len(r.s.guilds)-1]
+	} else if data.Name != "" {
+		g.Name = data.Name
+		g.UpdatedAt = time.Now()
+	}
+	guild := *g
+	return &guild, nil
+}
+
+func (r *GuildsRepo) SetAllowed(ctx context.Context, guildId string, allowed bool) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	g := r.get(guildId)
+	if g == nil {
+		return gorm.ErrRecordNotFound
+	}
+	g.Allowed = allowed
+	g.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateSettings only writes the settings that are set in data.
+func (r *GuildsRepo) UpdateSettings(ctx context.Context, guildId string, data database.GuildSettingsDto) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	g := r.get(guildId)
+	if g == nil {
+		return nil
+	}
+	if data.TimeZone != nil {
+		g.TimeZone = *data.TimeZone
+	}
+	if data.Language != nil {
+		g.Language = *data.Language
+	}
+	if data.EnabledServices != nil {
+		g.EnabledServices = *data.EnabledServices
+	}
+	if data.AdminRoleId != nil {
+		g.AdminRoleId = *data.AdminRoleId
+	}
+	g.UpdatedAt = time.Now()
+	return nil
+}
+
+// AdoptLegacyRows is a no-op, rows without a guild only exist in databases
+// from before guild scoping.
+func (r *GuildsRepo) AdoptLegacyRows(ctx context.Context, guildId string) error {
+	return ctx.Err()
+}
+
+func (r *GuildsRepo) get(guildId string) *models.Guild {
+	return first(r.s.guilds, func(g *models.Guild) bool { return g.GuildId == guildId })
+}
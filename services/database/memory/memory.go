@@ -0,0 +1,133 @@
+// Package memory implements the database repos in memory, so the bot and
+// the intent service can be exercised without a database file.
+//
+// The repos follow the gorm ones: the same ordering, upserts, cascades and
+// soft deletes, and gorm.ErrRecordNotFound where those return it.
+package memory
+
+import (
+	"biyobot/mixins"
+	"biyobot/models"
+	"biyobot/services/database"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store holds the rows of every repo. It is safe for concurrent use.
+type Store struct {
+	mu sync.Mutex
+
+	notifications   []models.Notification
+	discordMessages []models.DiscordMessage
+	expenses        []models.Expense
+	budgets         []models.Budget
+	bindings        []models.ChannelBinding
+	guilds          []models.Guild
+	permissions     []models.Permission
+	boards          []models.Board
+	attendees       []models.Attendee
+	polls           []models.Poll
+	votes           []models.PollVote
+	audits          []models.AuditLog
+	taskRuns        []models.TaskRun
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+// NewRepos returns every repo backed by a new, empty store.
+func NewRepos() *database.Repos {
+	return NewStore().Repos()
+}
+
+// Repos returns every repo backed by s.
+func (s *Store) Repos() *database.Repos {
+	return &database.Repos{
+		Notifications:   &NotificationsRepo{s: s},
+		DiscordMessages: &DiscordMessageRepo{s: s},
+		Expenses:        &ExpensesRepo{s: s},
+		Budgets:         &BudgetsRepo{s: s},
+		ChannelBindings: &ChannelBindingsRepo{s: s},
+		Guilds:          &GuildsRepo{s: s},
+		Permissions:     &PermissionsRepo{s: s},
+		Boards:          &BoardsRepo{s: s},
+		Attendees:       &AttendeesRepo{s: s},
+		Polls:           &PollsRepo{s: s},
+		TaskRuns:        &TaskRunsRepo{s: s},
+	}
+}
+
+// lock fails like a query would when ctx is already done, otherwise it
+// locks the store for the caller to unlock.
+func (s *Store) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	return nil
+}
+
+func (s *Store) unlock() {
+	s.mu.Unlock()
+}
+
+// newBase fills in what BaseModel and gorm would on create.
+func newBase() mixins.BaseModel {
+	id, err := uuid.NewV7()
+	if err != nil {
+		id = uuid.New()
+	}
+	now := time.Now()
+	return mixins.BaseModel{ID: id, CreatedAt: now, UpdatedAt: now}
+}
+
+func newSoftDeleteBase() mixins.SoftDeleteModel {
+	base := newBase()
+	return mixins.SoftDeleteModel{ID: base.ID, CreatedAt: base.CreatedAt, UpdatedAt: base.UpdatedAt}
+}
+
+// inGuild matches the rows of one guild. An empty guildId is the DM context,
+// where users see their own rows from every guild.
+func inGuild(rowGuildId, guildId string) bool {
+	return guildId == "" || rowGuildId == guildId
+}
+
+// filter copies the rows matching match.
+func filter[T any](rows []T, match func(*T) bool) []T {
+	var matched []T
+	for i := range rows {
+		if match(&rows[i]) {
+			matched = append(matched, rows[i])
+		}
+	}
+	return matched
+}
+
+// first returns the first row matching match, nil when there is none. The
+// row is the stored one, changes to it are saved.
+func first[T any](rows []T, match func(*T) bool) *T {
+	for i := range rows {
+		if match(&rows[i]) {
+			return &rows[i]
+		}
+	}
+	return nil
+}
+
+var (
+	_ database.Notifications   = (*NotificationsRepo)(nil)
+	_ database.DiscordMessages = (*DiscordMessageRepo)(nil)
+	_ database.Expenses        = (*ExpensesRepo)(nil)
+	_ database.Budgets         = (*BudgetsRepo)(nil)
+	_ database.ChannelBindings = (*ChannelBindingsRepo)(nil)
+	_ database.Guilds          = (*GuildsRepo)(nil)
+	_ database.Permissions     = (*PermissionsRepo)(nil)
+	_ database.Boards          = (*BoardsRepo)(nil)
+	_ database.Attendees       = (*AttendeesRepo)(nil)
+	_ database.Polls           = (*PollsRepo)(nil)
+	_ database.TaskRuns        = (*TaskRunsRepo)(nil)
+)
@@ -0,0 +1,293 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NotificationsRepo struct {
+	s *Store
+}
+
+func (r *NotificationsRepo) GetPublicNotifications(ctx context.Context, guildId string) ([]models.Notification, error) {
+	notifications, err := r.find(ctx, func(n *models.Notification) bool {
+		return n.GuildId == guildId && !n.Private
+	})
+	sortByNotifyAt(notifications)
+	return notifications, err
+}
+
+func (r *NotificationsRepo) GetUserNotifications(ctx context.Context, guildId, userId string) ([]models.Notification, error) {
+	notifications, err := r.find(ctx, func(n *models.Notification) bool {
+		return inGuild(n.GuildId, guildId) && n.UserId == userId
+	})
+	sortByNotifyAt(notifications)
+	return notifications, err
+}
+
+func (r *NotificationsRepo) GetNotification(ctx context.Context, notificationId uuid.UUID) (*models.Notification, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	n := r.get(notificationId)
+	if n == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	notification := *n
+	return &notification, nil
+}
+
+func (r *NotificationsRepo) GetAllExpiredNotifications(ctx context.Context) ([]models.Notification, error) {
+	now := utils.JapanTimeNow().Add(10 * time.Minute)
+	return r.find(ctx, func(n *models.Notification) bool { return !n.NotifyAt.After(now) })
+}
+
+func (r *NotificationsRepo) CountNotifications(ctx context.Context) (int64, error) {
+	notifications, err := r.find(ctx, func(n *models.Notification) bool { return true })
+	return int64(len(notifications)), err
+}
+
+// DeleteNotification soft deletes a notification along with its attendees.
+func (r *NotificationsRepo) DeleteNotification(ctx context.Context, notificationId uuid.UUID, actor database.Actor) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	n := r.get(notificationId)
+	if n == nil {
+		return gorm.ErrRecordNotFound
+	}
+	before := *n
+	r.delete([]uuid.UUID{notificationId})
+	return r.audit(actor, "delete", &before, nil)
+}
+
+func (r *NotificationsRepo) DeleteNotificationBatch(ctx context.Context, notificationIds []uuid.UUID, actor database.Actor) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	deleted := filter(r.s.notifications, func(n *models.Notification) bool {
+		return !n.DeletedAt.Valid && slices.Contains(notificationIds, n.ID)
+	})
+	r.delete(notificationIds)
+	for i := range deleted {
+		if err := r.audit(actor, "delete", &deleted[i], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeDeleted removes rows deleted before the given time for good.
+func (r *NotificationsRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.s.unlock()
+	r.s.attendees = slices.DeleteFunc(r.s.attendees, func(a models.Attendee) bool {
+		return a.DeletedAt.Valid && a.DeletedAt.Time.Before(before)
+	})
+	count := len(r.s.notifications)
+	r.s.notifications = slices.DeleteFunc(r.s.notifications, func(n models.Notification) bool {
+		return n.DeletedAt.Valid && n.DeletedAt.Time.Before(before)
+	})
+	return int64(count - len(r.s.notifications)), nil
+}
+
+func (r *NotificationsRepo) AddNotification(ctx context.Context, data database.AddNotificationDto) (*models.Notification, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	notification := models.Notification{
+		SoftDeleteModel: newSoftDeleteBase(),
+		Service:         data.Service,
+		Metadata:        data.Metadata,
+		NotifyAt:        utils.InJapanTime(data.NotifyAt),
+		Title:           data.Title,
+		Message:         data.Message,
+		GuildId:         data.GuildId,
+		UserId:          data.UserId,
+		Private:         data.Private,
+		Targets:         data.Targets,
+		IsEvent:         data.IsEvent,
+		Capacity:        data.Capacity,
+	}
+	r.s.notifications = append(r.s.notifications, notification)
+	return &notification, r.audit(data.Actor, "create", nil, &notification)
+}
+
+func (r *NotificationsRepo) EditNotification(ctx context.Context, data database.EditNotificationDto) (*models.Notification, error) {
+	id, err := uuid.Parse(data.ID)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	n := r.get(id)
+	if n == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	before := *n
+	n.Service = data.Service
+	n.Metadata = data.Metadata
+	n.NotifyAt = utils.InJapanTime(data.NotifyAt)
+	n.Title = data.Title
+	n.Message = data.Message
+	if data.Targets != "" {
+		n.Targets = data.Targets
+	}
+	n.UpdatedAt = time.Now()
+	notification := *n
+	if err := r.audit(data.Actor, "edit", &before, &notification); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// UndoLastAction reverts the actor's latest create, edit or delete of a
+// notification of the service.
+func (r *NotificationsRepo) UndoLastAction(ctx context.Context, actor database.Actor, service string) (*database.UndoResult, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	var entry *models.AuditLog
+	for i := len(r.s.audits) - 1; i >= 0; i-- {
+		a := &r.s.audits[i]
+		if a.Entity == "notification" && a.Service == service && a.GuildId == actor.GuildId && a.UserId == actor.UserId &&
+			a.Action != "undo" && a.UndoneAt == nil {
+			entry = a
+			break
+		}
+	}
+	if entry == nil {
+		return nil, database.ErrNothingToUndo
+	}
+	n := first(r.s.notifications, func(n *models.Notification) bool { return n.ID.String() == entry.EntityId })
+	if n == nil || n.DeletedAt.Valid != (entry.Action == "delete") {
+		return nil, database.ErrCannotUndo
+	}
+	before := *n
+
+	switch entry.Action {
+	case "create":
+		r.delete([]uuid.UUID{n.ID})
+	case "edit":
+		previous, err := utils.JsonToStruct[models.Notification](entry.Before)
+		if err != nil {
+			return nil, err
+		}
+		n.Service, n.Metadata, n.NotifyAt = previous.Service, previous.Metadata, previous.NotifyAt
+		n.Title, n.Message, n.Targets = previous.Title, previous.Message, previous.Targets
+		n.UpdatedAt = time.Now()
+	case "delete":
+		n.DeletedAt = gorm.DeletedAt{}
+		for i := range r.s.attendees {
+			if r.s.attendees[i].NotificationId == n.ID {
+				r.s.attendees[i].DeletedAt = gorm.DeletedAt{}
+			}
+		}
+	}
+
+	now := utils.JapanTimeNow()
+	entry.UndoneAt = &now
+	notification := *n
+	after := &notification
+	if entry.Action == "create" {
+		after = nil
+	}
+	if err := r.audit(actor, "undo", &before, after); err != nil {
+		return nil, err
+	}
+	return &database.UndoResult{Action: entry.Action, Notification: &notification}, nil
+}
+
+// SetRsvpMessage records the post an event takes RSVPs on.
+func (r *NotificationsRepo) SetRsvpMessage(ctx context.Context, notificationId uuid.UUID, channelId, messageId string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	if n := r.get(notificationId); n != nil {
+		n.RsvpChannelId, n.RsvpMessageId = channelId, messageId
+		n.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *NotificationsRepo) find(ctx context.Context, match func(*models.Notification) bool) ([]models.Notification, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	return filter(r.s.notifications, func(n *models.Notification) bool {
+		return !n.DeletedAt.Valid && match(n)
+	}), nil
+}
+
+// get returns a notification that is not deleted.
+func (r *NotificationsRepo) get(id uuid.UUID) *models.Notification {
+	return first(r.s.notifications, func(n *models.Notification) bool { return n.ID == id && !n.DeletedAt.Valid })
+}
+
+// delete soft deletes notifications and their attendees.
+func (r *NotificationsRepo) delete(ids []uuid.UUID) {
+	deletedAt := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	for i := range r.s.notifications {
+		if n := &r.s.notifications[i]; !n.DeletedAt.Valid && slices.Contains(ids, n.ID) {
+			n.DeletedAt = deletedAt
+		}
+	}
+	for i := range r.s.attendees {
+		if a := &r.s.attendees[i]; !a.DeletedAt.Valid && slices.Contains(ids, a.NotificationId) {
+			a.DeletedAt = deletedAt
+		}
+	}
+}
+
+// audit records a change like the gorm repo does, before is nil for
+// creates and after for deletes.
+func (r *NotificationsRepo) audit(actor database.Actor, action string, before, after *models.Notification) error {
+	entry := models.AuditLog{
+		BaseModel: newBase(),
+		Entity:    "notification",
+		Action:    action,
+		GuildId:   actor.GuildId,
+		UserId:    actor.UserId,
+		ChannelId: actor.ChannelId,
+		MessageId: actor.MessageId,
+	}
+	var err error
+	if before != nil {
+		entry.EntityId, entry.Service = before.ID.String(), before.Service
+		if entry.Before, err = utils.StructToJson(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		entry.EntityId, entry.Service = after.ID.String(), after.Service
+		if entry.After, err = utils.StructToJson(after); err != nil {
+			return err
+		}
+	}
+	r.s.audits = append(r.s.audits, entry)
+	return nil
+}
+
+func sortByNotifyAt(notifications []models.Notification) {
+	slices.SortStableFunc(notifications, func(a, b models.Notification) int {
+		return a.NotifyAt.Compare(b.NotifyAt)
+	})
+}
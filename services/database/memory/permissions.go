@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"cmp"
+	"context"
+	"slices"
+	"time"
+)
+
+type PermissionsRepo struct {
+	s *Store
+}
+
+func (r *PermissionsRepo) GetGuildPermissions(ctx context.Context, guildId string) ([]models.Permission, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	permissions := filter(r.s.permissions, func(p *models.Permission) bool { return p.GuildId == guildId })
+	slices.SortStableFunc(permissions, func(a, b models.Permission) int {
+		return cmp.Or(cmp.Compare(a.Service, b.Service), cmp.Compare(a.Action, b.Action), cmp.Compare(a.SubjectType, b.SubjectType))
+	})
+	return permissions, nil
+}
+
+// SetPermission adds a rule, or flips the effect of an existing one.
+func (r *PermissionsRepo) SetPermission(ctx context.Context, data database.SetPermissionDto) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	p := first(r.s.permissions, func(p *models.Permission) bool {
+		return p.GuildId == data.GuildId && p.SubjectType == data.SubjectType && p.SubjectId == data.SubjectId &&
+			p.Service == data.Service && p.Action == data.Action
+	})
+	if p == nil {
+		r.s.permissions = append(r.s.permissions, models.Permission{
+			BaseModel:   newBase(),
+			GuildId:     data.GuildId,
+			SubjectType: data.SubjectType,
+			SubjectId:   data.SubjectId,
+			Service:     data.Service,
+			Action:      data.Action,
+			Effect:      data.Effect,
+			CreatedBy:   data.CreatedBy,
+		})
+		return nil
+	}
+	p.Effect, p.CreatedBy = data.Effect, data.CreatedBy
+	p.UpdatedAt = time.Now()
+	return nil
+}
+
+// RemovePermission deletes a rule and reports how many were removed.
+func (r *PermissionsRepo) RemovePermission(ctx context.Context, data database.RemovePermissionDto) (int64, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.s.unlock()
+	before := len(r.s.permissions)
+	r.s.permissions = slices.DeleteFunc(r.s.permissions, func(p models.Permission) bool {
+		return p.GuildId == data.GuildId && p.SubjectType == data.SubjectType && p.SubjectId == data.SubjectId &&
+			p.Service == data.Service && p.Action == data.Action
+	})
+	return int64(before - len(r.s.permissions)), nil
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PollsRepo struct {
+	s *Store
+}
+
+func (r *PollsRepo) GetPoll(ctx context.Context, pollId uuid.UUID) (*models.Poll, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	p := r.get(pollId)
+	if p == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	poll := *p
+	return &poll, nil
+}
+
+// GetPollByNotification returns the poll a closing notification belongs to,
+// nil when there is none.
+func (r *PollsRepo) GetPollByNotification(ctx context.Context, notificationId uuid.UUID) (*models.Poll, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	p := first(r.s.polls, func(p *models.Poll) bool {
+		return p.NotificationId != nil && *p.NotificationId == notificationId
+	})
+	if p == nil {
+		return nil, nil
+	}
+	poll := *p
+	return &poll, nil
+}
+
+// GetVotes returns a poll's votes in the order they were cast.
+func (r *PollsRepo) GetVotes(ctx context.Context, pollId uuid.UUID) ([]models.PollVote, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	votes := filter(r.s.votes, func(v *models.PollVote) bool { return v.PollId == pollId })
+	slices.SortStableFunc(votes, func(a, b models.PollVote) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return votes, nil
+}
+
+func (r *PollsRepo) AddPoll(ctx context.Context, data database.AddPollDto) (*models.Poll, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	poll := models.Poll{
+		BaseModel:      newBase(),
+		GuildId:        data.GuildId,
+		ChannelId:      data.ChannelId,
+		UserId:         data.UserId,
+		Question:       data.Question,
+		Options:        data.Options,
+		MultiChoice:    data.MultiChoice,
+		Anonymous:      data.Anonymous,
+		NotificationId: data.NotificationId,
+	}
+	if data.ClosesAt != nil {
+		closesAt := utils.InJapanTime(*data.ClosesAt)
+		poll.ClosesAt = &closesAt
+	}
+	r.s.polls = append(r.s.polls, poll)
+	return &poll, nil
+}
+
+// SetPollMessage records the message a poll's buttons are on.
+func (r *PollsRepo) SetPollMessage(ctx context.Context, pollId uuid.UUID, channelId, messageId string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	if p := r.get(pollId); p != nil {
+		p.ChannelId, p.MessageId = channelId, messageId
+		p.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// Vote toggles a user's vote for an option. In single choice polls picking
+// another option replaces the user's previous vote.
+func (r *PollsRepo) Vote(ctx context.Context, pollId uuid.UUID, userId string, option int, multiChoice bool) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	before := len(r.s.votes)
+	r.s.votes = slices.DeleteFunc(r.s.votes, func(v models.PollVote) bool {
+		return v.PollId == pollId && v.UserId == userId && v.Option == option
+	})
+	if len(r.s.votes) < before {
+		return nil
+	}
+	if !multiChoice {
+		r.s.votes = slices.DeleteFunc(r.s.votes, func(v models.PollVote) bool {
+			return v.PollId == pollId && v.UserId == userId
+		})
+	}
+	r.s.votes = append(r.s.votes, models.PollVote{BaseModel: newBase(), PollId: pollId, UserId: userId, Option: option})
+	return nil
+}
+
+// ClosePoll marks a poll closed and reports whether it was still open.
+func (r *PollsRepo) ClosePoll(ctx context.Context, pollId uuid.UUID) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.s.unlock()
+	p := r.get(pollId)
+	if p == nil || p.Closed {
+		return false, nil
+	}
+	p.Closed = true
+	p.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *PollsRepo) get(id uuid.UUID) *models.Poll {
+	return first(r.s.polls, func(p *models.Poll) bool { return p.ID == id })
+}
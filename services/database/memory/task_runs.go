@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"biyobot/models"
+	"context"
+	"time"
+)
+
+type TaskRunsRepo struct {
+	s *Store
+}
+
+// GetTaskRun returns nil without error when the task never ran.
+func (r *TaskRunsRepo) GetTaskRun(ctx context.Context, name string) (*models.TaskRun, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+	t := first(r.s.taskRuns, func(t *models.TaskRun) bool { return t.Name == name })
+	if t == nil {
+		return nil, nil
+	}
+	run := *t
+	return &run, nil
+}
+
+// SaveTaskRun records run as the last run of its task.
+func (r *TaskRunsRepo) SaveTaskRun(ctx context.Context, run models.TaskRun) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+	t := first(r.s.taskRuns, func(t *models.TaskRun) bool { return t.Name == run.Name })
+	if t == nil {
+		run.BaseModel = newBase()
+		r.s.taskRuns = append(r.s.taskRuns, run)
+		return nil
+	}
+	t.LastRunAt, t.DurationMs, t.Error = run.LastRunAt, run.DurationMs, run.Error
+	t.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"biyobot/utils"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one embedded schema migration, versions are the timestamps
+// atlas names the files after and sort in the order they apply.
+type Migration struct {
+	Version string
+	Up      string
+	Down    string // empty when the migration can't be reverted
+}
+
+// MigrationStatus is whether a migration was applied. Migrations the database
+// knows but the binary doesn't have no Up.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations, recording them in the
+// schema_version table.
+type Migrator struct {
+	dbm        *DatabaseManager
+	migrations []Migration
+}
+
+func NewMigrator(dbm *DatabaseManager, fsys fs.FS) (*Migrator, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	slices.Sort(files)
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		up, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		down, err := fs.ReadFile(fsys, path.Join("down", file))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read down migration %s: %w", file, err)
+		}
+		migrations = append(migrations, Migration{
+			Version: strings.TrimSuffix(file, ".sql"),
+			Up:      string(up),
+			Down:    string(down),
+		})
+	}
+	return &Migrator{dbm: dbm, migrations: migrations}, nil
+}
+
+type schemaVersion struct {
+	Version   string
+	AppliedAt time.Time
+}
+
+// Status lists every migration, the embedded ones along with those only the
+// database knows.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(func(tx *gorm.DB) error {
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+		byVersion := make(map[string]time.Time, len(applied))
+		for _, v := range applied {
+			byVersion[v.Version] = v.AppliedAt
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if at, ok := byVersion[migration.Version]; ok {
+				status.AppliedAt = &at
+				delete(byVersion, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, at := range byVersion {
+			statuses = append(statuses, MigrationStatus{Migration: Migration{Version: version}, AppliedAt: &at})
+		}
+		slices.SortFunc(statuses, func(a, b MigrationStatus) int { return strings.Compare(a.Version, b.Version) })
+		return nil
+	})
+	return statuses, err
+}
+
+// Up applies the pending migrations and returns their versions. It refuses
+// to touch a database migrated by a newer binary.
+func (m *Migrator) Up() ([]string, error) {
+	var versions []string
+	err := m.withLock(func(tx *gorm.DB) error {
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+		if err := m.checkNewer(applied); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if slices.ContainsFunc(applied, func(v schemaVersion) bool { return v.Version == migration.Version }) {
+				continue
+			}
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return fmt.Errorf("migration %s failed: %w", migration.Version, err)
+			}
+			err := tx.Exec("INSERT INTO schema_version (version, applied_at) VALUES (?, ?)",
+				migration.Version, utils.JapanTimeNow()).Error
+			if err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
+			}
+			versions = append(versions, migration.Version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Down reverts the last n applied migrations and returns their versions.
+func (m *Migrator) Down(n int) ([]string, error) {
+	var versions []string
+	err := m.withLock(func(tx *gorm.DB) error {
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+		for i := len(applied) - 1; i >= 0 && len(versions) < n; i-- {
+			version := applied[i].Version
+			idx := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+			if idx < 0 {
+				return fmt.Errorf("migration %s is unknown to this binary", version)
+			}
+			if m.migrations[idx].Down == "" {
+				return fmt.Errorf("migration %s can't be reverted", version)
+			}
+			if err := tx.Exec(m.migrations[idx].Down).Error; err != nil {
+				return fmt.Errorf("reverting migration %s failed: %w", version, err)
+			}
+			if err := tx.Exec("DELETE FROM schema_version WHERE version = ?", version).Error; err != nil {
+				return fmt.Errorf("failed to unrecord migration %s: %w", version, err)
+			}
+			versions = append(versions, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// migrationLockId keys the PostgreSQL advisory lock migrations hold.
+const migrationLockId = 7_264_519_031
+
+// withLock runs fn in a transaction only one process at a time gets, so bots
+// starting together don't migrate twice. SQLite grants write transactions
+// one connection at a time, PostgreSQL takes an advisory lock.
+func (m *Migrator) withLock(fn func(tx *gorm.DB) error) error {
+	createTable := "CREATE TABLE IF NOT EXISTS schema_version (version varchar NOT NULL PRIMARY KEY, applied_at datetime NOT NULL)"
+	if m.dbm.Dialect() == "postgres" {
+		createTable = "CREATE TABLE IF NOT EXISTS schema_version (version varchar NOT NULL PRIMARY KEY, applied_at timestamptz NOT NULL)"
+		return m.dbm.App().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockId).Error; err != nil {
+				return fmt.Errorf("failed to lock database for migrations: %w", err)
+			}
+			if err := tx.Exec(createTable).Error; err != nil {
+				return err
+			}
+			return fn(tx)
+		})
+	}
+
+	return m.dbm.App().Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("BEGIN IMMEDIATE").Error; err != nil {
+			return fmt.Errorf("failed to lock database for migrations: %w", err)
+		}
+		err := conn.Exec(createTable).Error
+		if err == nil {
+			err = fn(conn)
+		}
+		if err != nil {
+			if rbErr := conn.Exec("ROLLBACK").Error; rbErr != nil {
+				slog.Error("failed to roll back migrations", "err", rbErr)
+			}
+			return err
+		}
+		return conn.Exec("COMMIT").Error
+	})
+}
+
+// applied returns the applied migrations in the order they apply. A database
+// migrated with atlas has its revisions imported on first use.
+func (m *Migrator) applied(tx *gorm.DB) ([]schemaVersion, error) {
+	var applied []schemaVersion
+	if err := tx.Raw("SELECT version, applied_at FROM schema_version ORDER BY version").Scan(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	// atlas only ever managed SQLite databases
+	if len(applied) > 0 || m.dbm.Dialect() != "sqlite" {
+		return applied, nil
+	}
+
+	var atlasTables int64
+	err := tx.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'atlas_schema_revisions'").Scan(&atlasTables).Error
+	if err != nil || atlasTables == 0 {
+		return nil, err
+	}
+	result := tx.Exec(`INSERT INTO schema_version (version, applied_at)
+		SELECT version, executed_at FROM atlas_schema_revisions
+		WHERE applied = total AND (error IS NULL OR error = '')`)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to import atlas revisions: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	slog.Info("imported atlas revisions into schema_version", "count", result.RowsAffected)
+	return m.applied(tx)
+}
+
+// checkNewer fails when the database has migrations applied that are newer
+// than any this binary has, running against that schema could corrupt it.
+func (m *Migrator) checkNewer(applied []schemaVersion) error {
+	if len(applied) == 0 || len(m.migrations) == 0 {
+		return nil
+	}
+	latest := m.migrations[len(m.migrations)-1].Version
+	if newest := applied[len(applied)-1].Version; newest > latest {
+		return fmt.Errorf("database schema %s is newer than this binary's %s, upgrade biyobot or revert it with `migrate down` of the newer one", newest, latest)
+	}
+	return nil
+}
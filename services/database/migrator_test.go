@@ -0,0 +1,173 @@
+package database_test
+
+import (
+	"biyobot/configs"
+	"biyobot/migrations"
+	"biyobot/services/database"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testMigrations are three small migrations, the last n of them left out.
+func testMigrations(leaveOut int) fstest.MapFS {
+	all := []struct{ version, up, down string }{
+		{"20260101000000", "CREATE TABLE a (id integer);", "DROP TABLE a;"},
+		{"20260102000000", "CREATE TABLE b (id integer);", "DROP TABLE b;"},
+		{"20260103000000", "CREATE TABLE c (id integer);", "DROP TABLE c;"},
+	}
+	fsys := fstest.MapFS{}
+	for _, m := range all[:len(all)-leaveOut] {
+		fsys[m.version+".sql"] = &fstest.MapFile{Data: []byte(m.up)}
+		fsys["down/"+m.version+".sql"] = &fstest.MapFile{Data: []byte(m.down)}
+	}
+	return fsys
+}
+
+func openSqlite(t *testing.T) *database.DatabaseManager {
+	t.Helper()
+	dbm, err := database.NewDatabaseManager(configs.DatabaseConfig{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbm.Close() })
+	return dbm
+}
+
+func newMigrator(t *testing.T, dbm *database.DatabaseManager, leaveOut int) *database.Migrator {
+	t.Helper()
+	migrator, err := database.NewMigrator(dbm, testMigrations(leaveOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrator
+}
+
+func tableExists(t *testing.T, dbm *database.DatabaseManager, name string) bool {
+	t.Helper()
+	var count int64
+	if err := dbm.App().Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	dbm := openSqlite(t)
+	migrator := newMigrator(t, dbm, 0)
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"20260101000000", "20260102000000", "20260103000000"}; !slices.Equal(applied, want) {
+		t.Fatalf("applied %v, want %v", applied, want)
+	}
+	if again, err := migrator.Up(); err != nil || len(again) != 0 {
+		t.Fatalf("second up applied %v (%v), want nothing", again, err)
+	}
+
+	reverted, err := migrator.Down(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"20260103000000", "20260102000000"}; !slices.Equal(reverted, want) {
+		t.Fatalf("reverted %v, want %v, newest first", reverted, want)
+	}
+	if !tableExists(t, dbm, "a") || tableExists(t, dbm, "b") || tableExists(t, dbm, "c") {
+		t.Error("down left the wrong tables")
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied"
+		}
+		states = append(states, status.Version+":"+state)
+	}
+	if want := []string{"20260101000000:applied", "20260102000000:pending", "20260103000000:pending"}; !slices.Equal(states, want) {
+		t.Errorf("status = %v, want %v", states, want)
+	}
+
+	if applied, err := migrator.Up(); err != nil || len(applied) != 2 {
+		t.Errorf("up after down applied %v (%v), want the two reverted", applied, err)
+	}
+}
+
+func TestMigratorRefusesNewerSchema(t *testing.T) {
+	dbm := openSqlite(t)
+	if _, err := newMigrator(t, dbm, 0).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// an older binary knows only the first two migrations
+	older := newMigrator(t, dbm, 1)
+	if _, err := older.Up(); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("up from an older binary: err = %v, want the newer schema refused", err)
+	}
+	if _, err := older.Down(1); err == nil {
+		t.Error("an older binary reverted a migration it doesn't have")
+	}
+	statuses, err := older.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := statuses[len(statuses)-1]
+	if last.Version != "20260103000000" || last.Up != "" || last.AppliedAt == nil {
+		t.Errorf("unknown migration listed as %+v, want applied without Up", last)
+	}
+}
+
+func TestMigratorDownNeedsRevert(t *testing.T) {
+	dbm := openSqlite(t)
+	fsys := testMigrations(0)
+	delete(fsys, "down/20260103000000.sql")
+	migrator, err := database.NewMigrator(dbm, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Down(1); err == nil {
+		t.Error("reverted a migration without a down file")
+	}
+	if !tableExists(t, dbm, "c") {
+		t.Error("failed down dropped the table anyway")
+	}
+}
+
+// TestEmbeddedMigrationsRevert checks every shipped SQLite migration can be
+// reverted and applied again.
+func TestEmbeddedMigrationsRevert(t *testing.T) {
+	dbm := openSqlite(t)
+	fsys, err := migrations.For("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := database.NewMigrator(dbm, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverted, err := migrator.Down(len(applied))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(applied) {
+		t.Fatalf("reverted %d of %d migrations", len(reverted), len(applied))
+	}
+	if again, err := migrator.Up(); err != nil || len(again) != len(applied) {
+		t.Errorf("up after reverting everything applied %d (%v), want %d", len(again), err, len(applied))
+	}
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"biyobot/models"
 	"biyobot/utils"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,37 +15,109 @@ type NotificationsRepo struct {
 	dbm *DatabaseManager
 }
 
-func NewNotificationsRepo(dbm *DatabaseManager) *NotificationsRepo{
+func NewNotificationsRepo(dbm *DatabaseManager) *NotificationsRepo {
 	return &NotificationsRepo{dbm: dbm}
 }
 
+// GetPublicNotifications returns the notifications shown on a guild's boards,
+// leaving out those created privately in DMs.
+func (r *NotificationsRepo) GetPublicNotifications(ctx context.Context, guildId string) ([]models.Notification, error) {
+	var notifications []models.Notification
+	result := r.dbm.App().WithContext(ctx).
+		Where("guild_id = ? AND private = ?", guildId, false).
+		Order("notify_at ASC").
+		Find(&notifications)
+	return notifications, result.Error
+}
 
-func (r *NotificationsRepo) GetAllNotifications() ([]models.Notification, error) {
+func (r *NotificationsRepo) GetUserNotifications(ctx context.Context, guildId, userId string) ([]models.Notification, error) {
 	var notifications []models.Notification
-	result := r.dbm.App().Find(&notifications)
+	result := r.dbm.App().WithContext(ctx).
+		Scopes(scopeGuild(guildId)).
+		Where("user_id = ?", userId).
+		Order("notify_at ASC").
+		Find(&notifications)
 	return notifications, result.Error
 }
-func (r *NotificationsRepo) GetAllExpiredNotifications() ([]models.Notification, error) {
+
+func (r *NotificationsRepo) GetNotification(ctx context.Context, notificationId uuid.UUID) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.dbm.App().WithContext(ctx).First(&notification, "id = ?", notificationId).Error; err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *NotificationsRepo) GetAllExpiredNotifications(ctx context.Context) ([]models.Notification, error) {
 	now := utils.JapanTimeNow().Add(10 * time.Minute)
 	var notifications []models.Notification
-	result := r.dbm.App().Where("notify_at <= ?", now).Find(&notifications)
+	result := r.dbm.App().WithContext(ctx).Where("notify_at <= ?", now).Find(&notifications)
 	return notifications, result.Error
 }
 
-func (r *NotificationsRepo) DeleteNotification(notificationId uuid.UUID) (error) {
-	result := r.dbm.App().Delete(&models.Notification{}, "id = ?", notificationId)
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+func (r *NotificationsRepo) CountNotifications(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.dbm.App().WithContext(ctx).Model(&models.Notification{}).Count(&count).Error
+	return count, err
+}
+
+// DeleteNotification soft deletes a notification along with its attendees,
+// the delete can be undone until the row is purged.
+func (r *NotificationsRepo) DeleteNotification(ctx context.Context, notificationId uuid.UUID, actor Actor) error {
+	return r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var notification models.Notification
+		if err := tx.First(&notification, "id = ?", notificationId).Error; err != nil {
+			return err
+		}
+		if err := deleteNotifications(tx, []uuid.UUID{notificationId}); err != nil {
+			return err
+		}
+		return auditNotification(tx, actor, "delete", &notification, nil)
+	})
+}
+
+// DeleteNotificationBatch soft deletes notifications, e.g. once they were
+// delivered by the bot as SystemActor.
+func (r *NotificationsRepo) DeleteNotificationBatch(ctx context.Context, notificationIds []uuid.UUID, actor Actor) error {
+	return r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var notifications []models.Notification
+		if err := tx.Where("id IN ?", notificationIds).Find(&notifications).Error; err != nil {
+			return err
+		}
+		if err := deleteNotifications(tx, notificationIds); err != nil {
+			return err
+		}
+		for i := range notifications {
+			if err := auditNotification(tx, actor, "delete", &notifications[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteNotifications(tx *gorm.DB, notificationIds []uuid.UUID) error {
+	if err := tx.Where("id IN ?", notificationIds).Delete(&models.Notification{}).Error; err != nil {
+		return err
 	}
-	return nil
+	return tx.Where("notification_id IN ?", notificationIds).Delete(&models.Attendee{}).Error
 }
-func (r *NotificationsRepo) DeleteNotificationBatch(notificationIds []uuid.UUID) (error) {
-	return r.dbm.App().
-		Where("id IN ?", notificationIds).
-		Delete(&models.Notification{}).Error
+
+// PurgeDeleted removes notifications, and their attendees, that were deleted
+// before the given time for good. It returns how many notifications were
+// removed, their audit log is kept.
+func (r *NotificationsRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("deleted_at < ?", before).Delete(&models.Attendee{}).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("deleted_at < ?", before).Delete(&models.Notification{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
 }
 
 type AddNotificationDto struct {
@@ -52,18 +126,36 @@ type AddNotificationDto struct {
 	NotifyAt time.Time `json:"notify_at"`
 	Title    string    `json:"title"`
 	Message  string    `json:"message"`
+	GuildId  string    `json:"guild_id"`
+	UserId   string    `json:"user_id"`
+	Private  bool      `json:"private"`
+	Targets  string    `json:"targets"`
+	IsEvent  bool      `json:"is_event"`
+	Capacity int       `json:"capacity"`
+	Actor    Actor     `json:"actor"`
 }
 
-func (r *NotificationsRepo) AddNotification(data AddNotificationDto) (*models.Notification, error) {
+func (r *NotificationsRepo) AddNotification(ctx context.Context, data AddNotificationDto) (*models.Notification, error) {
 	notification := &models.Notification{
 		Service:  data.Service,
 		Metadata: data.Metadata,
-		NotifyAt: data.NotifyAt,
+		NotifyAt: utils.InJapanTime(data.NotifyAt),
 		Title:    data.Title,
 		Message:  data.Message,
+		GuildId:  data.GuildId,
+		UserId:   data.UserId,
+		Private:  data.Private,
+		Targets:  data.Targets,
+		IsEvent:  data.IsEvent,
+		Capacity: data.Capacity,
 	}
-	result := r.dbm.App().Create(notification)
-	return notification, result.Error
+	err := r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(notification).Error; err != nil {
+			return err
+		}
+		return auditNotification(tx, data.Actor, "create", nil, notification)
+	})
+	return notification, err
 }
 
 type EditNotificationDto struct {
@@ -73,20 +165,128 @@ type EditNotificationDto struct {
 	NotifyAt time.Time `json:"notify_at"`
 	Title    string    `json:"title"`
 	Message  string    `json:"message"`
+	Targets  string    `json:"targets"` // kept when empty
+	Actor    Actor     `json:"actor"`
 }
 
-func (r *NotificationsRepo) EditNotification(data EditNotificationDto) (*models.Notification, error) {
+func (r *NotificationsRepo) EditNotification(ctx context.Context, data EditNotificationDto) (*models.Notification, error) {
 	var notification models.Notification
-	if err := r.dbm.App().First(&notification, "id = ?", data.ID).Error; err != nil {
+	err := r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&notification, "id = ?", data.ID).Error; err != nil {
+			return err
+		}
+		before := notification
+
+		updates := map[string]any{
+			"service":   data.Service,
+			"metadata":  data.Metadata,
+			"notify_at": utils.InJapanTime(data.NotifyAt),
+			"title":     data.Title,
+			"message":   data.Message,
+		}
+		if data.Targets != "" {
+			updates["targets"] = data.Targets
+		}
+		if err := tx.Model(&notification).Updates(updates).Error; err != nil {
+			return err
+		}
+		return auditNotification(tx, data.Actor, "edit", &before, &notification)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return &notification, nil
+}
+
+// UndoLastAction reverts the actor's latest create, edit or delete of a
+// notification of the service, in the guild or DMs the actor is in. Creates
+// are deleted, edits put back and deletes restored with their attendees.
+func (r *NotificationsRepo) UndoLastAction(ctx context.Context, actor Actor, service string) (*UndoResult, error) {
+	var result *UndoResult
+	err := r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var entry models.AuditLog
+		err := tx.Where("entity = ? AND service = ? AND guild_id = ? AND user_id = ?", "notification", service, actor.GuildId, actor.UserId).
+			Where("action IN ? AND undone_at IS NULL", []string{"create", "edit", "delete"}).
+			Order("created_at DESC, id DESC").
+			First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return err
+		}
+
+		var notification models.Notification
+		err = tx.Unscoped().First(&notification, "id = ?", entry.EntityId).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCannotUndo
+		}
+		if err != nil {
+			return err
+		}
+		// anything but undoing a delete needs the notification still around
+		if notification.DeletedAt.Valid != (entry.Action == "delete") {
+			return ErrCannotUndo
+		}
+		before := notification
+		after := &notification
+
+		switch entry.Action {
+		case "create":
+			if err := deleteNotifications(tx, []uuid.UUID{notification.ID}); err != nil {
+				return err
+			}
+			after = nil
+		case "edit":
+			previous, err := utils.JsonToStruct[models.Notification](entry.Before)
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&notification).Updates(map[string]any{
+				"service":   previous.Service,
+				"metadata":  previous.Metadata,
+				"notify_at": previous.NotifyAt,
+				"title":     previous.Title,
+				"message":   previous.Message,
+				"targets":   previous.Targets,
+			}).Error
+			if err != nil {
+				return err
+			}
+		case "delete":
+			err := tx.Unscoped().Model(&models.Notification{}).
+				Where("id = ?", notification.ID).
+				Update("deleted_at", nil).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Unscoped().Model(&models.Attendee{}).
+				Where("notification_id = ? AND deleted_at IS NOT NULL", notification.ID).
+				Update("deleted_at", nil).Error
+			if err != nil {
+				return err
+			}
+			notification.DeletedAt = gorm.DeletedAt{}
+		}
 
-	result := r.dbm.App().Model(&notification).Updates(map[string]any{
-		"service":   data.Service,
-		"metadata":  data.Metadata,
-		"notify_at": data.NotifyAt,
-		"title":     data.Title,
-		"message":   data.Message,
+		if err := tx.Model(&entry).Update("undone_at", utils.JapanTimeNow()).Error; err != nil {
+			return err
+		}
+		if err := auditNotification(tx, actor, "undo", &before, after); err != nil {
+			return err
+		}
+		result = &UndoResult{Action: entry.Action, Notification: &notification}
+		return nil
 	})
-	return &notification, result.Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetRsvpMessage records the post an event takes RSVPs on.
+func (r *NotificationsRepo) SetRsvpMessage(ctx context.Context, notificationId uuid.UUID, channelId, messageId string) error {
+	return r.dbm.App().WithContext(ctx).Model(&models.Notification{}).
+		Where("id = ?", notificationId).
+		Updates(map[string]any{"rsvp_channel_id": channelId, "rsvp_message_id": messageId}).Error
 }
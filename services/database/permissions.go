@@ -0,0 +1,73 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+
+	"gorm.io/gorm/clause"
+)
+
+type PermissionsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewPermissionsRepo(dbm *DatabaseManager) *PermissionsRepo {
+	return &PermissionsRepo{dbm: dbm}
+}
+
+func (r *PermissionsRepo) GetGuildPermissions(ctx context.Context, guildId string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.dbm.App().WithContext(ctx).
+		Where("guild_id = ?", guildId).
+		Order("service ASC, action ASC, subject_type ASC").
+		Find(&permissions).Error
+	return permissions, err
+}
+
+type SetPermissionDto struct {
+	GuildId     string `json:"guild_id"`
+	SubjectType string `json:"subject_type"`
+	SubjectId   string `json:"subject_id"`
+	Service     string `json:"service"`
+	Action      string `json:"action"`
+	Effect      string `json:"effect"`
+	CreatedBy   string `json:"created_by"`
+}
+
+// SetPermission adds a rule, or flips the effect of an existing one.
+func (r *PermissionsRepo) SetPermission(ctx context.Context, data SetPermissionDto) error {
+	permission := &models.Permission{
+		GuildId:     data.GuildId,
+		SubjectType: data.SubjectType,
+		SubjectId:   data.SubjectId,
+		Service:     data.Service,
+		Action:      data.Action,
+		Effect:      data.Effect,
+		CreatedBy:   data.CreatedBy,
+	}
+	return r.dbm.App().WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{
+				{Name: "guild_id"}, {Name: "subject_type"}, {Name: "subject_id"}, {Name: "service"}, {Name: "action"},
+			},
+			DoUpdates: clause.AssignmentColumns([]string{"effect", "created_by", "updated_at"}),
+		}).
+		Create(permission).Error
+}
+
+type RemovePermissionDto struct {
+	GuildId     string `json:"guild_id"`
+	SubjectType string `json:"subject_type"`
+	SubjectId   string `json:"subject_id"`
+	Service     string `json:"service"`
+	Action      string `json:"action"`
+}
+
+// RemovePermission deletes a rule and reports how many were removed.
+func (r *PermissionsRepo) RemovePermission(ctx context.Context, data RemovePermissionDto) (int64, error) {
+	result := r.dbm.App().WithContext(ctx).
+		Where("guild_id = ? AND subject_type = ? AND subject_id = ? AND service = ? AND action = ?",
+			data.GuildId, data.SubjectType, data.SubjectId, data.Service, data.Action).
+		Delete(&models.Permission{})
+	return result.RowsAffected, result.Error
+}
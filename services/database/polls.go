@@ -0,0 +1,120 @@
+package database
+
+import (
+	"biyobot/models"
+	"biyobot/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PollsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewPollsRepo(dbm *DatabaseManager) *PollsRepo {
+	return &PollsRepo{dbm: dbm}
+}
+
+func (r *PollsRepo) GetPoll(ctx context.Context, pollId uuid.UUID) (*models.Poll, error) {
+	var poll models.Poll
+	if err := r.dbm.App().WithContext(ctx).First(&poll, "id = ?", pollId).Error; err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// GetPollByNotification returns the poll a closing notification belongs to,
+// nil when there is none.
+func (r *PollsRepo) GetPollByNotification(ctx context.Context, notificationId uuid.UUID) (*models.Poll, error) {
+	var poll models.Poll
+	err := r.dbm.App().WithContext(ctx).First(&poll, "notification_id = ?", notificationId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// GetVotes returns a poll's votes in the order they were cast.
+func (r *PollsRepo) GetVotes(ctx context.Context, pollId uuid.UUID) ([]models.PollVote, error) {
+	var votes []models.PollVote
+	err := r.dbm.App().WithContext(ctx).
+		Where("poll_id = ?", pollId).
+		Order("created_at ASC").
+		Find(&votes).Error
+	return votes, err
+}
+
+type AddPollDto struct {
+	GuildId        string     `json:"guild_id"`
+	ChannelId      string     `json:"channel_id"`
+	UserId         string     `json:"user_id"`
+	Question       string     `json:"question"`
+	Options        string     `json:"options"`
+	MultiChoice    bool       `json:"multi_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closes_at"`
+	NotificationId *uuid.UUID `json:"notification_id"`
+}
+
+func (r *PollsRepo) AddPoll(ctx context.Context, data AddPollDto) (*models.Poll, error) {
+	poll := &models.Poll{
+		GuildId:        data.GuildId,
+		ChannelId:      data.ChannelId,
+		UserId:         data.UserId,
+		Question:       data.Question,
+		Options:        data.Options,
+		MultiChoice:    data.MultiChoice,
+		Anonymous:      data.Anonymous,
+		NotificationId: data.NotificationId,
+	}
+	if data.ClosesAt != nil {
+		closesAt := utils.InJapanTime(*data.ClosesAt)
+		poll.ClosesAt = &closesAt
+	}
+	result := r.dbm.App().WithContext(ctx).Create(poll)
+	return poll, result.Error
+}
+
+// SetPollMessage records the message a poll's buttons are on.
+func (r *PollsRepo) SetPollMessage(ctx context.Context, pollId uuid.UUID, channelId, messageId string) error {
+	return r.dbm.App().WithContext(ctx).Model(&models.Poll{}).
+		Where("id = ?", pollId).
+		Updates(map[string]any{"channel_id": channelId, "message_id": messageId}).Error
+}
+
+// Vote toggles a user's vote for an option. In single choice polls picking
+// another option replaces the user's previous vote.
+func (r *PollsRepo) Vote(ctx context.Context, pollId uuid.UUID, userId string, option int, multiChoice bool) error {
+	return r.dbm.App().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("poll_id = ? AND user_id = ? AND option = ?", pollId, userId, option).Delete(&models.PollVote{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		if !multiChoice {
+			err := tx.Where("poll_id = ? AND user_id = ?", pollId, userId).Delete(&models.PollVote{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.PollVote{PollId: pollId, UserId: userId, Option: option}).Error
+	})
+}
+
+// ClosePoll marks a poll closed and reports whether it was still open, so
+// results are only announced once.
+func (r *PollsRepo) ClosePoll(ctx context.Context, pollId uuid.UUID) (bool, error) {
+	result := r.dbm.App().WithContext(ctx).Model(&models.Poll{}).
+		Where("id = ? AND closed = ?", pollId, false).
+		Update("closed", true)
+	return result.RowsAffected > 0, result.Error
+}
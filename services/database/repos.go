@@ -0,0 +1,152 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The interfaces below are what the bot, the intent service and the services
+// depend on. The gorm repos in this package implement them against the
+// database, the memory package implements them for tests.
+
+type Notifications interface {
+	GetPublicNotifications(ctx context.Context, guildId string) ([]models.Notification, error)
+	GetUserNotifications(ctx context.Context, guildId, userId string) ([]models.Notification, error)
+	GetNotification(ctx context.Context, notificationId uuid.UUID) (*models.Notification, error)
+	GetAllExpiredNotifications(ctx context.Context) ([]models.Notification, error)
+	CountNotifications(ctx context.Context) (int64, error)
+	DeleteNotification(ctx context.Context, notificationId uuid.UUID, actor Actor) error
+	DeleteNotificationBatch(ctx context.Context, notificationIds []uuid.UUID, actor Actor) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	AddNotification(ctx context.Context, data AddNotificationDto) (*models.Notification, error)
+	EditNotification(ctx context.Context, data EditNotificationDto) (*models.Notification, error)
+	UndoLastAction(ctx context.Context, actor Actor, service string) (*UndoResult, error)
+	SetRsvpMessage(ctx context.Context, notificationId uuid.UUID, channelId, messageId string) error
+}
+
+type DiscordMessages interface {
+	GetAllExpiredMessages(ctx context.Context) ([]models.DiscordMessage, error)
+	CountQueuedMessages(ctx context.Context) (int64, error)
+	GetDueActions(ctx context.Context) ([]models.DiscordMessage, error)
+	AddMessage(ctx context.Context, data AddDiscordMessageDto) (*models.DiscordMessage, error)
+	DeleteMessageBatch(ctx context.Context, ids []uuid.UUID) error
+	RetryMessageBatch(ctx context.Context, ids []uuid.UUID, retryAt time.Time) error
+	CancelActions(ctx context.Context, refId string) error
+}
+
+type Expenses interface {
+	GetExpense(ctx context.Context, expenseId uuid.UUID) (*models.Expense, error)
+	GetRecentExpenses(ctx context.Context, guildId, userId string, limit int) ([]models.Expense, error)
+	AddExpense(ctx context.Context, data AddExpenseDto) (*models.Expense, error)
+	EditExpense(ctx context.Context, data EditExpenseDto) (*models.Expense, error)
+	DeleteExpense(ctx context.Context, expenseId uuid.UUID) error
+	GetExpensesBetween(ctx context.Context, guildId, userId string, from, to time.Time) ([]models.Expense, error)
+}
+
+type Budgets interface {
+	GetBudget(ctx context.Context, guildId, userId, category string) (*models.Budget, error)
+	GetBudgets(ctx context.Context, guildId, userId string) ([]models.Budget, error)
+	SetBudget(ctx context.Context, data SetBudgetDto) (*models.Budget, error)
+	MarkAlerted(ctx context.Context, budgetId uuid.UUID, month string, percent int) error
+}
+
+type ChannelBindings interface {
+	GetChannelServices(ctx context.Context, channelId string) ([]string, error)
+	GetServiceBindings(ctx context.Context, service string) ([]models.ChannelBinding, error)
+	GetGuildBindings(ctx context.Context, guildId string) ([]models.ChannelBinding, error)
+	BindChannel(ctx context.Context, data BindChannelDto) error
+	UnbindChannel(ctx context.Context, guildId, channelId string, services []string) (int64, error)
+}
+
+type Guilds interface {
+	GetGuild(ctx context.Context, guildId string) (*models.Guild, error)
+	GetAllGuilds(ctx context.Context) ([]models.Guild, error)
+	EnsureGuild(ctx context.Context, data UpsertGuildDto) (*models.Guild, error)
+	SetAllowed(ctx context.Context, guildId string, allowed bool) error
+	UpdateSettings(ctx context.Context, guildId string, data GuildSettingsDto) error
+	AdoptLegacyRows(ctx context.Context, guildId string) error
+}
+
+type Permissions interface {
+	GetGuildPermissions(ctx context.Context, guildId string) ([]models.Permission, error)
+	SetPermission(ctx context.Context, data SetPermissionDto) error
+	RemovePermission(ctx context.Context, data RemovePermissionDto) (int64, error)
+}
+
+type Boards interface {
+	GetBoard(ctx context.Context, channelId, service string) (*models.Board, error)
+	GetBoardById(ctx context.Context, id uuid.UUID) (*models.Board, error)
+	SaveBoard(ctx context.Context, data SaveBoardDto) (*models.Board, error)
+	SetBoardView(ctx context.Context, id uuid.UUID, view string, page int) error
+	DeleteBoard(ctx context.Context, id uuid.UUID) error
+}
+
+type Attendees interface {
+	GetAttendees(ctx context.Context, notificationId uuid.UUID) ([]models.Attendee, error)
+	CountGoing(ctx context.Context, notificationIds []uuid.UUID) (map[uuid.UUID]int, error)
+	Rsvp(ctx context.Context, data RsvpDto) (*RsvpResult, error)
+}
+
+type Polls interface {
+	GetPoll(ctx context.Context, pollId uuid.UUID) (*models.Poll, error)
+	GetPollByNotification(ctx context.Context, notificationId uuid.UUID) (*models.Poll, error)
+	GetVotes(ctx context.Context, pollId uuid.UUID) ([]models.PollVote, error)
+	AddPoll(ctx context.Context, data AddPollDto) (*models.Poll, error)
+	SetPollMessage(ctx context.Context, pollId uuid.UUID, channelId, messageId string) error
+	Vote(ctx context.Context, pollId uuid.UUID, userId string, option int, multiChoice bool) error
+	ClosePoll(ctx context.Context, pollId uuid.UUID) (bool, error)
+}
+
+type TaskRuns interface {
+	GetTaskRun(ctx context.Context, name string) (*models.TaskRun, error)
+	SaveTaskRun(ctx context.Context, run models.TaskRun) error
+}
+
+// Repos collects every repo, so they are built in one place and handed
+// around together.
+type Repos struct {
+	Notifications   Notifications
+	DiscordMessages DiscordMessages
+	Expenses        Expenses
+	Budgets         Budgets
+	ChannelBindings ChannelBindings
+	Guilds          Guilds
+	Permissions     Permissions
+	Boards          Boards
+	Attendees       Attendees
+	Polls           Polls
+	TaskRuns        TaskRuns
+}
+
+func NewRepos(dbm *DatabaseManager) *Repos {
+	return &Repos{
+		Notifications:   NewNotificationsRepo(dbm),
+		DiscordMessages: NewDiscordMessageRepo(dbm),
+		Expenses:        NewExpensesRepo(dbm),
+		Budgets:         NewBudgetsRepo(dbm),
+		ChannelBindings: NewChannelBindingsRepo(dbm),
+		Guilds:          NewGuildsRepo(dbm),
+		Permissions:     NewPermissionsRepo(dbm),
+		Boards:          NewBoardsRepo(dbm),
+		Attendees:       NewAttendeesRepo(dbm),
+		Polls:           NewPollsRepo(dbm),
+		TaskRuns:        NewTaskRunsRepo(dbm),
+	}
+}
+
+var (
+	_ Notifications   = (*NotificationsRepo)(nil)
+	_ DiscordMessages = (*DiscordMessageRepo)(nil)
+	_ Expenses        = (*ExpensesRepo)(nil)
+	_ Budgets         = (*BudgetsRepo)(nil)
+	_ ChannelBindings = (*ChannelBindingsRepo)(nil)
+	_ Guilds          = (*GuildsRepo)(nil)
+	_ Permissions     = (*PermissionsRepo)(nil)
+	_ Boards          = (*BoardsRepo)(nil)
+	_ Attendees       = (*AttendeesRepo)(nil)
+	_ Polls           = (*PollsRepo)(nil)
+	_ TaskRuns        = (*TaskRunsRepo)(nil)
+)
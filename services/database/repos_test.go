@@ -0,0 +1,494 @@
+package database_test
+
+import (
+	"biyobot/configs"
+	"biyobot/migrations"
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/services/database/memory"
+	"biyobot/utils"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openMigrated opens a database and brings its schema up to date, it is
+// closed when the test ends.
+func openMigrated(t *testing.T, conf configs.DatabaseConfig) *database.DatabaseManager {
+	t.Helper()
+	dbm, err := database.NewDatabaseManager(conf)
+	if err != nil {
+		t.Fatalf("open %s: %v", conf.Driver, err)
+	}
+	t.Cleanup(func() { dbm.Close() })
+	fsys, err := migrations.For(dbm.Dialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := database.NewMigrator(dbm, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate %s: %v", conf.Driver, err)
+	}
+	return dbm
+}
+
+func TestMemoryRepos(t *testing.T) {
+	testRepos(t, func(t *testing.T) *database.Repos { return memory.NewRepos() })
+}
+
+func TestSqliteRepos(t *testing.T) {
+	testRepos(t, func(t *testing.T) *database.Repos {
+		dbm := openMigrated(t, configs.DatabaseConfig{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")})
+		return database.NewRepos(dbm)
+	})
+}
+
+// TestPostgresRepos runs against the database at DATABASE_URL, which must be
+// a throwaway one, every table is emptied between subtests.
+func TestPostgresRepos(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	dbm := openMigrated(t, configs.DatabaseConfig{Driver: "postgres", URL: url})
+	testRepos(t, func(t *testing.T) *database.Repos {
+		var tables []string
+		err := dbm.App().Raw("SELECT tablename FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_version'").
+			Scan(&tables).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tables) > 0 {
+			if err := dbm.App().Exec("TRUNCATE " + strings.Join(tables, ", ") + " CASCADE").Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		return database.NewRepos(dbm)
+	})
+}
+
+func TestDeliveredNotificationsAuditedAsSystem(t *testing.T) {
+	dbm := openMigrated(t, configs.DatabaseConfig{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")})
+	repos := database.NewRepos(dbm)
+	id := addNotification(t, repos, database.AddNotificationDto{Title: "standup", GuildId: "g1", UserId: "alice"})
+	if err := repos.Notifications.DeleteNotificationBatch(context.Background(), []uuid.UUID{id}, database.SystemActor); err != nil {
+		t.Fatal(err)
+	}
+	var entries []models.AuditLog
+	if err := dbm.App().Where("entity_id = ? AND action = ?", id.String(), "delete").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UserId != database.SystemActor.UserId {
+		t.Errorf("delete audit = %+v, want one by %s", entries, database.SystemActor.UserId)
+	}
+}
+
+// testRepos runs the same checks against every implementation, newRepos
+// returns empty repos for each subtest.
+func testRepos(t *testing.T, newRepos func(t *testing.T) *database.Repos) {
+	t.Run("expired notifications", func(t *testing.T) {
+		testExpired(t, newRepos(t))
+	})
+	t.Run("notifications are scoped by guild", func(t *testing.T) {
+		testGuildScope(t, newRepos(t))
+	})
+	t.Run("undo restores a deleted notification", func(t *testing.T) {
+		testUndo(t, newRepos(t))
+	})
+	t.Run("purge deleted", func(t *testing.T) {
+		testPurgeDeleted(t, newRepos(t))
+	})
+	t.Run("rsvp waitlist", func(t *testing.T) {
+		testRsvpWaitlist(t, newRepos(t))
+	})
+	t.Run("rsvp waitlist order", func(t *testing.T) {
+		testRsvpWaitlistOrder(t, newRepos(t))
+	})
+	t.Run("deleted events take no answers", func(t *testing.T) {
+		testDeletedEventRsvp(t, newRepos(t))
+	})
+	t.Run("channel bindings", func(t *testing.T) {
+		testChannelBindings(t, newRepos(t))
+	})
+	t.Run("scheduled actions", func(t *testing.T) {
+		testScheduledActions(t, newRepos(t))
+	})
+}
+
+func addNotification(t *testing.T, repos *database.Repos, data database.AddNotificationDto) uuid.UUID {
+	t.Helper()
+	if data.Service == "" {
+		data.Service = configs.ServiceNames.Scheduler
+	}
+	if data.NotifyAt.IsZero() {
+		data.NotifyAt = time.Now().Add(time.Hour)
+	}
+	n, err := repos.Notifications.AddNotification(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n.ID
+}
+
+func testExpired(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	past := addNotification(t, repos, database.AddNotificationDto{Title: "past", GuildId: "g1", UserId: "alice", NotifyAt: time.Now().Add(-time.Minute)})
+	addNotification(t, repos, database.AddNotificationDto{Title: "future", GuildId: "g1", UserId: "alice"})
+
+	expired, err := repos.Notifications.GetAllExpiredNotifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].ID != past {
+		t.Fatalf("expired = %v, want only past", expired)
+	}
+	if err := repos.Notifications.DeleteNotificationBatch(ctx, []uuid.UUID{past}, database.SystemActor); err != nil {
+		t.Fatal(err)
+	}
+	count, err := repos.Notifications.CountNotifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d notifications left, want 1", count)
+	}
+}
+
+func testGuildScope(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	addNotification(t, repos, database.AddNotificationDto{Title: "a", GuildId: "g1", UserId: "alice"})
+	addNotification(t, repos, database.AddNotificationDto{Title: "b", GuildId: "g2", UserId: "alice"})
+	addNotification(t, repos, database.AddNotificationDto{Title: "c", GuildId: "g1", UserId: "alice", Private: true})
+
+	public, err := repos.Notifications.GetPublicNotifications(ctx, "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(public) != 1 || public[0].Title != "a" {
+		t.Errorf("public in g1 = %v, want only a", titles(public))
+	}
+	mine, err := repos.Notifications.GetUserNotifications(ctx, "g2", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 1 || mine[0].Title != "b" {
+		t.Errorf("alice's in g2 = %v, want only b", titles(mine))
+	}
+}
+
+func testUndo(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	alice := database.Actor{UserId: "alice", GuildId: "g1", ChannelId: "c1"}
+	id := addNotification(t, repos, database.AddNotificationDto{Title: "standup", GuildId: "g1", UserId: "alice", Actor: alice})
+	if err := repos.Notifications.DeleteNotification(ctx, id, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Notifications.GetNotification(ctx, id); err == nil {
+		t.Fatal("notification still there after delete")
+	}
+
+	bob := database.Actor{UserId: "bob", GuildId: "g1", ChannelId: "c1"}
+	if _, err := repos.Notifications.UndoLastAction(ctx, bob, configs.ServiceNames.Scheduler); !errors.Is(err, database.ErrNothingToUndo) {
+		t.Errorf("bob's undo: err = %v, want ErrNothingToUndo", err)
+	}
+	elsewhere := database.Actor{UserId: "alice", GuildId: "g2", ChannelId: "c2"}
+	if _, err := repos.Notifications.UndoLastAction(ctx, elsewhere, configs.ServiceNames.Scheduler); !errors.Is(err, database.ErrNothingToUndo) {
+		t.Errorf("undo from another guild: err = %v, want ErrNothingToUndo", err)
+	}
+
+	result, err := repos.Notifications.UndoLastAction(ctx, alice, configs.ServiceNames.Scheduler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != "delete" || result.Notification.ID != id {
+		t.Errorf("undid %s of %s, want delete of %s", result.Action, result.Notification.ID, id)
+	}
+	if _, err := repos.Notifications.GetNotification(ctx, id); err != nil {
+		t.Errorf("notification not restored: %v", err)
+	}
+
+	// the next undo takes back the create
+	result, err = repos.Notifications.UndoLastAction(ctx, alice, configs.ServiceNames.Scheduler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != "create" {
+		t.Errorf("second undo was a %s, want create", result.Action)
+	}
+	if _, err := repos.Notifications.GetNotification(ctx, id); err == nil {
+		t.Error("notification still there after undoing its create")
+	}
+}
+
+func testPurgeDeleted(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	alice := database.Actor{UserId: "alice", GuildId: "g1", ChannelId: "c1"}
+	id := addNotification(t, repos, database.AddNotificationDto{Title: "standup", GuildId: "g1", UserId: "alice", Actor: alice})
+	if err := repos.Notifications.DeleteNotification(ctx, id, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bot passes cutoffs in JST, deleted_at must compare with them
+	purged, err := repos.Notifications.PurgeDeleted(ctx, utils.JapanTimeNow().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Fatalf("purged %d notifications deleted just now", purged)
+	}
+	purged, err = repos.Notifications.PurgeDeleted(ctx, utils.JapanTimeNow().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d, want 1", purged)
+	}
+	if _, err := repos.Notifications.UndoLastAction(ctx, alice, configs.ServiceNames.Scheduler); !errors.Is(err, database.ErrCannotUndo) {
+		t.Errorf("undo after the purge: err = %v, want ErrCannotUndo", err)
+	}
+}
+
+func testRsvpWaitlist(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	id := addNotification(t, repos, database.AddNotificationDto{Title: "games", GuildId: "g1", UserId: "alice", IsEvent: true, Capacity: 2})
+	rsvp := func(userId, status string) *database.RsvpResult {
+		t.Helper()
+		result, err := repos.Attendees.Rsvp(ctx, database.RsvpDto{NotificationId: id, UserId: userId, Status: status, Capacity: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	for _, user := range []string{"alice", "bob"} {
+		if got := rsvp(user, "going").Status; got != "going" {
+			t.Errorf("%s: %s, want going", user, got)
+		}
+	}
+	for _, user := range []string{"carol", "dave"} {
+		if got := rsvp(user, "going").Status; got != "waitlist" {
+			t.Errorf("%s: %s, want waitlist", user, got)
+		}
+	}
+	// answering going again keeps the spot in line
+	if got := rsvp("carol", "going").Status; got != "waitlist" {
+		t.Errorf("carol again: %s, want waitlist", got)
+	}
+
+	result := rsvp("bob", "declined")
+	if result.Promoted == nil || result.Promoted.UserId != "carol" {
+		t.Fatalf("promoted %+v, want carol", result.Promoted)
+	}
+	if result := rsvp("dave", "maybe"); result.Promoted != nil {
+		t.Errorf("leaving the waitlist promoted %s", result.Promoted.UserId)
+	}
+
+	counts, err := repos.Attendees.CountGoing(ctx, []uuid.UUID{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[id] != 2 {
+		t.Errorf("going = %d, want 2", counts[id])
+	}
+}
+
+func testRsvpWaitlistOrder(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	id := addNotification(t, repos, database.AddNotificationDto{Title: "games", GuildId: "g1", UserId: "alice", IsEvent: true, Capacity: 1})
+	rsvp := func(userId, status string) *database.RsvpResult {
+		t.Helper()
+		result, err := repos.Attendees.Rsvp(ctx, database.RsvpDto{NotificationId: id, UserId: userId, Status: status, Capacity: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	rsvp("alice", "going")
+	rsvp("bob", "going")
+	rsvp("carol", "going")
+	// bob leaves the line and joins again behind carol, though his answer
+	// is older
+	rsvp("bob", "maybe")
+	rsvp("bob", "going")
+
+	result := rsvp("alice", "declined")
+	if result.Promoted == nil || result.Promoted.UserId != "carol" {
+		t.Fatalf("promoted %+v, want carol", result.Promoted)
+	}
+	result = rsvp("carol", "declined")
+	if result.Promoted == nil || result.Promoted.UserId != "bob" {
+		t.Fatalf("promoted %+v, want bob", result.Promoted)
+	}
+	attendees, err := repos.Attendees.GetAttendees(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range attendees {
+		if a.Status != "waitlist" && a.WaitlistedAt != nil {
+			t.Errorf("%s is %s but still has waitlisted_at", a.UserId, a.Status)
+		}
+	}
+}
+
+func testChannelBindings(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	scheduler, receipts := configs.ServiceNames.Scheduler, configs.ServiceNames.Receipts
+	for _, dto := range []database.BindChannelDto{
+		{GuildId: "g1", ChannelId: "c1", Service: scheduler},
+		{GuildId: "g1", ChannelId: "c1", Service: receipts},
+		{GuildId: "g1", ChannelId: "c1", Service: scheduler}, // again, a no-op
+		{GuildId: "g2", ChannelId: "c2", Service: scheduler},
+	} {
+		if err := repos.ChannelBindings.BindChannel(ctx, dto); err != nil {
+			t.Fatal(err)
+		}
+	}
+	services, err := repos.ChannelBindings.GetChannelServices(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 {
+		t.Errorf("c1 services = %v, want scheduler and receipts", services)
+	}
+
+	// another guild can't touch c1's bindings
+	removed, err := repos.ChannelBindings.UnbindChannel(ctx, "g2", "c1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Errorf("g2 removed %d of c1's bindings", removed)
+	}
+
+	removed, err = repos.ChannelBindings.UnbindChannel(ctx, "g1", "c1", []string{receipts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d bindings, want 1", removed)
+	}
+	bound, err := repos.ChannelBindings.GetGuildBindings(ctx, "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bound) != 1 || bound[0].Service != scheduler {
+		t.Errorf("g1 bindings = %+v, want only the scheduler", bound)
+	}
+
+	// unbound rows are gone for good, binding again is not a no-op
+	if err := repos.ChannelBindings.BindChannel(ctx, database.BindChannelDto{GuildId: "g1", ChannelId: "c1", Service: receipts}); err != nil {
+		t.Fatal(err)
+	}
+	if services, _ := repos.ChannelBindings.GetChannelServices(ctx, "c1"); len(services) != 2 {
+		t.Errorf("c1 services after binding again = %v, want scheduler and receipts", services)
+	}
+}
+
+func testDeletedEventRsvp(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	alice := database.Actor{UserId: "alice", GuildId: "g1", ChannelId: "c1"}
+	id := addNotification(t, repos, database.AddNotificationDto{Title: "games", GuildId: "g1", UserId: "alice", IsEvent: true, Actor: alice})
+	if _, err := repos.Attendees.Rsvp(ctx, database.RsvpDto{NotificationId: id, UserId: "bob", Status: "going"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Notifications.DeleteNotification(ctx, id, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Attendees.Rsvp(ctx, database.RsvpDto{NotificationId: id, UserId: "bob", Status: "maybe"}); err == nil {
+		t.Error("answered a deleted event")
+	}
+
+	if _, err := repos.Notifications.UndoLastAction(ctx, alice, configs.ServiceNames.Scheduler); err != nil {
+		t.Fatal(err)
+	}
+	result, err := repos.Attendees.Rsvp(ctx, database.RsvpDto{NotificationId: id, UserId: "bob", Status: "maybe"})
+	if err != nil {
+		t.Fatalf("answer after the undo: %v", err)
+	}
+	if result.Status != "maybe" {
+		t.Errorf("status = %s, want maybe", result.Status)
+	}
+	attendees, err := repos.Attendees.GetAttendees(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attendees) != 1 || attendees[0].Status != "maybe" {
+		t.Errorf("attendees = %+v, want bob's restored answer, changed to maybe", attendees)
+	}
+}
+
+func titles(notifications []models.Notification) []string {
+	var out []string
+	for _, n := range notifications {
+		out = append(out, n.Title)
+	}
+	return out
+}
+
+func testScheduledActions(t *testing.T, repos *database.Repos) {
+	ctx := context.Background()
+	now := utils.JapanTimeNow()
+	add := func(action string, at time.Time, payload string) uuid.UUID {
+		t.Helper()
+		m, err := repos.DiscordMessages.AddMessage(ctx, database.AddDiscordMessageDto{
+			Action: action, ChannelId: "c1", MessageId: "m1", ExecuteActionOn: at, Payload: payload, RefId: "event",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.ID
+	}
+	due := func() []models.DiscordMessage {
+		t.Helper()
+		actions, err := repos.DiscordMessages.GetDueActions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return actions
+	}
+
+	payload := `{"content":"🔔 \"Dinner\" is starting now\n"}`
+	edit := add("edit", now.Add(-time.Minute), payload)
+	pin := add("pin", now.Add(-2*time.Minute), "")
+	add("unpin", now.Add(time.Hour), "")
+	add("delete", now.Add(-time.Minute), "")
+
+	actions := due()
+	if len(actions) != 2 || actions[0].ID != pin || actions[1].ID != edit {
+		t.Fatalf("due actions = %v, want the pin then the edit", actions)
+	}
+	if actions[1].Payload != payload || actions[1].RefId != "event" || actions[1].MessageId != "m1" {
+		t.Errorf("edit came back as %+v", actions[1])
+	}
+
+	if err := repos.DiscordMessages.RetryMessageBatch(ctx, []uuid.UUID{pin}, now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.DiscordMessages.RetryMessageBatch(ctx, []uuid.UUID{edit}, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	actions = due()
+	if len(actions) != 1 || actions[0].ID != pin || actions[0].Attempts != 1 {
+		t.Fatalf("due actions after retrying = %v, want the pin on its second attempt", actions)
+	}
+
+	// the deletion of a message already sent outlives its event
+	if err := repos.DiscordMessages.CancelActions(ctx, "event"); err != nil {
+		t.Fatal(err)
+	}
+	if actions := due(); len(actions) != 0 {
+		t.Errorf("due actions after cancelling = %v", actions)
+	}
+	if count, err := repos.DiscordMessages.CountQueuedMessages(ctx); err != nil || count != 1 {
+		t.Errorf("%d deletions queued (%v), want the one kept", count, err)
+	}
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"biyobot/models"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TaskRunsRepo struct {
+	dbm *DatabaseManager
+}
+
+func NewTaskRunsRepo(dbm *DatabaseManager) *TaskRunsRepo {
+	return &TaskRunsRepo{dbm: dbm}
+}
+
+// GetTaskRun returns nil without error when the task never ran.
+func (r *TaskRunsRepo) GetTaskRun(ctx context.Context, name string) (*models.TaskRun, error) {
+	var run models.TaskRun
+	err := r.dbm.App().WithContext(ctx).First(&run, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// SaveTaskRun records run as the last run of its task.
+func (r *TaskRunsRepo) SaveTaskRun(ctx context.Context, run models.TaskRun) error {
+	return r.dbm.App().WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_run_at", "duration_ms", "error", "updated_at"}),
+		}).
+		Create(&run).Error
+}
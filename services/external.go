@@ -18,13 +18,13 @@ type ExternalRunner struct {
 	Env        []string // extra env vars in "KEY=VALUE" form
 }
 
-func (e *ExternalRunner) Run(input json.RawMessage) configs.ServiceResult {
+func (e *ExternalRunner) Run(ctx context.Context, input json.RawMessage) configs.ServiceResult {
 	timeout := e.Timeout
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, e.Executable, e.Args...)
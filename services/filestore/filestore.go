@@ -0,0 +1,119 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrTooLarge = errors.New("file exceeds size limit")
+
+// FileStore keeps files on disk addressed by the sha256 of their content, so
+// the same upload posted twice is only stored once.
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create file store: %w", err)
+	}
+	return &FileStore{root: root}, nil
+}
+
+// Put streams r into the store and returns its hash and size. Reading more
+// than maxBytes aborts with ErrTooLarge and nothing is kept.
+func (fs *FileStore) Put(r io.Reader, maxBytes int64) (string, int64, error) {
+	tmp, err := os.CreateTemp(filepath.Join(fs.root, "tmp"), "upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if size > maxBytes {
+		return "", 0, ErrTooLarge
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	dst := fs.Path(hash)
+	if _, err := os.Stat(dst); err == nil {
+		// stored again, Prune must not take it from under the new upload
+		now := time.Now()
+		if err := os.Chtimes(dst, now, now); err != nil {
+			return "", 0, err
+		}
+		return hash, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// Path returns where the file with hash lives, fanned out by the first two
+// bytes of the hash to keep directories small.
+func (fs *FileStore) Path(hash string) string {
+	if len(hash) < 4 {
+		return filepath.Join(fs.root, hash)
+	}
+	return filepath.Join(fs.root, hash[:2], hash[2:4], hash)
+}
+
+func (fs *FileStore) Open(hash string) (*os.File, error) {
+	return os.Open(fs.Path(hash))
+}
+
+func (fs *FileStore) Remove(hash string) error {
+	err := os.Remove(fs.Path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Prune removes the files last stored before the given time, along with
+// uploads left behind in tmp by a crash, and returns how many it removed.
+func (fs *FileStore) Prune(before time.Time) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(fs.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune file store: %w", err)
+	}
+	return removed, nil
+}
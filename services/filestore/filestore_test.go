@@ -0,0 +1,145 @@
+package filestore
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStore(t *testing.T) *FileStore {
+	t.Helper()
+	fs, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// files lists everything kept under the store's root.
+func files(t *testing.T, fs *FileStore) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir(fs.root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestPutKeepsContentByHash(t *testing.T) {
+	fs := newStore(t)
+	hash, size, err := fs.Put(strings.NewReader("receipt"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 7 || len(hash) != 64 {
+		t.Errorf("Put = %q, %d", hash, size)
+	}
+	if path := fs.Path(hash); path != filepath.Join(fs.root, hash[:2], hash[2:4], hash) {
+		t.Errorf("stored at %s", path)
+	}
+	f, err := fs.Open(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if content, _ := io.ReadAll(f); string(content) != "receipt" {
+		t.Errorf("read back %q", content)
+	}
+}
+
+func TestPutDedupsSameContent(t *testing.T) {
+	fs := newStore(t)
+	first, _, err := fs.Put(strings.NewReader("receipt"), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := fs.Put(strings.NewReader("receipt"), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := fs.Put(strings.NewReader("another receipt"), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Errorf("same content got hashes %s and %s", first, second)
+	}
+	if first == other {
+		t.Error("different content got the same hash")
+	}
+	if kept := files(t, fs); len(kept) != 2 {
+		t.Errorf("kept %q, want one file per content", kept)
+	}
+}
+
+func TestPutRejectsTooLarge(t *testing.T) {
+	fs := newStore(t)
+	if _, _, err := fs.Put(strings.NewReader("exactly10!"), 10); err != nil {
+		t.Fatalf("a file at the limit: %v", err)
+	}
+
+	_, _, err := fs.Put(strings.NewReader("one byte over"), 12)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	// only the file at the limit, nothing left in tmp
+	if kept := files(t, fs); len(kept) != 1 {
+		t.Errorf("kept %q", kept)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	fs := newStore(t)
+	old, _, err := fs.Put(strings.NewReader("old"), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _, err := fs.Put(strings.NewReader("stored again"), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _, err := fs.Put(strings.NewReader("fresh"), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leftover := filepath.Join(fs.root, "tmp", "upload-1")
+	if err := os.WriteFile(leftover, []byte("crashed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	for _, path := range []string{fs.Path(old), fs.Path(again), leftover} {
+		if err := os.Chtimes(path, dayAgo, dayAgo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := fs.Put(strings.NewReader("stored again"), 1<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := fs.Prune(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d files, want the old one and the leftover upload", removed)
+	}
+	for hash, want := range map[string]bool{old: false, again: true, fresh: true} {
+		if _, err := os.Stat(fs.Path(hash)); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", hash, err == nil, want)
+		}
+	}
+	if _, err := os.Stat(leftover); err == nil {
+		t.Error("kept the leftover upload")
+	}
+}
@@ -3,6 +3,7 @@ package notifications
 import (
 	"biyobot/configs"
 	"biyobot/services/database"
+	"context"
 	"encoding/json"
 	"time"
 )
@@ -21,16 +22,16 @@ type Output struct {
 }
 
 type Service struct {
-	notifyRepo *database.NotificationsRepo
+	notifyRepo database.Notifications
 }
 
-func NewService(notifyRepo *database.NotificationsRepo) *Service {
+func NewService(notifyRepo database.Notifications) *Service {
 	return &Service{
 		notifyRepo: notifyRepo,
 	}
 }
 
-func (s *Service) Run(msg json.RawMessage) configs.ServiceResult {
+func (s *Service) Run(ctx context.Context, msg json.RawMessage) configs.ServiceResult {
 	var input Input
 	if err := json.Unmarshal(msg, &input); err != nil {
 		return configs.Failure("invalid input: " + err.Error())
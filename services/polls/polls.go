@@ -0,0 +1,331 @@
+package polls
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxOptions        = 10
+	maxQuestionLength = 200
+	// Discord limits button labels to 80 characters
+	maxOptionLength = 80
+)
+
+type Input struct {
+	Action      string   `json:"action"` // create | vote | close
+	ID          string   `json:"id"`
+	GuildId     string   `json:"guild_id"`
+	ChannelId   string   `json:"channel_id"`
+	UserId      string   `json:"user_id"`
+	Metadata    string   `json:"metadata"`
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+	MultiChoice bool     `json:"multi_choice"`
+	Anonymous   bool     `json:"anonymous"`
+	Closes      string   `json:"closes"`    // RFC3339, a duration like 2h or a time of day like 18:00 or 6pm
+	TimeZone    string   `json:"time_zone"` // closing times of day are read in it, JST when empty
+	Option      int      `json:"option"`
+}
+
+// actor is who the poll's closing notification is recorded as changed by.
+func (i Input) actor() database.Actor {
+	return database.Actor{UserId: i.UserId, GuildId: i.GuildId, ChannelId: i.ChannelId}
+}
+
+type Output struct {
+	ResultMessage string       `json:"message"`
+	Poll          *models.Poll `json:"poll"`
+	Options       []string     `json:"options"`
+	Tally         []Tally      `json:"tally"`
+}
+
+// Tally is the votes one option got, Voters is left empty for anonymous
+// polls.
+type Tally struct {
+	Option string   `json:"option"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+type Service struct {
+	pollRepo   database.Polls
+	notifyRepo database.Notifications
+}
+
+func NewService(pollRepo database.Polls, notifyRepo database.Notifications) *Service {
+	return &Service{
+		pollRepo:   pollRepo,
+		notifyRepo: notifyRepo,
+	}
+}
+
+func (s *Service) Run(ctx context.Context, msg json.RawMessage) configs.ServiceResult {
+	var input Input
+	if err := json.Unmarshal(msg, &input); err != nil {
+		return configs.Failure("invalid input: " + err.Error())
+	}
+
+	var output *Output
+	var err error
+	switch input.Action {
+	case "create":
+		output, err = s.create(ctx, input)
+	case "vote":
+		output, err = s.vote(ctx, input)
+	case "close":
+		output, err = s.close(ctx, input)
+	default:
+		return configs.Failure("`action` can only be `create | vote | close`")
+	}
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+	return configs.Success(output)
+}
+
+func (s *Service) create(ctx context.Context, input Input) (*Output, error) {
+	question := strings.TrimSpace(input.Question)
+	if question == "" {
+		return nil, fmt.Errorf("`question` is required")
+	}
+	if utf8.RuneCountInString(question) > maxQuestionLength {
+		return nil, fmt.Errorf("the question is longer than %d characters", maxQuestionLength)
+	}
+	var options []string
+	for _, option := range input.Options {
+		option = strings.TrimSpace(option)
+		if option != "" && !slices.Contains(options, option) {
+			options = append(options, option)
+		}
+	}
+	if len(options) < 2 || len(options) > MaxOptions {
+		return nil, fmt.Errorf("a poll needs 2 to %d options", MaxOptions)
+	}
+	for _, option := range options {
+		if utf8.RuneCountInString(option) > maxOptionLength {
+			return nil, fmt.Errorf("option `%s` is longer than %d characters", option, maxOptionLength)
+		}
+	}
+	optionsJson, err := utils.StructToJson(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize options: %s", err)
+	}
+
+	data := database.AddPollDto{
+		GuildId:     input.GuildId,
+		ChannelId:   input.ChannelId,
+		UserId:      input.UserId,
+		Question:    question,
+		Options:     optionsJson,
+		MultiChoice: input.MultiChoice,
+		Anonymous:   input.Anonymous,
+	}
+	if input.Closes != "" {
+		loc := utils.JapanTimeNow().Location()
+		if tz, err := time.LoadLocation(input.TimeZone); err == nil && input.TimeZone != "" {
+			loc = tz
+		}
+		closesAt, err := ParseCloses(input.Closes, time.Now().In(loc))
+		if err != nil {
+			return nil, err
+		}
+		// the scheduler closes the poll, announcing the results
+		notification, err := s.notifyRepo.AddNotification(ctx, database.AddNotificationDto{
+			Service:  configs.ServiceNames.Polls,
+			Metadata: input.Metadata,
+			NotifyAt: closesAt,
+			Title:    "Poll closed",
+			Message:  question,
+			GuildId:  input.GuildId,
+			UserId:   input.UserId,
+			Private:  true,
+			Actor:    input.actor(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to schedule closing: %s", err)
+		}
+		data.ClosesAt, data.NotificationId = &closesAt, &notification.ID
+	}
+
+	poll, err := s.pollRepo.AddPoll(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add poll: %s", err)
+	}
+	return s.output(ctx, poll)
+}
+
+func (s *Service) vote(ctx context.Context, input Input) (*Output, error) {
+	poll, err := s.getPoll(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.Closed {
+		return nil, fmt.Errorf("this poll is closed")
+	}
+	options := pollOptions(poll)
+	if input.Option < 0 || input.Option >= len(options) {
+		return nil, fmt.Errorf("unknown option")
+	}
+	if err := s.pollRepo.Vote(ctx, poll.ID, input.UserId, input.Option, poll.MultiChoice); err != nil {
+		return nil, fmt.Errorf("failed to save your vote: %s", err)
+	}
+	return s.output(ctx, poll)
+}
+
+func (s *Service) close(ctx context.Context, input Input) (*Output, error) {
+	poll, err := s.getPoll(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	closed, err := s.pollRepo.ClosePoll(ctx, poll.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close poll: %s", err)
+	}
+	if !closed {
+		return nil, fmt.Errorf("this poll is already closed")
+	}
+	poll.Closed = true
+	// closed early, the scheduled closing is no longer needed
+	if poll.NotificationId != nil {
+		err := s.notifyRepo.DeleteNotification(ctx, *poll.NotificationId, input.actor())
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "failed to cancel closing of poll", "poll_id", poll.ID, "err", err)
+		}
+	}
+
+	output, err := s.output(ctx, poll)
+	if err != nil {
+		return nil, err
+	}
+	output.ResultMessage = formatResults(poll.Question, output.Tally)
+	return output, nil
+}
+
+func (s *Service) getPoll(ctx context.Context, id string) (*models.Poll, error) {
+	pollId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse poll id: %s", err)
+	}
+	poll, err := s.pollRepo.GetPoll(ctx, pollId)
+	if err != nil {
+		return nil, fmt.Errorf("poll `%s` not found", id)
+	}
+	return poll, nil
+}
+
+func (s *Service) output(ctx context.Context, poll *models.Poll) (*Output, error) {
+	votes, err := s.pollRepo.GetVotes(ctx, poll.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load votes: %s", err)
+	}
+	options := pollOptions(poll)
+	tally := make([]Tally, len(options))
+	for i, option := range options {
+		tally[i].Option = option
+	}
+	for _, vote := range votes {
+		if vote.Option < 0 || vote.Option >= len(tally) {
+			continue
+		}
+		tally[vote.Option].Votes++
+		if !poll.Anonymous {
+			tally[vote.Option].Voters = append(tally[vote.Option].Voters, vote.UserId)
+		}
+	}
+	return &Output{Poll: poll, Options: options, Tally: tally}, nil
+}
+
+func pollOptions(poll *models.Poll) []string {
+	options, err := utils.JsonToStruct[[]string](poll.Options)
+	if err != nil {
+		slog.Error("failed to parse poll options", "poll_id", poll.ID, "err", err)
+		return nil
+	}
+	return options
+}
+
+// formatResults announces the winning options of a closed poll.
+func formatResults(question string, tally []Tally) string {
+	most := 0
+	for _, t := range tally {
+		most = max(most, t.Votes)
+	}
+	if most == 0 {
+		return fmt.Sprintf("📊 **%s** closed without any votes.", question)
+	}
+	var winners []string
+	for _, t := range tally {
+		if t.Votes == most {
+			winners = append(winners, "**"+t.Option+"**")
+		}
+	}
+	votes := "votes"
+	if most == 1 {
+		votes = "vote"
+	}
+	if len(winners) > 1 {
+		return fmt.Sprintf("📊 **%s** closed, it's a tie between %s with %d %s each.", question, strings.Join(winners, " and "), most, votes)
+	}
+	return fmt.Sprintf("📊 **%s** closed, %s wins with %d %s.", question, winners[0], most, votes)
+}
+
+var clockRe = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+// ParseCloses reads when a poll closes: an RFC3339 time, a duration from now
+// like 90m or 2h, or a time of day like 18:00 or 6pm, which means tomorrow
+// once it has passed today.
+func ParseCloses(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("the closing time has already passed")
+		}
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("the closing time has already passed")
+		}
+		return now.Add(d), nil
+	}
+
+	match := clockRe.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("can't read closing time `%s`, use e.g. `2h`, `18:00` or `6pm`", value)
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	switch {
+	case match[3] != "" && (hour < 1 || hour > 12):
+		return time.Time{}, fmt.Errorf("can't read closing time `%s`", value)
+	case match[3] == "pm" && hour != 12:
+		hour += 12
+	case match[3] == "am" && hour == 12:
+		hour = 0
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, fmt.Errorf("can't read closing time `%s`", value)
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
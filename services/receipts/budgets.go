@@ -0,0 +1,159 @@
+package receipts
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// budgetThresholds are the percentages of a monthly budget that trigger an
+// alert, each at most once per month.
+var budgetThresholds = []int{80, 100}
+
+func (s *Service) budget(ctx context.Context, input Input) configs.ServiceResult {
+	if input.UserId == "" {
+		return configs.Failure("`user_id` is required")
+	}
+	if input.Total == "" {
+		return s.budgetStatus(ctx, input.GuildId, input.UserId)
+	}
+	if input.Category == "" {
+		return configs.Failure("`category` is required")
+	}
+
+	category := normalizeCategory(input.Category)
+	currency := normalizeCurrency(input.Currency)
+	limitRaw, err := utils.ToRaw(input.Total, currency)
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+	if limitRaw <= 0 {
+		return configs.Failure("budget must be greater than zero")
+	}
+
+	budget, err := s.budgetRepo.SetBudget(ctx, database.SetBudgetDto{
+		GuildId:  input.GuildId,
+		UserId:   input.UserId,
+		Category: category,
+		LimitRaw: limitRaw,
+		Currency: currency,
+		Metadata: input.Metadata,
+	})
+	if err != nil {
+		return configs.Failure("failed to set budget: " + err.Error())
+	}
+	return configs.Success(Output{
+		ResultMessage: fmt.Sprintf("🎯 Monthly budget for **%s** set to %s", budget.Category, utils.ToAmount(budget.LimitRaw, budget.Currency)),
+	})
+}
+
+func (s *Service) budgetStatus(ctx context.Context, guildId, userId string) configs.ServiceResult {
+	budgets, err := s.budgetRepo.GetBudgets(ctx, guildId, userId)
+	if err != nil {
+		return configs.Failure("failed to load budgets: " + err.Error())
+	}
+	if len(budgets) == 0 {
+		return configs.Success(Output{ResultMessage: "📭 No budgets set. Say e.g. `set groceries budget to 40000`"})
+	}
+
+	month := currentMonth()
+	var b strings.Builder
+	fmt.Fprintf(&b, "🎯 **Budgets — %s**\n\n", month)
+	for _, budget := range budgets {
+		spent, err := s.spentInCategory(ctx, budget, month)
+		if err != nil {
+			return configs.Failure("failed to load expenses: " + err.Error())
+		}
+		fmt.Fprintf(&b, "• %s — %s / %s (%d%%)\n", budget.Category,
+			utils.ToAmount(spent, budget.Currency), utils.ToAmount(budget.LimitRaw, budget.Currency), percentOf(spent, budget.LimitRaw))
+	}
+	return configs.Success(Output{ResultMessage: b.String()})
+}
+
+// checkBudget queues a notification when an expense pushes its category over
+// the next budget threshold for the month the expense belongs to.
+func (s *Service) checkBudget(ctx context.Context, expense *models.Expense) {
+	budget, err := s.budgetRepo.GetBudget(ctx, expense.GuildId, expense.UserId, expense.Category)
+	if err != nil || budget == nil || budget.Currency != expense.Currency {
+		return
+	}
+
+	// older receipts don't alert, the month they belong to is already over
+	month := monthOf(expense)
+	if !month.From.Equal(currentMonth().From) {
+		return
+	}
+
+	spent, err := s.spentInCategory(ctx, *budget, month)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check budget", "budget_id", budget.ID, "err", err)
+		return
+	}
+	percent := percentOf(spent, budget.LimitRaw)
+	monthKey := month.From.Format("2006-01")
+
+	crossed := 0
+	for _, t := range budgetThresholds {
+		if percent >= t {
+			crossed = t
+		}
+	}
+	if crossed == 0 || (budget.AlertedMonth == monthKey && budget.AlertedPercent >= crossed) {
+		return
+	}
+
+	title := fmt.Sprintf("Budget alert: %s", budget.Category)
+	message := fmt.Sprintf("You've spent %s of your %s %s budget for %s (%d%%).",
+		utils.ToAmount(spent, budget.Currency), utils.ToAmount(budget.LimitRaw, budget.Currency), budget.Category, month, percent)
+	_, err = s.notifyRepo.AddNotification(ctx, database.AddNotificationDto{
+		Service:  "budgets",
+		Metadata: budget.Metadata,
+		NotifyAt: utils.JapanTimeNow(),
+		Title:    title,
+		Message:  message,
+		UserId:   budget.UserId,
+		Private:  true,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to queue budget alert", "budget_id", budget.ID, "err", err)
+		return
+	}
+	if err := s.budgetRepo.MarkAlerted(ctx, budget.ID, monthKey, crossed); err != nil {
+		slog.ErrorContext(ctx, "failed to mark budget alerted", "budget_id", budget.ID, "err", err)
+	}
+}
+
+func monthOf(expense *models.Expense) period {
+	at := expense.PurchasedAt.In(utils.JapanTimeNow().Location())
+	from := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, at.Location())
+	return period{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+// spentInCategory sums the budget owner's expenses in the budget's guild, a
+// DM budget (empty guild) counts spending everywhere.
+func (s *Service) spentInCategory(ctx context.Context, budget models.Budget, p period) (int64, error) {
+	expenses, err := s.expenseRepo.GetExpensesBetween(ctx, budget.GuildId, budget.UserId, p.From, p.To)
+	if err != nil {
+		return 0, err
+	}
+	var spent int64
+	for _, e := range expenses {
+		if e.Category == budget.Category && e.Currency == budget.Currency {
+			spent += e.TotalRaw
+		}
+	}
+	return spent, nil
+}
+
+func percentOf(part, whole int64) int {
+	if whole <= 0 {
+		return 0
+	}
+	return int(part * 100 / whole)
+}
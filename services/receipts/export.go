@@ -0,0 +1,143 @@
+package receipts
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/utils"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// chartColors pairs each bar color with the emoji square used as its legend
+// in the message text, since the image itself carries no labels.
+var chartColors = []struct {
+	Emoji string
+	Color color.RGBA
+}{
+	{"🟦", color.RGBA{0x55, 0xac, 0xee, 0xff}},
+	{"🟩", color.RGBA{0x78, 0xb1, 0x59, 0xff}},
+	{"🟧", color.RGBA{0xf4, 0x90, 0x0c, 0xff}},
+	{"🟪", color.RGBA{0xaa, 0x8e, 0xd6, 0xff}},
+	{"🟥", color.RGBA{0xdd, 0x2e, 0x44, 0xff}},
+	{"🟨", color.RGBA{0xfd, 0xcb, 0x58, 0xff}},
+	{"🟫", color.RGBA{0xc1, 0x69, 0x4f, 0xff}},
+	{"⬜", color.RGBA{0xe6, 0xe7, 0xe8, 0xff}},
+}
+
+func (s *Service) export(ctx context.Context, input Input) configs.ServiceResult {
+	if input.UserId == "" {
+		return configs.Failure("`user_id` is required")
+	}
+	p, err := resolvePeriod(input)
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+	expenses, err := s.expenseRepo.GetExpensesBetween(ctx, input.GuildId, input.UserId, p.From, p.To)
+	if err != nil {
+		return configs.Failure("failed to load expenses: " + err.Error())
+	}
+	if len(expenses) == 0 {
+		return configs.Success(Output{ResultMessage: fmt.Sprintf("📭 No expenses for %s.", p)})
+	}
+
+	name := fmt.Sprintf("expenses_%s_%s", p.From.Format("20060102"), p.To.AddDate(0, 0, -1).Format("20060102"))
+	switch input.Format {
+	case "", "csv":
+		data, err := expensesCSV(expenses)
+		if err != nil {
+			return configs.Failure("failed to write csv: " + err.Error())
+		}
+		return configs.Success(Output{
+			ResultMessage: fmt.Sprintf("📄 %d expenses for %s", len(expenses), p),
+			File:          &File{Name: name + ".csv", ContentType: "text/csv", Data: data},
+		})
+	case "chart":
+		return expensesChart(expenses, p, name+".png")
+	}
+	return configs.Failure("`format` can only be `csv | chart`")
+}
+
+func expensesCSV(expenses []models.Expense) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "date", "merchant", "category", "total", "currency"})
+	for _, e := range expenses {
+		decimals := utils.CurrencyDecimals[e.Currency]
+		units := float64(utils.CurrencyUnits[e.Currency])
+		w.Write([]string{
+			e.ID.String(),
+			e.PurchasedAt.Format("2006-01-02"),
+			e.Merchant,
+			e.Category,
+			strconv.FormatFloat(float64(e.TotalRaw)/units, 'f', int(decimals), 64),
+			e.Currency,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// expensesChart renders per-category totals of the dominant currency as a
+// bar chart. Mixing currencies on one axis would be meaningless.
+func expensesChart(expenses []models.Expense, p period, name string) configs.ServiceResult {
+	totals := groupTotals(expenses, func(e models.Expense) string { return e.Category })
+	currency := dominantCurrency(expenses)
+
+	var bars []total
+	for _, t := range totals {
+		if t.Currency == currency {
+			bars = append(bars, t)
+		}
+	}
+	if len(bars) > len(chartColors) {
+		bars = bars[:len(chartColors)]
+	}
+
+	const width, height, pad, gap = 640, 360, 24, 16
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0x2b, 0x2d, 0x31, 0xff}}, image.Point{}, draw.Src)
+
+	maxRaw := bars[0].Raw
+	barWidth := (width - 2*pad - gap*(len(bars)-1)) / len(bars)
+	var legend strings.Builder
+	fmt.Fprintf(&legend, "📊 **Spending by category — %s** (%s)\n\n", p, currency)
+	for i, t := range bars {
+		barHeight := 1
+		if maxRaw > 0 {
+			barHeight = max(1, int(t.Raw*int64(height-2*pad)/maxRaw))
+		}
+		x := pad + i*(barWidth+gap)
+		rect := image.Rect(x, height-pad-barHeight, x+barWidth, height-pad)
+		draw.Draw(img, rect, &image.Uniform{chartColors[i].Color}, image.Point{}, draw.Src)
+		fmt.Fprintf(&legend, "%s %s — %s\n", chartColors[i].Emoji, t.Key, utils.ToAmount(t.Raw, t.Currency))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return configs.Failure("failed to render chart: " + err.Error())
+	}
+	return configs.Success(Output{
+		ResultMessage: legend.String(),
+		File:          &File{Name: name, ContentType: "image/png", Data: buf.Bytes()},
+	})
+}
+
+func dominantCurrency(expenses []models.Expense) string {
+	counts := make(map[string]int)
+	best := expenses[0].Currency
+	for _, e := range expenses {
+		counts[e.Currency]++
+		if counts[e.Currency] > counts[best] {
+			best = e.Currency
+		}
+	}
+	return best
+}
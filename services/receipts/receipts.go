@@ -0,0 +1,331 @@
+package receipts
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/services/database"
+	"biyobot/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var Categories = []string{
+	"groceries", "dining", "transport", "shopping", "utilities", "health", "entertainment", "other",
+}
+
+// Cleaner turns raw OCR text into receipt fields (merchant, date, total,
+// currency, category, items), usually with help from the LLM.
+type Cleaner interface {
+	CleanReceipt(ctx context.Context, ocrText string) (map[string]any, error)
+}
+
+type Input struct {
+	Action      string                  `json:"action"` // add | edit | delete | summary | budget | export
+	ID          string                  `json:"id"`
+	Attachments []configs.AttachmentRef `json:"attachments"`
+	GuildId     string                  `json:"guild_id"` // empty in DMs
+	UserId      string                  `json:"user_id"`
+	Metadata    string                  `json:"metadata"`
+	Merchant    string                  `json:"merchant"`
+	Date        string                  `json:"date"` // YYYY-MM-DD
+	Total       string                  `json:"total"`
+	Currency    string                  `json:"currency"`
+	Category    string                  `json:"category"`
+	Month       string                  `json:"month"` // YYYY-MM
+	From        string                  `json:"from"`  // YYYY-MM-DD, inclusive
+	To          string                  `json:"to"`    // YYYY-MM-DD, inclusive
+	GroupBy     string                  `json:"group_by"`
+	Format      string                  `json:"format"` // csv | chart
+}
+
+type Output struct {
+	ResultMessage string            `json:"message"`
+	Expenses      []*models.Expense `json:"expenses,omitempty"`
+	File          *File             `json:"file,omitempty"`
+}
+
+// File is an attachment the caller should upload alongside ResultMessage.
+type File struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+type ocrOutput struct {
+	Text string `json:"text"`
+}
+
+type Service struct {
+	expenseRepo database.Expenses
+	budgetRepo  database.Budgets
+	notifyRepo  database.Notifications
+	ocr         configs.Runner
+	cleaner     Cleaner
+}
+
+func NewService(expenseRepo database.Expenses, budgetRepo database.Budgets, notifyRepo database.Notifications, ocr configs.Runner, cleaner Cleaner) *Service {
+	return &Service{
+		expenseRepo: expenseRepo,
+		budgetRepo:  budgetRepo,
+		notifyRepo:  notifyRepo,
+		ocr:         ocr,
+		cleaner:     cleaner,
+	}
+}
+
+func (s *Service) Run(ctx context.Context, msg json.RawMessage) configs.ServiceResult {
+	var input Input
+	if err := json.Unmarshal(msg, &input); err != nil {
+		return configs.Failure("invalid input: " + err.Error())
+	}
+
+	switch input.Action {
+	case "add":
+		return s.add(ctx, input)
+	case "edit":
+		return s.edit(ctx, input)
+	case "delete":
+		return s.delete(ctx, input)
+	case "summary":
+		return s.summary(ctx, input)
+	case "budget":
+		return s.budget(ctx, input)
+	case "export":
+		return s.export(ctx, input)
+	case "":
+		return configs.Failure("`action` is required")
+	default:
+		return configs.Failure("`action` can only be `add | edit | delete | summary | budget | export`")
+	}
+}
+
+// add scans every image attachment as a separate receipt. It only fails when
+// none of them could be stored.
+func (s *Service) add(ctx context.Context, input Input) configs.ServiceResult {
+	if input.UserId == "" {
+		return configs.Failure("`user_id` is required")
+	}
+	var images []configs.AttachmentRef
+	for _, a := range input.Attachments {
+		if a.IsImage() {
+			images = append(images, a)
+		}
+	}
+	if len(images) == 0 {
+		return configs.Failure("attach a photo of the receipt to scan it")
+	}
+
+	var output Output
+	var lines, failures []string
+	for _, image := range images {
+		expense, err := s.scan(ctx, image, input)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("❌ Could not read `%s`: %s", image.Filename, err))
+			continue
+		}
+		output.Expenses = append(output.Expenses, expense)
+		lines = append(lines, "🧾 Saved receipt\n"+FormatExpense(expense)+
+			fmt.Sprintf("\nSomething wrong? Say e.g. `edit receipt %s total 1500`", expense.ID))
+	}
+	if len(output.Expenses) == 0 {
+		return configs.Failure(strings.Join(failures, "\n"))
+	}
+	output.ResultMessage = strings.Join(append(lines, failures...), "\n\n")
+	return configs.Success(output)
+}
+
+func (s *Service) scan(ctx context.Context, image configs.AttachmentRef, input Input) (*models.Expense, error) {
+	ocrInput, _ := json.Marshal(map[string]string{"image_path": image.Path})
+	var ocr ocrOutput
+	if err := s.ocr.Run(ctx, ocrInput).Decode(&ocr); err != nil {
+		return nil, fmt.Errorf("ocr failed: %w", err)
+	}
+	if strings.TrimSpace(ocr.Text) == "" {
+		return nil, fmt.Errorf("no text could be read from the receipt image")
+	}
+
+	params, err := s.cleaner.CleanReceipt(ctx, ocr.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := normalizeCurrency(utils.ParamString(params, "currency"))
+	totalRaw, err := utils.ToRaw(paramAmount(params, "total"), currency)
+	if err != nil {
+		return nil, fmt.Errorf("could not read receipt total: %w", err)
+	}
+
+	expense, err := s.expenseRepo.AddExpense(ctx, database.AddExpenseDto{
+		GuildId:     input.GuildId,
+		UserId:      input.UserId,
+		Metadata:    input.Metadata,
+		Merchant:    fallback(utils.ParamString(params, "merchant"), "Unknown merchant"),
+		Category:    normalizeCategory(utils.ParamString(params, "category")),
+		PurchasedAt: parseDate(utils.ParamString(params, "date")),
+		TotalRaw:    totalRaw,
+		Currency:    currency,
+		OcrText:     ocr.Text,
+		Items:       parseItems(params, currency),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save expense: %w", err)
+	}
+	s.checkBudget(ctx, expense)
+	return expense, nil
+}
+
+// ownedExpense loads the expense input.ID refers to, as long as it belongs to
+// the requesting user and, outside of DMs, to the guild they asked from.
+func (s *Service) ownedExpense(ctx context.Context, input Input) (*models.Expense, error) {
+	expenseId, err := uuid.Parse(input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("`id` is not a valid expense id")
+	}
+	expense, err := s.expenseRepo.GetExpense(ctx, expenseId)
+	if err != nil {
+		return nil, fmt.Errorf("expense not found")
+	}
+	if expense.UserId != input.UserId || (input.GuildId != "" && expense.GuildId != input.GuildId) {
+		return nil, fmt.Errorf("expense not found")
+	}
+	return expense, nil
+}
+
+func (s *Service) edit(ctx context.Context, input Input) configs.ServiceResult {
+	existing, err := s.ownedExpense(ctx, input)
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+
+	data := database.EditExpenseDto{
+		ID:          existing.ID,
+		Merchant:    fallback(input.Merchant, existing.Merchant),
+		Category:    existing.Category,
+		PurchasedAt: existing.PurchasedAt,
+		TotalRaw:    existing.TotalRaw,
+		Currency:    existing.Currency,
+	}
+	if input.Category != "" {
+		data.Category = normalizeCategory(input.Category)
+	}
+	if input.Date != "" {
+		data.PurchasedAt = parseDate(input.Date)
+	}
+	if input.Currency != "" {
+		data.Currency = normalizeCurrency(input.Currency)
+	}
+	if input.Total != "" {
+		data.TotalRaw, err = utils.ToRaw(input.Total, data.Currency)
+		if err != nil {
+			return configs.Failure(err.Error())
+		}
+	}
+
+	expense, err := s.expenseRepo.EditExpense(ctx, data)
+	if err != nil {
+		return configs.Failure("failed to edit expense: " + err.Error())
+	}
+	s.checkBudget(ctx, expense)
+	return configs.Success(Output{
+		ResultMessage: "✏️ Updated receipt\n" + FormatExpense(expense),
+		Expenses:      []*models.Expense{expense},
+	})
+}
+
+func (s *Service) delete(ctx context.Context, input Input) configs.ServiceResult {
+	expense, err := s.ownedExpense(ctx, input)
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+	if err := s.expenseRepo.DeleteExpense(ctx, expense.ID); err != nil {
+		return configs.Failure("failed to delete expense: " + err.Error())
+	}
+	return configs.Success(Output{
+		ResultMessage: fmt.Sprintf("🗑️ Deleted receipt `%s`", expense.ID),
+	})
+}
+
+func FormatExpense(e *models.Expense) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** — %s `[id:%s]`\n", e.Merchant, e.PurchasedAt.Format("Jan 02, 2006"), e.ID)
+	fmt.Fprintf(&b, "💴 %s · 🏷️ %s\n", utils.ToAmount(e.TotalRaw, e.Currency), e.Category)
+	for _, item := range e.Items {
+		fmt.Fprintf(&b, "• %s ×%d — %s\n", item.Name, item.Quantity, utils.ToAmount(item.AmountRaw, e.Currency))
+	}
+	return b.String()
+}
+
+func parseItems(params map[string]any, currency string) []database.AddExpenseItemDto {
+	rawItems, _ := params["items"].([]any)
+	var items []database.AddExpenseItemDto
+	for _, raw := range rawItems {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := utils.ParamString(item, "name")
+		amountRaw, err := utils.ToRaw(paramAmount(item, "amount"), currency)
+		if name == "" || err != nil {
+			continue
+		}
+		quantity := 1
+		if q, ok := item["quantity"].(float64); ok && q >= 1 {
+			quantity = int(q)
+		}
+		items = append(items, database.AddExpenseItemDto{
+			Name:      name,
+			Quantity:  quantity,
+			AmountRaw: amountRaw,
+		})
+	}
+	return items
+}
+
+// paramAmount reads an amount the LLM may have emitted as a string or number.
+func paramAmount(params map[string]any, key string) string {
+	switch v := params[key].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+func parseDate(date string) time.Time {
+	now := utils.JapanTimeNow()
+	parsed, err := time.ParseInLocation("2006-01-02", date, now.Location())
+	if err != nil {
+		return now
+	}
+	return parsed
+}
+
+func normalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := utils.CurrencyUnits[currency]; !ok {
+		return "JPY"
+	}
+	return currency
+}
+
+func normalizeCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if !slices.Contains(Categories, category) {
+		return "other"
+	}
+	return category
+}
+
+func fallback(value, def string) string {
+	if strings.TrimSpace(value) == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,65 @@
+package receipts
+
+import (
+	"biyobot/services/database"
+	"biyobot/services/database/memory"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEditAndDeleteOnlyOwnExpenses(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepos()
+	s := NewService(repos.Expenses, repos.Budgets, repos.Notifications, nil, nil)
+	expense, err := repos.Expenses.AddExpense(ctx, database.AddExpenseDto{
+		GuildId:     "g1",
+		UserId:      "alice",
+		Merchant:    "Lawson",
+		Category:    "food",
+		PurchasedAt: time.Now(),
+		TotalRaw:    500,
+		Currency:    "JPY",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   Input
+		allowed bool
+	}{
+		{"other user", Input{GuildId: "g1", UserId: "bob"}, false},
+		{"other guild", Input{GuildId: "g2", UserId: "alice"}, false},
+		{"owner in DMs", Input{UserId: "alice"}, true},
+		{"owner in the guild", Input{GuildId: "g1", UserId: "alice"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Action = "edit"
+			tt.input.ID = expense.ID.String()
+			tt.input.Merchant = "FamilyMart"
+			msg, _ := json.Marshal(tt.input)
+			if result := s.Run(ctx, msg); result.OK != tt.allowed {
+				t.Errorf("edit ok = %v (%s), want %v", result.OK, result.Error, tt.allowed)
+			}
+		})
+	}
+
+	for _, input := range []Input{{GuildId: "g1", UserId: "bob"}, {GuildId: "g2", UserId: "alice"}} {
+		input.Action, input.ID = "delete", expense.ID.String()
+		msg, _ := json.Marshal(input)
+		if result := s.Run(ctx, msg); result.OK {
+			t.Errorf("%s deleted alice's expense from %s", input.UserId, input.GuildId)
+		}
+	}
+	msg, _ := json.Marshal(Input{Action: "delete", ID: expense.ID.String(), GuildId: "g1", UserId: "alice"})
+	if result := s.Run(ctx, msg); !result.OK {
+		t.Fatalf("delete: %s", result.Error)
+	}
+	if _, err := repos.Expenses.GetExpense(ctx, expense.ID); err == nil {
+		t.Error("expense still there after delete")
+	}
+}
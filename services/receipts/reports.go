@@ -0,0 +1,147 @@
+package receipts
+
+import (
+	"biyobot/configs"
+	"biyobot/models"
+	"biyobot/utils"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type period struct {
+	From time.Time // inclusive
+	To   time.Time // exclusive
+}
+
+func (p period) String() string {
+	last := p.To.AddDate(0, 0, -1)
+	if p.From.Day() == 1 && p.To.Equal(p.From.AddDate(0, 1, 0)) {
+		return p.From.Format("January 2006")
+	}
+	return fmt.Sprintf("%s – %s", p.From.Format("Jan 02, 2006"), last.Format("Jan 02, 2006"))
+}
+
+// resolvePeriod picks the reporting range from month or from/to, defaulting
+// to the current month.
+func resolvePeriod(input Input) (period, error) {
+	loc := utils.JapanTimeNow().Location()
+	if input.Month != "" {
+		month, err := time.ParseInLocation("2006-01", input.Month, loc)
+		if err != nil {
+			return period{}, fmt.Errorf("`month` must be YYYY-MM")
+		}
+		return period{From: month, To: month.AddDate(0, 1, 0)}, nil
+	}
+	if input.From != "" || input.To != "" {
+		from, err := time.ParseInLocation("2006-01-02", input.From, loc)
+		if err != nil {
+			return period{}, fmt.Errorf("`from` must be YYYY-MM-DD")
+		}
+		to := utils.JapanTimeNow()
+		if input.To != "" {
+			if to, err = time.ParseInLocation("2006-01-02", input.To, loc); err != nil {
+				return period{}, fmt.Errorf("`to` must be YYYY-MM-DD")
+			}
+		}
+		to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+		if !to.After(from) {
+			return period{}, fmt.Errorf("`to` must not be before `from`")
+		}
+		return period{From: from, To: to}, nil
+	}
+	return currentMonth(), nil
+}
+
+func currentMonth() period {
+	now := utils.JapanTimeNow()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return period{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+type total struct {
+	Key      string
+	Currency string
+	Raw      int64
+	Count    int
+}
+
+// groupTotals sums expenses per key and currency, largest amount first.
+func groupTotals(expenses []models.Expense, key func(models.Expense) string) []total {
+	idx := make(map[string]*total)
+	var totals []*total
+	for _, e := range expenses {
+		k := key(e)
+		t, ok := idx[k+"|"+e.Currency]
+		if !ok {
+			t = &total{Key: k, Currency: e.Currency}
+			idx[k+"|"+e.Currency] = t
+			totals = append(totals, t)
+		}
+		t.Raw += e.TotalRaw
+		t.Count++
+	}
+
+	result := make([]total, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Currency != result[j].Currency {
+			return result[i].Currency < result[j].Currency
+		}
+		return result[i].Raw > result[j].Raw
+	})
+	return result
+}
+
+func groupKey(groupBy string) (func(models.Expense) string, error) {
+	switch groupBy {
+	case "", "category":
+		return func(e models.Expense) string { return e.Category }, nil
+	case "merchant":
+		return func(e models.Expense) string { return e.Merchant }, nil
+	case "month":
+		return func(e models.Expense) string { return e.PurchasedAt.Format("2006-01") }, nil
+	}
+	return nil, fmt.Errorf("`group_by` can only be `category | merchant | month`")
+}
+
+func (s *Service) summary(ctx context.Context, input Input) configs.ServiceResult {
+	if input.UserId == "" {
+		return configs.Failure("`user_id` is required")
+	}
+	p, err := resolvePeriod(input)
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+	key, err := groupKey(input.GroupBy)
+	if err != nil {
+		return configs.Failure(err.Error())
+	}
+	expenses, err := s.expenseRepo.GetExpensesBetween(ctx, input.GuildId, input.UserId, p.From, p.To)
+	if err != nil {
+		return configs.Failure("failed to load expenses: " + err.Error())
+	}
+	if len(expenses) == 0 {
+		return configs.Success(Output{ResultMessage: fmt.Sprintf("📭 No expenses for %s.", p)})
+	}
+
+	groupBy := input.GroupBy
+	if groupBy == "" {
+		groupBy = "category"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 **Spending by %s — %s**\n\n", groupBy, p)
+	for _, t := range groupTotals(expenses, key) {
+		fmt.Fprintf(&b, "• %s — %s (%d)\n", t.Key, utils.ToAmount(t.Raw, t.Currency), t.Count)
+	}
+	b.WriteString("\n")
+	for _, t := range groupTotals(expenses, func(models.Expense) string { return "Total" }) {
+		fmt.Fprintf(&b, "**Total: %s** across %d receipts\n", utils.ToAmount(t.Raw, t.Currency), t.Count)
+	}
+	return configs.Success(Output{ResultMessage: b.String()})
+}
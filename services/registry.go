@@ -2,8 +2,12 @@ package services
 
 import (
 	"biyobot/configs"
+	"biyobot/metrics"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"time"
 )
 
 type Registry struct {
@@ -18,12 +22,27 @@ func (r *Registry) Register(name string, svc configs.Runner) {
 	r.services[name] = svc
 }
 
-func (r *Registry) Run(name string, input json.RawMessage) configs.ServiceResult {
+// Run runs the service called name and logs how it went under the request
+// ID of ctx.
+func (r *Registry) Run(ctx context.Context, name string, input json.RawMessage) configs.ServiceResult {
 	svc, ok := r.services[name]
 	if !ok {
+		slog.WarnContext(ctx, "unknown service", "service", name)
 		return configs.Failure(fmt.Sprintf("unknown service: %q", name))
 	}
-	return svc.Run(input)
+	start := time.Now()
+	result := svc.Run(ctx, input)
+	outcome := "ok"
+	if !result.OK {
+		outcome = "failed"
+	}
+	metrics.ServiceRuns.WithLabelValues(name, outcome).Observe(time.Since(start).Seconds())
+	if result.OK {
+		slog.InfoContext(ctx, "service ran", "service", name, "duration", time.Since(start))
+	} else {
+		slog.WarnContext(ctx, "service failed", "service", name, "duration", time.Since(start), "err", result.Error)
+	}
+	return result
 }
 
 func (r *Registry) Names() []string {
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string // empty when the spec is valid
+	}{
+		{spec: "*/15 * * * *"},
+		{spec: "0 9-17/2 * * mon-fri"},
+		{spec: "0 0 1,15 * *"},
+		{spec: "0 0 * * 7"},
+		{spec: "@daily"},
+		{spec: "@WEEKLY"},
+		{spec: "* * * *", err: "neither an interval"},
+		{spec: "60 * * * *", err: "minute"},
+		{spec: "0 24 * * *", err: "hour"},
+		{spec: "0 0 0 * *", err: "day of month"},
+		{spec: "0 0 * 13 *", err: "month"},
+		{spec: "0 0 * * 8", err: "day of week"},
+		{spec: "*/0 * * * *", err: "not a positive number"},
+		{spec: "0 17-9 * * *", err: "runs backwards"},
+		{spec: "0 0 * * funday", err: "day of week"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := parseCron(tt.spec, time.UTC)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("parsed, want an error mentioning %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("error %q does not mention %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name        string
+		spec        string
+		after, want string // want is empty when it never matches
+	}{
+		{"step", "*/15 * * * *", "2025-03-10 10:07", "2025-03-10 10:15"},
+		{"step wraps the hour", "*/15 * * * *", "2025-03-10 10:45", "2025-03-10 11:00"},
+		{"strictly after", "30 4 * * *", "2025-03-10 04:30", "2025-03-11 04:30"},
+		{"seconds are dropped", "30 4 * * *", "2025-03-10 04:29", "2025-03-10 04:30"},
+		{"stepped range", "0 9-17/4 * * *", "2025-03-10 13:01", "2025-03-10 17:00"},
+		// 2025-03-10 is a Monday
+		{"day of week", "0 8 * * fri", "2025-03-10 09:00", "2025-03-14 08:00"},
+		{"7 is Sunday", "0 8 * * 7", "2025-03-10 09:00", "2025-03-16 08:00"},
+		{"0 is Sunday", "0 8 * * 0", "2025-03-10 09:00", "2025-03-16 08:00"},
+		// either day field matching is enough when both are restricted
+		{"day of month or week, week first", "0 0 20 * sat", "2025-03-10 09:00", "2025-03-15 00:00"},
+		{"day of month or week, month first", "0 0 11 * sat", "2025-03-10 09:00", "2025-03-11 00:00"},
+		// with one of them *, the other one decides
+		{"day of month with any weekday", "0 0 20 * *", "2025-03-10 09:00", "2025-03-20 00:00"},
+		{"month", "0 0 1 jun *", "2025-03-10 09:00", "2025-06-01 00:00"},
+		{"leap day", "0 0 29 2 *", "2025-03-10 09:00", "2028-02-29 00:00"},
+		{"year end", "@yearly", "2025-12-31 23:59", "2026-01-01 00:00"},
+		{"never matches", "0 0 30 2 *", "2025-03-10 09:00", ""},
+		{"never matches in April", "0 0 31 4 *", "2025-03-10 09:00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.spec, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.next(at(tt.after))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("next = %s, want none", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("next = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCronNextInLocation(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	c, err := parseCron("0 4 * * *", jst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20:00 UTC is 05:00 JST, the next 04:00 JST is the following 19:00 UTC
+	got := c.next(time.Date(2025, 3, 10, 20, 0, 0, 0, time.UTC))
+	if want := time.Date(2025, 3, 11, 19, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("next = %s, want %s", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse("0 0 30 2 *", 0); err == nil || !strings.Contains(err.Error(), "never matches") {
+		t.Errorf("February 30th: err = %v", err)
+	}
+	if _, err := Parse("500ms", 0); err == nil {
+		t.Error("an interval under a second was accepted")
+	}
+	if _, err := Parse("1m", -time.Second); err == nil {
+		t.Error("a negative jitter was accepted")
+	}
+
+	s, err := Parse("5m", 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.String(); got != "5m0s (up to +30s)" {
+		t.Errorf("String() = %q", got)
+	}
+	now := time.Now()
+	for range 100 {
+		next := s.Next(now)
+		if next.Before(now.Add(5*time.Minute)) || !next.Before(now.Add(5*time.Minute+30*time.Second)) {
+			t.Fatalf("next = %s, want within 30s after %s", next, now.Add(5*time.Minute))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"biyobot/utils"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Schedule is when a task runs: every interval, or at the times a cron
+// expression matches. Runs start up to the jitter late, so tasks scheduled
+// alike don't all start at once.
+type Schedule struct {
+	spec   string
+	every  time.Duration
+	cron   *cron
+	jitter time.Duration
+}
+
+// Every runs a task every interval.
+func Every(interval, jitter time.Duration) Schedule {
+	return Schedule{spec: interval.String(), every: interval, jitter: jitter}
+}
+
+// Parse reads a schedule: an interval like "90s" or "3m", or a cron
+// expression like "0 4 * * *" or "@daily", evaluated in JST.
+func Parse(spec string, jitter time.Duration) (Schedule, error) {
+	if jitter < 0 {
+		return Schedule{}, fmt.Errorf("jitter must not be negative, got %s", jitter)
+	}
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval < time.Second {
+			return Schedule{}, fmt.Errorf("interval must be at least 1s, got %s", interval)
+		}
+		return Every(interval, jitter), nil
+	}
+	c, err := parseCron(spec, utils.JapanTimeNow().Location())
+	if err != nil {
+		return Schedule{}, err
+	}
+	if c.next(time.Now()).IsZero() {
+		return Schedule{}, fmt.Errorf("cron expression %q never matches", spec)
+	}
+	return Schedule{spec: spec, cron: c, jitter: jitter}, nil
+}
+
+// Next is the first run after after, zero when there is none.
+func (s Schedule) Next(after time.Time) time.Time {
+	var next time.Time
+	if s.cron != nil {
+		next = s.cron.next(after)
+	} else {
+		next = after.Add(s.every)
+	}
+	if s.jitter > 0 && !next.IsZero() {
+		next = next.Add(rand.N(s.jitter))
+	}
+	return next
+}
+
+// String is the spec the schedule was made from, with the jitter runs may
+// start late by.
+func (s Schedule) String() string {
+	if s.jitter > 0 {
+		return fmt.Sprintf("%s (up to +%s)", s.spec, s.jitter)
+	}
+	return s.spec
+}
@@ -0,0 +1,338 @@
+// Package scheduler runs the bot's background tasks on intervals or cron
+// schedules. A run that panics is recovered and counts as failed, a run due
+// while the previous one is still going is skipped, and the last run of
+// each task is stored so a restart doesn't run it again before it is due.
+package scheduler
+
+import (
+	"biyobot/logging"
+	"biyobot/metrics"
+	"biyobot/models"
+	"biyobot/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// saveTimeout bounds storing a task's last run.
+const saveTimeout = 5 * time.Second
+
+// Func is the work of a task, the error is the run's result.
+type Func func(ctx context.Context) error
+
+// Store keeps the last run of each task, database.TaskRuns implements it.
+type Store interface {
+	GetTaskRun(ctx context.Context, name string) (*models.TaskRun, error)
+	SaveTaskRun(ctx context.Context, run models.TaskRun) error
+}
+
+type Scheduler struct {
+	ctx   context.Context
+	store Store
+
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+// New returns a scheduler whose tasks run until ctx is done or they are
+// stopped. store may be nil, then nothing survives a restart.
+func New(ctx context.Context, store Store) *Scheduler {
+	return &Scheduler{ctx: ctx, store: store, tasks: map[string]*Task{}}
+}
+
+// Add starts running fn on schedule as the task name. It runs right away
+// unless its stored last run says the next one isn't due yet.
+func (s *Scheduler) Add(name string, schedule Schedule, fn Func) *Task {
+	ctx, cancel := context.WithCancel(s.ctx)
+	t := &Task{
+		name:     name,
+		fn:       fn,
+		store:    s.store,
+		cancel:   cancel,
+		trigger:  make(chan struct{}, 1),
+		reset:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		schedule: schedule,
+	}
+
+	first := time.Now()
+	if last := t.load(ctx); last != nil {
+		t.lastRun = last.LastRunAt
+		t.lastDuration = time.Duration(last.DurationMs) * time.Millisecond
+		if last.Error != "" {
+			t.lastErr = errors.New(last.Error)
+		}
+		if next := schedule.Next(last.LastRunAt); next.After(first) {
+			first = next
+		}
+	}
+
+	s.mu.Lock()
+	if old := s.tasks[name]; old != nil {
+		old.Stop()
+	}
+	s.tasks[name] = t
+	s.mu.Unlock()
+
+	go t.loop(ctx, first)
+	return t
+}
+
+// Task returns the task added as name, nil if there is none.
+func (s *Scheduler) Task(name string) *Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks[name]
+}
+
+// Names lists the tasks in alphabetical order.
+func (s *Scheduler) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.tasks))
+	for name := range s.tasks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Task is a task added to a scheduler.
+type Task struct {
+	name    string
+	fn      Func
+	store   Store
+	cancel  context.CancelFunc
+	paused  atomic.Bool
+	running atomic.Bool
+	trigger chan struct{}
+	reset   chan struct{}
+	runs    sync.WaitGroup
+	done    chan struct{}
+
+	mu           sync.Mutex
+	schedule     Schedule
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastErr      error
+	nextRun      time.Time
+	skipped      int
+}
+
+// Status is what a task is doing and how its last run went.
+type Status struct {
+	Name         string
+	Schedule     string
+	Paused       bool
+	Running      bool
+	LastRun      time.Time // zero if it never ran
+	LastDuration time.Duration
+	LastError    error
+	NextRun      time.Time // zero if there is no next run
+	Skipped      int       // runs skipped since startup because one was still going
+}
+
+func (t *Task) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Status{
+		Name:         t.name,
+		Schedule:     t.schedule.String(),
+		Paused:       t.Paused(),
+		Running:      t.running.Load(),
+		LastRun:      t.lastRun,
+		LastDuration: t.lastDuration,
+		LastError:    t.lastErr,
+		NextRun:      t.nextRun,
+		Skipped:      t.skipped,
+	}
+}
+
+// Stop cancels the task, a run in progress sees its context cancelled. Done
+// is closed once it has returned.
+func (t *Task) Stop() {
+	t.cancel()
+}
+
+// Done is closed when the task has stopped and no run is in progress.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Pause skips the scheduled runs until Resume, Trigger still runs the task.
+func (t *Task) Pause() {
+	t.paused.Store(true)
+}
+
+func (t *Task) Resume() {
+	t.paused.Store(false)
+}
+
+func (t *Task) Paused() bool {
+	return t.paused.Load()
+}
+
+// Trigger runs the task now without waiting for its next run. It returns
+// false when a run is already in progress, the trigger is dropped then.
+func (t *Task) Trigger() bool {
+	if t.running.Load() {
+		return false
+	}
+	select {
+	case t.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// SetSchedule changes when the task runs, the next run counts from now.
+func (t *Task) SetSchedule(schedule Schedule) {
+	t.mu.Lock()
+	t.schedule = schedule
+	t.mu.Unlock()
+	select {
+	case t.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Task) loop(ctx context.Context, first time.Time) {
+	defer close(t.done)
+	defer t.runs.Wait()
+
+	timer := time.NewTimer(t.setNext(first))
+	defer timer.Stop()
+	for {
+		// select picks at random among ready cases, a stopped task must not
+		// start another run
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-timer.C:
+			if !t.Paused() {
+				t.start(ctx)
+			}
+			timer.Reset(t.setNext(t.nextAfter(time.Now())))
+		case <-t.trigger:
+			t.start(ctx)
+		case <-t.reset:
+			timer.Reset(t.setNext(t.nextAfter(time.Now())))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *Task) nextAfter(after time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.schedule.Next(after)
+}
+
+// setNext records next as the next run and returns how long until then. A
+// schedule without a next run parks the timer until the schedule changes.
+func (t *Task) setNext(next time.Time) time.Duration {
+	t.mu.Lock()
+	t.nextRun = next
+	t.mu.Unlock()
+	if next.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+	return max(0, time.Until(next))
+}
+
+// start runs the task in the background unless the previous run is still
+// going, a slow run must not pile up behind itself.
+func (t *Task) start(ctx context.Context) {
+	if !t.running.CompareAndSwap(false, true) {
+		t.mu.Lock()
+		t.skipped++
+		t.mu.Unlock()
+		metrics.TaskRuns.WithLabelValues(t.name, "skipped").Inc()
+		slog.WarnContext(ctx, "skipped task run, the previous one is still running", "task", t.name)
+		return
+	}
+	t.runs.Go(func() {
+		defer t.running.Store(false)
+		t.run(ctx)
+	})
+}
+
+func (t *Task) run(ctx context.Context) {
+	// each run is a request of its own, its log lines share an ID
+	ctx = logging.WithRequestID(ctx)
+	startedAt := time.Now()
+	t.mu.Lock()
+	t.lastRun = startedAt
+	t.mu.Unlock()
+	slog.DebugContext(ctx, "running task", "task", t.name)
+
+	outcome, err := t.call(ctx)
+	duration := time.Since(startedAt)
+	metrics.TaskRuns.WithLabelValues(t.name, outcome).Inc()
+	metrics.TaskDuration.WithLabelValues(t.name).Observe(duration.Seconds())
+	if err != nil {
+		slog.ErrorContext(ctx, "task failed", "task", t.name, "duration", duration, "err", err)
+	} else {
+		slog.DebugContext(ctx, "task finished", "task", t.name, "duration", duration)
+	}
+
+	t.mu.Lock()
+	t.lastDuration = duration
+	t.lastErr = err
+	t.mu.Unlock()
+
+	// a run cut short by shutdown runs again after the restart
+	if ctx.Err() != nil {
+		return
+	}
+	run := models.TaskRun{Name: t.name, LastRunAt: utils.InJapanTime(startedAt), DurationMs: duration.Milliseconds()}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	t.save(ctx, run)
+}
+
+// call runs fn, a panic is recovered into an error.
+func (t *Task) call(ctx context.Context) (outcome string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "task panicked", "task", t.name, "panic", r, "stack", string(debug.Stack()))
+			outcome, err = "panic", fmt.Errorf("panic: %v", r)
+		}
+	}()
+	err = t.fn(ctx)
+	return metrics.Outcome(err), err
+}
+
+func (t *Task) load(ctx context.Context) *models.TaskRun {
+	if t.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, saveTimeout)
+	defer cancel()
+	run, err := t.store.GetTaskRun(ctx, t.name)
+	if err != nil {
+		slog.Warn("failed to load last task run, running it now", "task", t.name, "err", err)
+		return nil
+	}
+	return run
+}
+
+func (t *Task) save(ctx context.Context, run models.TaskRun) {
+	if t.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+	if err := t.store.SaveTaskRun(ctx, run); err != nil {
+		slog.WarnContext(ctx, "failed to save task run", "task", t.name, "err", err)
+	}
+}
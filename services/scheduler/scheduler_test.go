@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"biyobot/models"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeStore keeps task runs in memory.
+type fakeStore struct {
+	mu   sync.Mutex
+	runs map[string]models.TaskRun
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{runs: map[string]models.TaskRun{}}
+}
+
+func (s *fakeStore) GetTaskRun(ctx context.Context, name string) (*models.TaskRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[name]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func (s *fakeStore) SaveTaskRun(ctx context.Context, run models.TaskRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.Name] = run
+	return nil
+}
+
+func (s *fakeStore) get(name string) (models.TaskRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[name]
+	return run, ok
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func stopAll(t *testing.T, s *Scheduler) {
+	t.Cleanup(func() {
+		for _, name := range s.Names() {
+			task := s.Task(name)
+			task.Stop()
+			<-task.Done()
+		}
+	})
+}
+
+func TestNoRerunAfterRestart(t *testing.T) {
+	store := newFakeStore()
+	ran := make(chan struct{}, 10)
+	fn := func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}
+
+	first := New(context.Background(), store)
+	task := first.Add("hourly", Every(time.Hour, 0), fn)
+	<-ran
+	waitFor(t, "the run to be saved", func() bool { _, ok := store.get("hourly"); return ok })
+	task.Stop()
+	<-task.Done()
+
+	// a restart within the hour waits for the next run
+	second := New(context.Background(), store)
+	stopAll(t, second)
+	task = second.Add("hourly", Every(time.Hour, 0), fn)
+	select {
+	case <-ran:
+		t.Fatal("the task ran again right after the restart")
+	case <-time.After(50 * time.Millisecond):
+	}
+	status := task.Status()
+	if status.LastRun.IsZero() {
+		t.Error("the last run was not loaded")
+	}
+	if until := time.Until(status.NextRun); until < 59*time.Minute {
+		t.Errorf("next run in %s, want about an hour", until)
+	}
+}
+
+func TestPanicCountsAsFailed(t *testing.T) {
+	store := newFakeStore()
+	s := New(context.Background(), store)
+	stopAll(t, s)
+	var runs atomic.Int32
+	task := s.Add("boom", Every(time.Hour, 0), func(ctx context.Context) error {
+		runs.Add(1)
+		panic("boom")
+	})
+	waitFor(t, "the run to be saved", func() bool { _, ok := store.get("boom"); return ok })
+
+	run, _ := store.get("boom")
+	if run.Error != "panic: boom" {
+		t.Errorf("stored error = %q, want the panic", run.Error)
+	}
+	if err := task.Status().LastError; err == nil || err.Error() != "panic: boom" {
+		t.Errorf("status error = %v, want the panic", err)
+	}
+	// the task keeps going after a panic
+	waitFor(t, "the run to finish", func() bool { return !task.Status().Running })
+	if !task.Trigger() {
+		t.Fatal("trigger dropped")
+	}
+	waitFor(t, "the second run", func() bool { return runs.Load() == 2 })
+}
+
+func TestOverlappingRunIsSkipped(t *testing.T) {
+	s := New(context.Background(), nil)
+	stopAll(t, s)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	task := s.Add("slow", Every(time.Hour, 0), func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	<-started
+
+	if task.Trigger() {
+		t.Error("trigger accepted while a run is in progress")
+	}
+	// a run coming due while the previous one goes on is skipped
+	task.start(context.Background())
+	if got := task.Status().Skipped; got != 1 {
+		t.Errorf("skipped = %d, want 1", got)
+	}
+	if !task.Status().Running {
+		t.Error("status does not show the run in progress")
+	}
+	close(release)
+	waitFor(t, "the run to finish", func() bool { return !task.Status().Running })
+	select {
+	case <-started:
+		t.Error("the skipped run ran anyway")
+	default:
+	}
+}
+
+func TestStopWaitsForRun(t *testing.T) {
+	store := newFakeStore()
+	s := New(context.Background(), store)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	task := s.Add("stubborn", Every(time.Hour, 0), func(ctx context.Context) error {
+		close(started)
+		// ignores the cancellation a while, like a query that is almost done
+		<-ctx.Done()
+		<-release
+		return ctx.Err()
+	})
+	<-started
+
+	task.Stop()
+	select {
+	case <-task.Done():
+		t.Fatal("Done closed while the run was in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-task.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after the run returned")
+	}
+	// a run cut short by the stop runs again after a restart
+	if _, ok := store.get("stubborn"); ok {
+		t.Error("the cancelled run was saved")
+	}
+	if err := task.Status().LastError; !errors.Is(err, context.Canceled) {
+		t.Errorf("last error = %v, want context.Canceled", err)
+	}
+}
@@ -6,3 +6,9 @@ func JapanTimeNow() time.Time {
 	now := time.Now().In(time.FixedZone("JST", 9*60*60)) // UTC+9
 	return now
 }
+
+// InJapanTime converts t to JST. Times are stored in JST so that the
+// database compares them correctly as text.
+func InJapanTime(t time.Time) time.Time {
+	return t.In(JapanTimeNow().Location())
+}
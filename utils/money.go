@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var CurrencyDecimals = map[string]int32{
+	"JPY": 0,
+	"USD": 2,
+	"EUR": 2,
+}
+var CurrencyUnits = map[string]int64{
+	"USD": 100, // 1 USD = 100 cents
+	"EUR": 100,
+	"JPY": 1, // no minor units
+}
+
+// ToRaw converts a decimal amount string into minor units of currency.
+func ToRaw(amount string, currency string) (int64, error) {
+	units, ok := CurrencyUnits[currency]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency: %s", currency)
+	}
+	cleaned := strings.NewReplacer(",", "", "¥", "", "$", "", "€", "", "円", "").Replace(strings.TrimSpace(amount))
+	parsed, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", amount)
+	}
+	return int64(math.Round(parsed * float64(units))), nil
+}
+
+// ToAmount formats minor units of currency as "CUR 12.34".
+func ToAmount(raw int64, currency string) string {
+	decimals, ok := CurrencyDecimals[currency]
+	if !ok {
+		decimals = 2
+	}
+	divisor := math.Pow(10, float64(decimals))
+	return fmt.Sprintf("%s %.*f", currency, decimals, float64(raw)/divisor)
+}
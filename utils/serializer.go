@@ -3,6 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 func StructToJson[T any](v T) (string, error) {
@@ -29,3 +31,44 @@ func ParamString(params map[string]any, key string) string {
 	s, _ := v.(string)
 	return s
 }
+
+// ParamBool reads a boolean the LLM may have answered as a string.
+func ParamBool(params map[string]any, key string) bool {
+	switch v := params[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	}
+	return false
+}
+
+// ParamInt reads an integer the LLM may have answered as a float or string.
+func ParamInt(params map[string]any, key string) int {
+	switch v := params[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		return n
+	}
+	return 0
+}
+
+// ParamList reads a list the LLM may have answered as an array or as a
+// comma separated string.
+func ParamList(params map[string]any, key string) []string {
+	var list []string
+	switch v := params[key].(type) {
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	case string:
+		list = strings.Split(v, ",")
+	}
+	return list
+}